@@ -0,0 +1,313 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/organization_api/config"
+	"github.com/organization_api/pkg/apperror"
+	db "github.com/organization_api/pkg/database"
+	"github.com/organization_api/pkg/database/mongodb/models"
+	"github.com/organization_api/pkg/database/mongodb/repository"
+	"github.com/organization_api/pkg/utils"
+)
+
+// errUsage reports a command invoked with the wrong arguments.
+var errUsage = errors.New("invalid arguments")
+
+// admin runs the user, org and token commands against the repository layer.
+type admin struct {
+	organizations repository.OrganizationStore
+	users         repository.UserStore
+	settings      repository.SettingsStore
+	domains       repository.DomainStore
+	teams         repository.TeamStore
+	webhooks      repository.WebhookStore
+	tokens        repository.TokenRevocationStore
+
+	stdin  io.Reader
+	stdout io.Writer
+}
+
+// administer connects to MongoDB and Redis and runs a user, org or token command.
+func administer(group string, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	positional, flags := splitArgs(args)
+	if len(positional) == 0 {
+		fmt.Fprintf(stderr, "%s: missing subcommand\n\n%s", group, usage)
+		return 2
+	}
+
+	cfg, _, status := loadConfig(flags, stderr)
+	if cfg == nil {
+		return status
+	}
+	utils.ConfigureTokens(cfg.JWT)
+
+	if err := db.Connect(cfg.Mongo); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	defer db.Disconnect(context.Background())
+	redisClient := config.Init_redis(cfg.Redis)
+	defer redisClient.Close()
+
+	a := &admin{
+		organizations: repository.NewOrganizationRepo(),
+		users:         repository.NewUserRepository(),
+		settings:      repository.NewSettingsRepo(),
+		domains:       repository.NewDomainRepo(),
+		teams:         repository.NewTeamRepo(),
+		webhooks:      repository.NewWebhookRepo(),
+		tokens:        repository.NewRedisTokenRevocations(redisClient),
+		stdin:         stdin,
+		stdout:        stdout,
+	}
+
+	err := a.run(context.Background(), group, positional)
+	if errors.Is(err, errUsage) {
+		fmt.Fprintf(stderr, "%s %s: %v\n\n%s", group, positional[0], err, usage)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "%s %s: %v\n", group, positional[0], err)
+		return 1
+	}
+	return 0
+}
+
+// run executes `<group> <subcommand> [arguments]`.
+func (a *admin) run(ctx context.Context, group string, args []string) error {
+	command, args := group+" "+args[0], args[1:]
+	expect := func(n int) error {
+		if len(args) != n {
+			return errUsage
+		}
+		return nil
+	}
+
+	switch command {
+	case "user create":
+		if err := expect(2); err != nil {
+			return err
+		}
+		return a.createUser(ctx, args[0], args[1])
+	case "user disable":
+		if err := expect(1); err != nil {
+			return err
+		}
+		return a.disableUser(ctx, args[0])
+	case "user reset-password":
+		if err := expect(1); err != nil {
+			return err
+		}
+		return a.resetPassword(ctx, args[0])
+	case "user verify":
+		if err := expect(1); err != nil {
+			return err
+		}
+		return a.verifyEmail(ctx, args[0])
+	case "org list":
+		if err := expect(0); err != nil {
+			return err
+		}
+		return a.listOrganizations(ctx)
+	case "org show":
+		if err := expect(1); err != nil {
+			return err
+		}
+		return a.showOrganization(ctx, args[0])
+	case "org transfer":
+		if err := expect(2); err != nil {
+			return err
+		}
+		return a.transferOrganization(ctx, args[0], args[1])
+	case "org delete":
+		if err := expect(1); err != nil {
+			return err
+		}
+		return a.deleteOrganization(ctx, args[0])
+	case "token revoke":
+		if err := expect(1); err != nil {
+			return err
+		}
+		return a.revokeTokens(ctx, args[0])
+	default:
+		return fmt.Errorf("%w: unknown command %q", errUsage, command)
+	}
+}
+
+func (a *admin) createUser(ctx context.Context, email, name string) error {
+	password, err := a.readPassword()
+	if err != nil {
+		return err
+	}
+
+	// Apply the same rules as sign-up.
+	user := models.User{Name: name, Email: email, Password: password}
+	if err := utils.ValidateUser(user); err != nil {
+		return err
+	}
+	if user.Password, err = utils.HashPassword(password); err != nil {
+		return err
+	}
+
+	if _, err := a.users.CreateUser(ctx, &user); err != nil {
+		return err
+	}
+	// Like signing up, this settles any invitations waiting for the account.
+	if err := a.organizations.AcceptInvitations(ctx, email); err != nil {
+		return err
+	}
+	fmt.Fprintf(a.stdout, "created user %s\n", email)
+	return nil
+}
+
+func (a *admin) disableUser(ctx context.Context, email string) error {
+	if err := a.users.SetUserDisabled(ctx, email, true); err != nil {
+		return err
+	}
+	// Cut off sessions that are already open, not just future sign-ins.
+	if err := a.revoke(ctx, email); err != nil {
+		return err
+	}
+	fmt.Fprintf(a.stdout, "disabled user %s and revoked their tokens\n", email)
+	return nil
+}
+
+// verifyEmail confirms an address on the user's behalf, e.g. where no SMTP relay is configured.
+func (a *admin) verifyEmail(ctx context.Context, email string) error {
+	if err := a.users.SetEmailVerified(ctx, email); err != nil {
+		return err
+	}
+	fmt.Fprintf(a.stdout, "marked the email address of %s as confirmed\n", email)
+	return nil
+}
+
+func (a *admin) resetPassword(ctx context.Context, email string) error {
+	password, err := a.readPassword()
+	if err != nil {
+		return err
+	}
+
+	user, err := a.users.FindUserByEmail(ctx, email)
+	if err != nil {
+		return err
+	}
+	user.Password = password
+	if err := utils.ValidateUser(*user); err != nil {
+		return err
+	}
+
+	hash, err := utils.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	if err := a.users.UpdatePassword(ctx, email, hash); err != nil {
+		return err
+	}
+	if err := a.revoke(ctx, email); err != nil {
+		return err
+	}
+	fmt.Fprintf(a.stdout, "reset the password of %s and revoked their tokens\n", email)
+	return nil
+}
+
+func (a *admin) listOrganizations(ctx context.Context) error {
+	organizations, err := a.organizations.GetAllOrganizations(ctx)
+	if err != nil {
+		return err
+	}
+
+	table := tabwriter.NewWriter(a.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "ID\tSLUG\tNAME\tOWNER\tMEMBERS")
+	for _, org := range organizations {
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%d\n", org.Id.Hex(), org.Slug, org.Name, org.Owner, len(org.InvitedUsers))
+	}
+	return table.Flush()
+}
+
+func (a *admin) showOrganization(ctx context.Context, organizationID string) error {
+	org, err := a.organizations.GetOrganizationById(ctx, organizationID)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(a.stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(org)
+}
+
+func (a *admin) transferOrganization(ctx context.Context, organizationID, email string) error {
+	// Operators act on behalf of whoever owns the organization now.
+	org, err := a.organizations.GetOrganizationById(ctx, organizationID)
+	if err != nil {
+		return err
+	}
+	if err := a.organizations.TransferOwnership(ctx, organizationID, org.Owner, email); err != nil {
+		return err
+	}
+	fmt.Fprintf(a.stdout, "%s now owns organization %s\n", email, organizationID)
+	return nil
+}
+
+func (a *admin) deleteOrganization(ctx context.Context, organizationID string) error {
+	// Children would be left pointing at a parent that no longer exists.
+	children, err := a.organizations.GetChildOrganizations(ctx, organizationID)
+	if err != nil {
+		return err
+	}
+	if len(children) > 0 {
+		return apperror.Conflict("Organization has %d child organizations; move or delete them first", len(children))
+	}
+
+	if err := a.organizations.DeleteOrganization(ctx, organizationID); err != nil {
+		return err
+	}
+	if err := a.settings.DeleteSettings(ctx, organizationID); err != nil {
+		return err
+	}
+	if err := a.domains.DeleteDomainClaims(ctx, organizationID); err != nil {
+		return err
+	}
+	if err := a.teams.DeleteTeams(ctx, organizationID); err != nil {
+		return err
+	}
+	if err := a.webhooks.DeleteWebhooks(ctx, organizationID); err != nil {
+		return err
+	}
+	fmt.Fprintf(a.stdout, "deleted organization %s\n", organizationID)
+	return nil
+}
+
+func (a *admin) revokeTokens(ctx context.Context, email string) error {
+	if err := a.revoke(ctx, email); err != nil {
+		return err
+	}
+	fmt.Fprintf(a.stdout, "revoked every token issued to %s so far\n", email)
+	return nil
+}
+
+// revoke invalidates every token issued to email until now. The record outlives the longest-lived token.
+func (a *admin) revoke(ctx context.Context, email string) error {
+	return a.tokens.RevokeTokens(ctx, email, time.Now(), utils.RefreshTokenLifetime())
+}
+
+// readPassword reads a password from the first line of stdin, so it never appears in the process list or shell history.
+func (a *admin) readPassword() (string, error) {
+	line, err := bufio.NewReader(a.stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", errors.New("no password on stdin")
+	}
+	return password, nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/organization_api/pkg/apperror"
+	"github.com/organization_api/pkg/database/mongodb/models"
+	"github.com/organization_api/pkg/database/mongodb/repository"
+	"github.com/organization_api/pkg/utils"
+)
+
+func newTestAdmin(stdin string) (*admin, *bytes.Buffer) {
+	var stdout bytes.Buffer
+	return &admin{
+		organizations: repository.NewMemoryOrganizationRepo(),
+		users:         repository.NewMemoryUserRepository(),
+		settings:      repository.NewMemorySettingsRepo(),
+		domains:       repository.NewMemoryDomainRepo(),
+		teams:         repository.NewMemoryTeamRepo(),
+		webhooks:      repository.NewMemoryWebhookRepo(),
+		tokens:        repository.NewMemoryTokenRevocations(),
+		stdin:         strings.NewReader(stdin),
+		stdout:        &stdout,
+	}, &stdout
+}
+
+func TestAdminUserCommands(t *testing.T) {
+	ctx := context.Background()
+	a, _ := newTestAdmin("password123\n")
+
+	if err := a.run(ctx, "user", []string{"create", "ada@example.com", "Ada"}); err != nil {
+		t.Fatalf("user create: %v", err)
+	}
+	user, err := a.users.FindUserByEmail(ctx, "ada@example.com")
+	if err != nil {
+		t.Fatalf("FindUserByEmail: %v", err)
+	}
+	if ok, _ := utils.CheckPasswordHash("password123", user.Password); !ok {
+		t.Fatal("expected the password to be stored hashed")
+	}
+
+	a.stdin = strings.NewReader("new-password\n")
+	if err := a.run(ctx, "user", []string{"reset-password", "ada@example.com"}); err != nil {
+		t.Fatalf("user reset-password: %v", err)
+	}
+	user, _ = a.users.FindUserByEmail(ctx, "ada@example.com")
+	if ok, _ := utils.CheckPasswordHash("new-password", user.Password); !ok {
+		t.Fatal("expected the password to be replaced")
+	}
+	revokedAt, err := a.tokens.RevokedAt(ctx, "ada@example.com")
+	if err != nil || time.Since(revokedAt) > time.Minute {
+		t.Fatalf("expected tokens to be revoked on reset, got %v, %v", revokedAt, err)
+	}
+
+	if err := a.run(ctx, "user", []string{"verify", "ada@example.com"}); err != nil {
+		t.Fatalf("user verify: %v", err)
+	}
+	user, _ = a.users.FindUserByEmail(ctx, "ada@example.com")
+	if !user.EmailVerified {
+		t.Fatal("expected the email address to be confirmed")
+	}
+
+	if err := a.run(ctx, "user", []string{"disable", "ada@example.com"}); err != nil {
+		t.Fatalf("user disable: %v", err)
+	}
+	user, _ = a.users.FindUserByEmail(ctx, "ada@example.com")
+	if !user.Disabled {
+		t.Fatal("expected the user to be disabled")
+	}
+
+	if err := a.run(ctx, "user", []string{"disable", "nobody@example.com"}); !errors.Is(err, apperror.ErrNotFound) {
+		t.Fatalf("disable unknown user: expected not found, got %v", err)
+	}
+	a.stdin = strings.NewReader("short\n")
+	if err := a.run(ctx, "user", []string{"create", "bob@example.com", "Bob"}); err == nil {
+		t.Fatal("expected a too-short password to be rejected")
+	}
+}
+
+func TestAdminOrganizationCommands(t *testing.T) {
+	ctx := context.Background()
+	a, stdout := newTestAdmin("")
+
+	id, err := a.organizations.CreateOrganization(ctx, &models.Organization{
+		Name:         "Acme",
+		Owner:        "ada@example.com",
+		InvitedUsers: []string{"ada@example.com", "bob@example.com"},
+	})
+	if err != nil {
+		t.Fatalf("CreateOrganization: %v", err)
+	}
+
+	if err := a.run(ctx, "org", []string{"list"}); err != nil {
+		t.Fatalf("org list: %v", err)
+	}
+	if out := stdout.String(); !strings.Contains(out, id) || !strings.Contains(out, "ada@example.com") {
+		t.Fatalf("org list: unexpected output %q", out)
+	}
+
+	if err := a.run(ctx, "org", []string{"transfer", id, "bob@example.com"}); err != nil {
+		t.Fatalf("org transfer: %v", err)
+	}
+	if err := a.run(ctx, "org", []string{"transfer", id, "eve@example.com"}); !errors.Is(err, apperror.ErrConflict) {
+		t.Fatalf("transfer to a non-member: expected conflict, got %v", err)
+	}
+
+	stdout.Reset()
+	if err := a.run(ctx, "org", []string{"show", id}); err != nil {
+		t.Fatalf("org show: %v", err)
+	}
+	if !strings.Contains(stdout.String(), `"owner": "bob@example.com"`) {
+		t.Fatalf("org show: expected the new owner, got %q", stdout)
+	}
+
+	childID, err := a.organizations.CreateOrganization(ctx, &models.Organization{
+		Name:         "Acme Labs",
+		Owner:        "bob@example.com",
+		InvitedUsers: []string{"bob@example.com"},
+		ParentId:     id,
+	})
+	if err != nil {
+		t.Fatalf("CreateOrganization child: %v", err)
+	}
+	if err := a.run(ctx, "org", []string{"delete", id}); !errors.Is(err, apperror.ErrConflict) {
+		t.Fatalf("delete with a child: expected conflict, got %v", err)
+	}
+	if err := a.run(ctx, "org", []string{"delete", childID}); err != nil {
+		t.Fatalf("org delete child: %v", err)
+	}
+
+	// Deleting the organization takes its teams, webhooks and their deliveries with it.
+	a.teams.CreateTeam(ctx, &models.Team{OrganizationId: id, Name: "Platform"})
+	hook := &models.Webhook{OrganizationId: id, URL: "https://hooks.example.com", Active: true}
+	a.webhooks.CreateWebhook(ctx, hook)
+	a.webhooks.SaveDelivery(ctx, &models.WebhookDelivery{WebhookId: hook.Id, OrganizationId: id})
+	if err := a.run(ctx, "org", []string{"delete", id}); err != nil {
+		t.Fatalf("org delete: %v", err)
+	}
+	if _, err := a.organizations.GetOrganizationById(ctx, id); !errors.Is(err, apperror.ErrNotFound) {
+		t.Fatalf("expected the organization to be gone, got %v", err)
+	}
+	teams, _ := a.teams.GetTeamsByOrganization(ctx, id)
+	hooks, _ := a.webhooks.GetWebhooksByOrganization(ctx, id)
+	deliveries, _ := a.webhooks.GetDeliveriesByWebhook(ctx, hook.Id.Hex())
+	if len(teams) != 0 || len(hooks) != 0 || len(deliveries) != 0 {
+		t.Fatalf("expected nothing left, got %d teams, %d webhooks and %d deliveries", len(teams), len(hooks), len(deliveries))
+	}
+}
+
+func TestAdminUsageErrors(t *testing.T) {
+	a, _ := newTestAdmin("")
+
+	for _, args := range [][]string{
+		{"user", "create", "ada@example.com"},
+		{"org", "show"},
+		{"token", "revoke", "a@example.com", "b@example.com"},
+		{"org", "rename", "x"},
+	} {
+		if err := a.run(context.Background(), args[0], args[1:]); !errors.Is(err, errUsage) {
+			t.Errorf("%v: expected a usage error, got %v", args, err)
+		}
+	}
+}
@@ -1,16 +1,111 @@
 package main
 
 import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/organization_api/config"
 	"github.com/organization_api/pkg"
 	db "github.com/organization_api/pkg/database"
 )
 
+const usage = `Usage: organization_api [command] [arguments] [flags]
+
+Commands:
+  serve                              run the HTTP server (default)
+  migrate [up|status]                apply or list schema migrations
+  user create <email> <name>         create a user; the password is read from stdin
+  user disable <email>               disable a user and revoke their tokens
+  user reset-password <email>        set a new password read from stdin and revoke tokens
+  user verify <email>                mark a user's email address as confirmed
+  org list                           list organizations
+  org show <id>                      print an organization as JSON
+  org transfer <id> <email>          make a member the owner of an organization
+  org delete <id>                    delete an organization
+  token revoke <email>               revoke every token issued to a user so far
+
+Every command accepts the configuration flags, e.g. --config or --mongo.uri;
+run "organization_api serve --help" to list them.
+`
+
 func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run dispatches to a command and returns the process exit status.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	command := "serve"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+
+	switch command {
+	case "serve":
+		return serve(args, stderr)
+	case "migrate":
+		return migrate(args, stdout, stderr)
+	case "user", "org", "token":
+		return administer(command, args, stdin, stdout, stderr)
+	case "help":
+		fmt.Fprint(stdout, usage)
+		return 0
+	default:
+		fmt.Fprintf(stderr, "unknown command %q\n\n%s", command, usage)
+		return 2
+	}
+}
+
+// serve runs the HTTP server until it is told to stop.
+func serve(args []string, stderr io.Writer) int {
+	// Load and validate the configuration before touching any dependency.
+	cfg, opts, status := loadConfig(args, stderr)
+	if cfg == nil {
+		return status
+	}
+
 	// Connect to the database.
-	err := db.Connect()
+	if err := db.Connect(cfg.Mongo); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	if err := pkg.Init(config.NewWatcher(cfg, opts, args)); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// loadConfig loads and validates the configuration. It returns a nil config with the exit
+// status when the command should stop: after --print-config, or when loading fails.
+func loadConfig(args []string, stderr io.Writer) (*config.Config, config.Options, int) {
+	cfg, opts, err := config.Load(args)
 	if err != nil {
-		panic(err)
+		fmt.Fprintln(stderr, err)
+		return nil, opts, 2
+	}
+	if opts.PrintConfig {
+		if err := cfg.Print(os.Stdout); err != nil {
+			fmt.Fprintln(stderr, err)
+			return nil, opts, 1
+		}
+		return nil, opts, 0
+	}
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintf(stderr, "invalid configuration:\n%v\n", err)
+		return nil, opts, 2
 	}
+	return cfg, opts, 0
+}
 
-	pkg.Init()
+// splitArgs separates a command's leading positional arguments from the configuration flags that follow.
+func splitArgs(args []string) (positional, flags []string) {
+	for i, arg := range args {
+		if strings.HasPrefix(arg, "-") {
+			return args[:i], args[i:]
+		}
+	}
+	return args, nil
 }
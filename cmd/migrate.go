@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	db "github.com/organization_api/pkg/database"
+	"github.com/organization_api/pkg/database/mongodb/migrations"
+)
+
+// migrate runs `migrate [up|status]`: up applies pending migrations, status lists them.
+func migrate(args []string, stdout, stderr io.Writer) int {
+	positional, flags := splitArgs(args)
+	action := "up"
+	if len(positional) > 0 {
+		action = positional[0]
+	}
+	if len(positional) > 1 || (action != "up" && action != "status") {
+		fmt.Fprintf(stderr, "usage: migrate [up|status] [flags]\n")
+		return 2
+	}
+
+	cfg, _, status := loadConfig(flags, stderr)
+	if cfg == nil {
+		return status
+	}
+	if err := db.Connect(cfg.Mongo); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	defer db.Disconnect(context.Background())
+
+	ctx := context.Background()
+	migrator := migrations.New(db.GetDatabase())
+
+	if action == "up" {
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		fmt.Fprintf(stdout, "applied %d migrations %v\n", len(applied), applied)
+		return 0
+	}
+
+	applied, err := migrator.Applied(ctx)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	pending, err := migrator.Pending(ctx)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	report := struct {
+		Applied []migrations.Record `json:"applied"`
+		Pending []int               `json:"pending"`
+	}{Applied: applied, Pending: []int{}}
+	for _, migration := range pending {
+		report.Pending = append(report.Pending, migration.Version)
+	}
+	encoder := json.NewEncoder(stdout)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(report)
+	return 0
+}
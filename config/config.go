@@ -1,43 +1,288 @@
 package config
 
 import (
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"time"
 
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
+// EnvPrefix prefixes every environment variable override, e.g. ORGAPI_MONGO_URI.
+const EnvPrefix = "ORGAPI"
+
+// DefaultConfigFile is read when no --config flag or ORGAPI_CONFIG variable is given.
+const DefaultConfigFile = "./config/app-config.yaml"
+
+// Config is the complete application configuration.
+type Config struct {
+	App         AppConfig        `mapstructure:"app" yaml:"app"`
+	HTTP        HTTPConfig       `mapstructure:"http" yaml:"http"`
+	Mongo       DbConfig         `mapstructure:"mongo" yaml:"mongo"`
+	Redis       RedisConfig      `mapstructure:"redis" yaml:"redis"`
+	JWT         JWTConfig        `mapstructure:"jwt" yaml:"jwt"`
+	Features    FeatureConfig    `mapstructure:"features" yaml:"features"`
+	Tracing     TracingConfig    `mapstructure:"tracing" yaml:"tracing"`
+	Invitations InvitationConfig `mapstructure:"invitations" yaml:"invitations"`
+	Mail        MailConfig       `mapstructure:"mail" yaml:"mail"`
+}
+
+// AppConfig holds general application settings.
+type AppConfig struct {
+	Name     string `mapstructure:"name" yaml:"name"`
+	Mode     string `mapstructure:"mode" yaml:"mode"`
+	LogLevel string `mapstructure:"log_level" yaml:"log_level"`
+	// LogFormat is json or text.
+	LogFormat string `mapstructure:"log_format" yaml:"log_format"`
+}
+
+// HTTPConfig holds the HTTP server settings.
+type HTTPConfig struct {
+	Addr              string        `mapstructure:"addr" yaml:"addr"`
+	ReadTimeout       time.Duration `mapstructure:"read_timeout" yaml:"read_timeout"`
+	ReadHeaderTimeout time.Duration `mapstructure:"read_header_timeout" yaml:"read_header_timeout"`
+	WriteTimeout      time.Duration `mapstructure:"write_timeout" yaml:"write_timeout"`
+	IdleTimeout       time.Duration `mapstructure:"idle_timeout" yaml:"idle_timeout"`
+	MaxHeaderBytes    int           `mapstructure:"max_header_bytes" yaml:"max_header_bytes"`
+	// ShutdownTimeout bounds how long in-flight requests and background work may drain on shutdown.
+	ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout" yaml:"shutdown_timeout"`
+}
+
 // DbConfig represents the configuration structure for the database.
 type DbConfig struct {
-	URI    string `yaml:"uri"`
-	DbName string `yaml:"dbname"`
+	URI      string     `mapstructure:"uri" yaml:"uri"`
+	DbName   string     `mapstructure:"database" yaml:"database"`
+	Timeouts DbTimeouts `mapstructure:"timeouts" yaml:"timeouts"`
+	// MigrateOnStart applies pending schema migrations before the server starts.
+	MigrateOnStart bool `mapstructure:"migrate_on_start" yaml:"migrate_on_start"`
+}
+
+// DbTimeouts bounds how long individual database operations may run.
+type DbTimeouts struct {
+	Connect time.Duration `mapstructure:"connect" yaml:"connect"`
+	Read    time.Duration `mapstructure:"read" yaml:"read"`
+	Write   time.Duration `mapstructure:"write" yaml:"write"`
+}
+
+// RedisConfig holds the Redis connection settings.
+type RedisConfig struct {
+	Addr     string `mapstructure:"addr" yaml:"addr"`
+	Password string `mapstructure:"password" yaml:"password"`
+	DB       int    `mapstructure:"db" yaml:"db"`
 }
 
-// LoadConfig loads the database configuration from a YAML file using Viper.
-func LoadConfig() (DbConfig, error) {
-	// Initialize an instance of DbConfig to hold the configuration values
-	var config DbConfig
+// JWTConfig holds the token signing settings.
+type JWTConfig struct {
+	Secret             string        `mapstructure:"secret" yaml:"secret"`
+	AccessTokenExpiry  time.Duration `mapstructure:"access_token_expiry" yaml:"access_token_expiry"`
+	RefreshTokenExpiry time.Duration `mapstructure:"refresh_token_expiry" yaml:"refresh_token_expiry"`
+}
+
+// FeatureConfig toggles optional features.
+type FeatureConfig struct {
+	Signup   bool `mapstructure:"signup" yaml:"signup"`
+	Webhooks bool `mapstructure:"webhooks" yaml:"webhooks"`
+}
+
+// InvitationConfig limits how fast members can be invited.
+type InvitationConfig struct {
+	// BatchRateLimit is how many batch invitations an organization may send per BatchRateWindow.
+	// Each batch counts once, whatever the number of emails in it.
+	BatchRateLimit  int           `mapstructure:"batch_rate_limit" yaml:"batch_rate_limit"`
+	BatchRateWindow time.Duration `mapstructure:"batch_rate_window" yaml:"batch_rate_window"`
+}
+
+// MailConfig sets up the SMTP relay used to confirm email addresses.
+type MailConfig struct {
+	// SMTPAddr is the relay's host:port. Empty disables email, so addresses cannot be confirmed.
+	SMTPAddr string `mapstructure:"smtp_addr" yaml:"smtp_addr"`
+	From     string `mapstructure:"from" yaml:"from"`
+	Username string `mapstructure:"username" yaml:"username"`
+	Password string `mapstructure:"password" yaml:"password"`
+	// VerifyURL is the page confirmation emails link to, with the token appended as ?token=.
+	// Empty sends the token on its own, to be posted to /auth/verify-email.
+	VerifyURL string `mapstructure:"verify_url" yaml:"verify_url"`
+}
+
+// TracingConfig selects where OpenTelemetry spans are exported.
+type TracingConfig struct {
+	// Exporter is none, stdout or otlp.
+	Exporter string `mapstructure:"exporter" yaml:"exporter"`
+	// Endpoint is the OTLP/HTTP collector address, e.g. localhost:4318.
+	Endpoint    string  `mapstructure:"endpoint" yaml:"endpoint"`
+	Insecure    bool    `mapstructure:"insecure" yaml:"insecure"`
+	SampleRatio float64 `mapstructure:"sample_ratio" yaml:"sample_ratio"`
+}
 
-	// Set the configuration file path, name, and type for Viper
-	viper.AddConfigPath("./config")
-	viper.SetConfigName("database-config")
-	viper.SetConfigType("yaml")
+// Options are the command-line switches that control loading rather than the configuration itself.
+type Options struct {
+	ConfigFile  string
+	PrintConfig bool
+}
+
+// defaults lists every configuration key; keys absent here cannot be set from the environment or flags.
+var defaults = map[string]interface{}{
+	"app.name":                      "Organization API",
+	"app.mode":                      "debug",
+	"app.log_level":                 "info",
+	"app.log_format":                "json",
+	"http.addr":                     ":8080",
+	"http.read_timeout":             15 * time.Second,
+	"http.read_header_timeout":      5 * time.Second,
+	"http.write_timeout":            30 * time.Second,
+	"http.idle_timeout":             2 * time.Minute,
+	"http.max_header_bytes":         1 << 20,
+	"http.shutdown_timeout":         20 * time.Second,
+	"mongo.uri":                     "mongodb://localhost:27017/",
+	"mongo.database":                "organization_db",
+	"mongo.timeouts.connect":        10 * time.Second,
+	"mongo.timeouts.read":           5 * time.Second,
+	"mongo.timeouts.write":          10 * time.Second,
+	"mongo.migrate_on_start":        true,
+	"redis.addr":                    "localhost:6379",
+	"redis.password":                "",
+	"redis.db":                      0,
+	"jwt.secret":                    "",
+	"jwt.access_token_expiry":       time.Hour,
+	"jwt.refresh_token_expiry":      72 * time.Hour,
+	"features.signup":               true,
+	"features.webhooks":             true,
+	"invitations.batch_rate_limit":  10,
+	"invitations.batch_rate_window": time.Minute,
+	"mail.smtp_addr":                "",
+	"mail.from":                     "",
+	"mail.username":                 "",
+	"mail.password":                 "",
+	"mail.verify_url":               "",
+	"tracing.exporter":              "none",
+	"tracing.endpoint":              "localhost:4318",
+	"tracing.insecure":              false,
+	"tracing.sample_ratio":          1.0,
+}
 
-	// Enable VIPER to read environment variables for configuration
-	viper.AutomaticEnv()
+// Load builds the configuration from defaults, the config file, ORGAPI_* environment
+// variables and command-line flags, each layer overriding the previous one.
+// Every key can be set with a flag of the same name, e.g. --mongo.uri.
+func Load(args []string) (*Config, Options, error) {
+	var opts Options
+	v := viper.New()
 
-	// Read the configuration file
-	err := viper.ReadInConfig()
-	if err != nil {
-		fmt.Println(err)
+	flags := pflag.NewFlagSet("organization_api", pflag.ContinueOnError)
+	flags.StringVar(&opts.ConfigFile, "config", "", "path to the YAML configuration file (env "+EnvPrefix+"_CONFIG)")
+	flags.BoolVar(&opts.PrintConfig, "print-config", false, "print the effective configuration with secrets redacted and exit")
+
+	for _, key := range sortedKeys() {
+		v.SetDefault(key, defaults[key])
+		flags.String(key, "", fmt.Sprintf("override %s (env %s)", key, envName(key)))
+		if err := v.BindPFlag(key, flags.Lookup(key)); err != nil {
+			return nil, opts, err
+		}
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return nil, opts, err
+	}
+
+	// Environment variables use the key with dots replaced, e.g. ORGAPI_JWT_SECRET.
+	v.SetEnvPrefix(EnvPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+
+	// An explicitly named file must exist; the default file is optional.
+	if opts.ConfigFile == "" {
+		opts.ConfigFile = os.Getenv(EnvPrefix + "_CONFIG")
+	}
+	if opts.ConfigFile != "" {
+		v.SetConfigFile(opts.ConfigFile)
+		if err := v.ReadInConfig(); err != nil {
+			return nil, opts, fmt.Errorf("reading config file %s: %w", opts.ConfigFile, err)
+		}
+	} else {
+		v.SetConfigFile(DefaultConfigFile)
+		if err := v.ReadInConfig(); err != nil {
+			slog.Info("config: default file not loaded, using defaults and environment", "file", DefaultConfigFile, "error", err)
+		} else {
+			opts.ConfigFile = DefaultConfigFile
+		}
+	}
+
+	// Unmarshal the configuration into the Config struct
+	var cfg Config
+	if err := v.Unmarshal(&cfg); err != nil {
+		return nil, opts, fmt.Errorf("decoding config: %w", err)
 	}
 
-	// Unmarshal the configuration into the DbConfig struct
-	err = viper.Unmarshal(&config)
-	if err != nil {
-		log.Fatalf("Unable to decode into struct: %v", err)
+	return &cfg, opts, nil
+}
+
+// Validate reports every invalid setting at once.
+func (cfg *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
 	}
 
-	// Return the populated DbConfig and any error encountered
-	return config, err
+	check(cfg.App.Mode == "debug" || cfg.App.Mode == "release" || cfg.App.Mode == "test",
+		"app.mode must be one of debug, release or test, got %q", cfg.App.Mode)
+	var level slog.Level
+	check(level.UnmarshalText([]byte(cfg.App.LogLevel)) == nil,
+		"app.log_level must be one of debug, info, warn or error, got %q", cfg.App.LogLevel)
+	check(cfg.App.LogFormat == "json" || cfg.App.LogFormat == "text",
+		"app.log_format must be json or text, got %q", cfg.App.LogFormat)
+	check(cfg.HTTP.Addr != "", "http.addr is required")
+	check(cfg.HTTP.ReadTimeout > 0, "http.read_timeout must be positive")
+	check(cfg.HTTP.ReadHeaderTimeout > 0, "http.read_header_timeout must be positive")
+	check(cfg.HTTP.WriteTimeout > 0, "http.write_timeout must be positive")
+	check(cfg.HTTP.IdleTimeout > 0, "http.idle_timeout must be positive")
+	check(cfg.HTTP.MaxHeaderBytes > 0, "http.max_header_bytes must be positive")
+	check(cfg.HTTP.ShutdownTimeout > 0, "http.shutdown_timeout must be positive")
+
+	check(strings.HasPrefix(cfg.Mongo.URI, "mongodb://") || strings.HasPrefix(cfg.Mongo.URI, "mongodb+srv://"),
+		"mongo.uri must start with mongodb:// or mongodb+srv://")
+	check(cfg.Mongo.DbName != "", "mongo.database is required")
+	check(cfg.Mongo.Timeouts.Connect > 0, "mongo.timeouts.connect must be positive")
+	check(cfg.Mongo.Timeouts.Read > 0, "mongo.timeouts.read must be positive")
+	check(cfg.Mongo.Timeouts.Write > 0, "mongo.timeouts.write must be positive")
+
+	check(cfg.Redis.Addr != "", "redis.addr is required")
+	check(cfg.Redis.DB >= 0, "redis.db must not be negative")
+
+	check(len(cfg.JWT.Secret) >= 8, "jwt.secret must be at least 8 characters")
+	check(cfg.JWT.AccessTokenExpiry > 0, "jwt.access_token_expiry must be positive")
+	check(cfg.JWT.RefreshTokenExpiry > cfg.JWT.AccessTokenExpiry,
+		"jwt.refresh_token_expiry must be longer than jwt.access_token_expiry")
+
+	check(cfg.Invitations.BatchRateLimit > 0, "invitations.batch_rate_limit must be positive")
+	check(cfg.Invitations.BatchRateWindow >= time.Second, "invitations.batch_rate_window must be at least 1s")
+
+	check(cfg.Mail.SMTPAddr == "" || cfg.Mail.From != "", "mail.from is required when mail.smtp_addr is set")
+	check(cfg.Mail.VerifyURL == "" || strings.HasPrefix(cfg.Mail.VerifyURL, "https://") || strings.HasPrefix(cfg.Mail.VerifyURL, "http://"),
+		"mail.verify_url must be an http or https URL")
+
+	check(cfg.Tracing.Exporter == "none" || cfg.Tracing.Exporter == "stdout" || cfg.Tracing.Exporter == "otlp",
+		"tracing.exporter must be one of none, stdout or otlp, got %q", cfg.Tracing.Exporter)
+	check(cfg.Tracing.Exporter != "otlp" || cfg.Tracing.Endpoint != "", "tracing.endpoint is required for the otlp exporter")
+	check(cfg.Tracing.SampleRatio >= 0 && cfg.Tracing.SampleRatio <= 1, "tracing.sample_ratio must be between 0 and 1")
+
+	return errors.Join(errs...)
+}
+
+func sortedKeys() []string {
+	keys := make([]string, 0, len(defaults))
+	for key := range defaults {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func envName(key string) string {
+	return EnvPrefix + "_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
 }
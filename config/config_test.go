@@ -0,0 +1,108 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfigFile(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	return path
+}
+
+func TestLoadLayering(t *testing.T) {
+	path := writeConfigFile(t, `
+http:
+  addr: ":7000"
+mongo:
+  uri: mongodb://file:27017/
+  database: from_file
+jwt:
+  secret: file-secret
+`)
+	t.Setenv("ORGAPI_MONGO_DATABASE", "from_env")
+	t.Setenv("ORGAPI_JWT_SECRET", "env-secret")
+
+	cfg, opts, err := Load([]string{"--config", path, "--jwt.secret", "flag-secret", "--mongo.timeouts.read=2s"})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if opts.ConfigFile != path {
+		t.Errorf("ConfigFile = %q, want %q", opts.ConfigFile, path)
+	}
+	if cfg.HTTP.Addr != ":7000" {
+		t.Errorf("file should override defaults: http.addr = %q", cfg.HTTP.Addr)
+	}
+	if cfg.Mongo.DbName != "from_env" {
+		t.Errorf("env should override file: mongo.database = %q", cfg.Mongo.DbName)
+	}
+	if cfg.JWT.Secret != "flag-secret" {
+		t.Errorf("flag should override env: jwt.secret = %q", cfg.JWT.Secret)
+	}
+	if cfg.Mongo.Timeouts.Read != 2*time.Second || cfg.Mongo.Timeouts.Write != 10*time.Second {
+		t.Errorf("unexpected timeouts: %+v", cfg.Mongo.Timeouts)
+	}
+	if cfg.JWT.RefreshTokenExpiry != 72*time.Hour || !cfg.Features.Signup {
+		t.Errorf("defaults not applied: %+v %+v", cfg.JWT, cfg.Features)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+}
+
+func TestLoadMissingExplicitFile(t *testing.T) {
+	if _, _, err := Load([]string{"--config", filepath.Join(t.TempDir(), "missing.yaml")}); err == nil {
+		t.Fatal("expected an error for a missing config file")
+	}
+}
+
+func TestValidateReportsAllProblems(t *testing.T) {
+	cfg := Config{App: AppConfig{Mode: "debug"}, HTTP: HTTPConfig{Addr: ":8080"}, Redis: RedisConfig{Addr: "localhost:6379"}}
+	cfg.Mongo.URI = "postgres://nope"
+	cfg.JWT.AccessTokenExpiry = time.Hour
+	cfg.JWT.RefreshTokenExpiry = time.Minute
+	cfg.Mail.SMTPAddr = "smtp.example.com:587"
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+	for _, want := range []string{"http.read_timeout", "http.shutdown_timeout", "mongo.uri", "mongo.database", "mongo.timeouts.read", "jwt.secret", "jwt.refresh_token_expiry", "mail.from"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error to mention %s, got:\n%v", want, err)
+		}
+	}
+}
+
+func TestPrintRedactsSecrets(t *testing.T) {
+	cfg := Config{}
+	cfg.Mongo.URI = "mongodb://admin:hunter2@db:27017/"
+	cfg.Redis.Password = "redis-pass"
+	cfg.JWT.Secret = "jwt-secret"
+	cfg.Mail.Password = "smtp-pass"
+
+	var out bytes.Buffer
+	if err := cfg.Print(&out); err != nil {
+		t.Fatalf("Print: %v", err)
+	}
+	for _, secret := range []string{"hunter2", "redis-pass", "jwt-secret", "smtp-pass"} {
+		if strings.Contains(out.String(), secret) {
+			t.Errorf("printed config leaks %q:\n%s", secret, out.String())
+		}
+	}
+	if !strings.Contains(out.String(), "mongodb://admin:REDACTED@db:27017/") {
+		t.Errorf("expected redacted mongo uri, got:\n%s", out.String())
+	}
+	if cfg.JWT.Secret != "jwt-secret" {
+		t.Error("Print must not modify the original configuration")
+	}
+}
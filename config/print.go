@@ -0,0 +1,47 @@
+package config
+
+import (
+	"io"
+	"net/url"
+
+	"gopkg.in/yaml.v3"
+)
+
+const redacted = "REDACTED"
+
+// Redacted returns a copy of the configuration with secrets masked.
+func (cfg Config) Redacted() Config {
+	if cfg.JWT.Secret != "" {
+		cfg.JWT.Secret = redacted
+	}
+	if cfg.Redis.Password != "" {
+		cfg.Redis.Password = redacted
+	}
+	if cfg.Mail.Password != "" {
+		cfg.Mail.Password = redacted
+	}
+	cfg.Mongo.URI = redactURI(cfg.Mongo.URI)
+	return cfg
+}
+
+// Print writes the configuration as YAML with secrets masked.
+func (cfg Config) Print(w io.Writer) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(cfg.Redacted()); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// redactURI masks the password embedded in a connection string.
+func redactURI(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return redacted
+	}
+	if _, hasPassword := u.User.Password(); hasPassword {
+		u.User = url.UserPassword(u.User.Username(), redacted)
+	}
+	return u.String()
+}
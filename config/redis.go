@@ -3,11 +3,11 @@ package config
 import "github.com/go-redis/redis"
 
 // method to initialize redis
-func Init_redis() *redis.Client {
+func Init_redis(cfg RedisConfig) *redis.Client {
 	redisClient := redis.NewClient(&redis.Options{
-		Addr:     "localhost:6379",
-		Password: "",
-		DB:       0,
+		Addr:     cfg.Addr,
+		Password: cfg.Password,
+		DB:       cfg.DB,
 	})
 	return redisClient
 }
@@ -0,0 +1,222 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"gopkg.in/yaml.v3"
+)
+
+// reloadable lists the keys that take effect without a restart.
+// Changes to any other key are reported and ignored until the next restart.
+var reloadable = map[string]bool{
+	"app.log_level":                 true,
+	"jwt.access_token_expiry":       true,
+	"jwt.refresh_token_expiry":      true,
+	"invitations.batch_rate_limit":  true,
+	"invitations.batch_rate_window": true,
+}
+
+// Watcher holds the live configuration and reloads it when the config file changes or the process receives SIGHUP.
+type Watcher struct {
+	args    []string
+	file    string
+	current atomic.Pointer[Config]
+
+	mu          sync.Mutex
+	subscribers []func(old, next *Config)
+}
+
+// NewWatcher starts from an already loaded configuration; args and opts must be the ones it was loaded with.
+func NewWatcher(cfg *Config, opts Options, args []string) *Watcher {
+	w := &Watcher{args: args, file: opts.ConfigFile}
+	w.current.Store(cfg)
+	return w
+}
+
+// Current returns the configuration in effect. The returned value must not be modified.
+func (w *Watcher) Current() *Config {
+	return w.current.Load()
+}
+
+// OnChange registers fn to be called after every successful reload that changed a setting.
+func (w *Watcher) OnChange(fn func(old, next *Config)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.subscribers = append(w.subscribers, fn)
+}
+
+// Reload reads every configuration layer again, validates the result and swaps in the reloadable settings.
+// The configuration in effect is left untouched when the new one fails to load or validate.
+// Subscribers run after the lock is released, so they may call back into the watcher.
+func (w *Watcher) Reload() error {
+	old, next, err := w.reload()
+	if err != nil || next == nil {
+		return err
+	}
+
+	w.mu.Lock()
+	subscribers := append([]func(old, next *Config){}, w.subscribers...)
+	w.mu.Unlock()
+
+	for _, fn := range subscribers {
+		fn(old, next)
+	}
+	return nil
+}
+
+// reload swaps in the reloadable settings and returns the previous and new configuration,
+// or a nil next when nothing reloadable changed.
+func (w *Watcher) reload() (*Config, *Config, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	loaded, _, err := Load(w.args)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := loaded.Validate(); err != nil {
+		return nil, nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	old := w.current.Load()
+	next := *old
+	applied := 0
+	for _, change := range Diff(old, loaded) {
+		if !reloadable[change.Key] {
+			slog.Warn("config: change requires a restart, ignoring", "key", change.Key, "old", change.Old, "new", change.New)
+			continue
+		}
+		slog.Info("config: setting changed", "key", change.Key, "old", change.Old, "new", change.New)
+		applied++
+	}
+	if applied == 0 {
+		return old, nil, nil
+	}
+
+	next.App.LogLevel = loaded.App.LogLevel
+	next.JWT.AccessTokenExpiry = loaded.JWT.AccessTokenExpiry
+	next.JWT.RefreshTokenExpiry = loaded.JWT.RefreshTokenExpiry
+	next.Invitations = loaded.Invitations
+	w.current.Store(&next)
+	return old, &next, nil
+}
+
+// Watch reloads on SIGHUP and on writes to the config file until ctx is cancelled.
+func (w *Watcher) Watch(ctx context.Context) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	var fileEvents <-chan fsnotify.Event
+	if w.file != "" {
+		watcher, err := fsnotify.NewWatcher()
+		if err != nil {
+			slog.Error("config: file watching disabled", "error", err)
+		} else {
+			defer watcher.Close()
+			// Watch the directory so editors that replace the file are still noticed.
+			if err := watcher.Add(filepath.Dir(w.file)); err != nil {
+				slog.Error("config: file watching disabled", "error", err)
+			} else {
+				fileEvents = watcher.Events
+			}
+		}
+	}
+
+	// Editors emit several events per save; wait for them to settle before reloading.
+	var debounce <-chan time.Time
+	target := filepath.Clean(w.file)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+			w.reloadAndLog("SIGHUP")
+		case event, ok := <-fileEvents:
+			if !ok {
+				fileEvents = nil
+				continue
+			}
+			if filepath.Clean(event.Name) == target && event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) != 0 {
+				debounce = time.After(100 * time.Millisecond)
+			}
+		case <-debounce:
+			debounce = nil
+			w.reloadAndLog("file change")
+		}
+	}
+}
+
+func (w *Watcher) reloadAndLog(trigger string) {
+	if err := w.Reload(); err != nil {
+		slog.Error("config: reload failed, keeping current configuration", "trigger", trigger, "error", err)
+	}
+}
+
+// Change is a single setting that differs between two configurations. Secrets are redacted.
+type Change struct {
+	Key string
+	Old string
+	New string
+}
+
+// Diff lists the settings that differ between two configurations, sorted by key.
+func Diff(old, next *Config) []Change {
+	oldRaw, nextRaw := flatten(*old), flatten(*next)
+	oldShown, nextShown := flatten(old.Redacted()), flatten(next.Redacted())
+
+	var changes []Change
+	for key, value := range nextRaw {
+		if oldRaw[key] == value {
+			continue
+		}
+		change := Change{Key: key, Old: oldShown[key], New: nextShown[key]}
+		if change.Old == change.New {
+			change.New = change.New + " (secret changed)"
+		}
+		changes = append(changes, change)
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	return changes
+}
+
+// flatten renders a configuration as dotted keys mapped to their string values.
+func flatten(cfg Config) map[string]string {
+	out := map[string]string{}
+	raw, err := yaml.Marshal(cfg)
+	if err != nil {
+		return out
+	}
+	var tree map[string]interface{}
+	if err := yaml.Unmarshal(raw, &tree); err != nil {
+		return out
+	}
+
+	var walk func(prefix string, node interface{})
+	walk = func(prefix string, node interface{}) {
+		if children, ok := node.(map[string]interface{}); ok {
+			for key, child := range children {
+				if prefix != "" {
+					key = prefix + "." + key
+				}
+				walk(key, child)
+			}
+			return
+		}
+		out[prefix] = fmt.Sprint(node)
+	}
+	walk("", tree)
+	return out
+}
@@ -0,0 +1,129 @@
+package config
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+)
+
+const baseConfig = `
+mongo:
+  uri: mongodb://localhost:27017/
+  database: organization_db
+jwt:
+  secret: first-secret
+  access_token_expiry: 1h
+`
+
+func newTestWatcher(t *testing.T, contents string) (*Watcher, string) {
+	t.Helper()
+	path := writeConfigFile(t, contents)
+	args := []string{"--config", path}
+
+	cfg, opts, err := Load(args)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	return NewWatcher(cfg, opts, args), path
+}
+
+func TestReloadAppliesReloadableSettings(t *testing.T) {
+	w, path := newTestWatcher(t, baseConfig)
+
+	var notified *Config
+	w.OnChange(func(old, next *Config) { notified = next })
+
+	updated := baseConfig + "  refresh_token_expiry: 24h\nhttp:\n  addr: \":9999\"\napp:\n  log_level: debug\ninvitations:\n  batch_rate_limit: 3\n"
+	if err := os.WriteFile(path, []byte(updated), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+
+	cfg := w.Current()
+	if cfg.JWT.RefreshTokenExpiry != 24*time.Hour || cfg.App.LogLevel != "debug" || cfg.Invitations.BatchRateLimit != 3 {
+		t.Errorf("reloadable settings not applied: %+v %+v %+v", cfg.JWT, cfg.App, cfg.Invitations)
+	}
+	if cfg.HTTP.Addr != ":8080" {
+		t.Errorf("http.addr requires a restart but changed to %q", cfg.HTTP.Addr)
+	}
+	if notified != cfg {
+		t.Error("subscribers were not notified with the new configuration")
+	}
+}
+
+func TestReloadSubscribersMayUseWatcher(t *testing.T) {
+	w, path := newTestWatcher(t, baseConfig)
+
+	done := make(chan struct{})
+	w.OnChange(func(old, next *Config) {
+		w.OnChange(func(old, next *Config) {})
+		close(done)
+	})
+
+	if err := os.WriteFile(path, []byte(baseConfig+"  refresh_token_expiry: 24h\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	go w.Reload()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("subscriber deadlocked registering with the watcher")
+	}
+}
+
+func TestReloadKeepsConfigurationWhenInvalid(t *testing.T) {
+	w, path := newTestWatcher(t, baseConfig)
+	before := w.Current()
+
+	if err := os.WriteFile(path, []byte(baseConfig+"  refresh_token_expiry: 1m\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Reload(); err == nil {
+		t.Fatal("expected a validation error")
+	}
+	if w.Current() != before {
+		t.Error("invalid configuration must not be swapped in")
+	}
+}
+
+func TestWatchReloadsOnFileChange(t *testing.T) {
+	w, path := newTestWatcher(t, baseConfig)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.Watch(ctx)
+
+	// Give the watcher a moment to subscribe before editing the file.
+	time.Sleep(100 * time.Millisecond)
+	if err := os.WriteFile(path, []byte(baseConfig+"  refresh_token_expiry: 48h\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		if w.Current().JWT.RefreshTokenExpiry == 48*time.Hour {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("file change was not picked up: %+v", w.Current().JWT)
+}
+
+func TestDiffRedactsSecrets(t *testing.T) {
+	old := &Config{}
+	old.JWT.Secret = "old-secret"
+	next := &Config{}
+	next.JWT.Secret = "new-secret"
+	next.JWT.AccessTokenExpiry = time.Hour
+
+	changes := Diff(old, next)
+	if len(changes) != 2 || changes[0].Key != "jwt.access_token_expiry" || changes[1].Key != "jwt.secret" {
+		t.Fatalf("unexpected changes: %+v", changes)
+	}
+	if changes[1].Old != "REDACTED" || changes[1].New != "REDACTED (secret changed)" {
+		t.Fatalf("secret leaked in diff: %+v", changes[1])
+	}
+}
@@ -1,54 +1,65 @@
 package handlers
 
 import (
+	"errors"
+	"log/slog"
 	"net/http"
 
+	"github.com/organization_api/pkg/api/middleware"
+	"github.com/organization_api/pkg/apperror"
 	"github.com/organization_api/pkg/database/mongodb/models"
-	"github.com/organization_api/pkg/database/mongodb/repository"
+	"github.com/organization_api/pkg/mail"
+	"github.com/organization_api/pkg/metrics"
 	"github.com/organization_api/pkg/utils"
 
 	"github.com/gin-gonic/gin"
 )
 
 // SignInHandler authenticates a user based on their AuthCreds.
-func SignInHandler(c *gin.Context) {
+func (h *Handler) SignInHandler(c *gin.Context) {
 	// Parse the incoming JSON payload containing user AuthCreds.
-	var AuthCreds *models.AuthCreds
-	newrepo := repository.NewUserRepository()
+	var AuthCreds models.AuthCreds
 
-	err := c.ShouldBindJSON(&AuthCreds)
+	err := bindJSON(c, &AuthCreds)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON payload"})
+		c.Error(err)
 		return
 	}
 
-	// Check that both username and password are provided.
-	if AuthCreds.Email == "" || AuthCreds.Password == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Username and password cannot be empty"})
+	// Find the user by email in the database.
+	userFound, err := h.Users.FindUserByEmail(c.Request.Context(), AuthCreds.Email)
+	if errors.Is(err, apperror.ErrNotFound) {
+		metrics.SignIns.WithLabelValues(metrics.ResultFailure).Inc()
+		c.Error(apperror.Unauthorized("Invalid credentials"))
 		return
 	}
-
-	// Find the user by email in the database.
-	userFound, err := newrepo.FindUserByEmail(AuthCreds.Email)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid AuthCreds"})
+		c.Error(err)
 		return
 	}
 
 	// Verify the provided password against the stored hash.
 	isMatch, err := utils.CheckPasswordHash(AuthCreds.Password, userFound.Password)
 	if err != nil || !isMatch {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid AuthCreds"})
+		metrics.SignIns.WithLabelValues(metrics.ResultFailure).Inc()
+		c.Error(apperror.Unauthorized("Invalid credentials"))
+		return
+	}
+	if userFound.Disabled {
+		metrics.SignIns.WithLabelValues(metrics.ResultFailure).Inc()
+		c.Error(apperror.Forbidden("Account is disabled"))
 		return
 	}
 
 	// Generate authentication tokens for the authenticated user.
 	access_token, refresh_token, err := utils.GenerateTokens(userFound.Name, userFound.Email)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate tokens"})
+		c.Error(apperror.Internal("Failed to generate tokens").Wrap(err))
 		return
 	}
 
+	metrics.SignIns.WithLabelValues(metrics.ResultSuccess).Inc()
+
 	// Respond with success message and tokens.
 	c.JSON(http.StatusOK, models.AuthResponse{
 		Message:      "SignIn successful",
@@ -58,42 +69,52 @@ func SignInHandler(c *gin.Context) {
 }
 
 // SignupHandler handles the creation of a new user account.
-func SignupHandler(c *gin.Context) {
+func (h *Handler) SignupHandler(c *gin.Context) {
+	if !h.Features.Signup {
+		c.Error(apperror.Forbidden("Sign-up is disabled"))
+		return
+	}
+
 	// Parse and validate the incoming JSON payload.
 	var user models.User
-	repo := repository.NewUserRepository()
 
-	err := c.ShouldBindJSON(&user)
+	err := bindJSON(c, &user)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON payload"})
-		return
-	}
-
-	// Validate the user data before proceeding.
-	if err := utils.ValidateUser(user); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
 	// Hash the user's password for secure storage.
 	hash, err := utils.HashPassword(user.Password)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		c.Error(apperror.Internal("Failed to hash password").Wrap(err))
 		return
 	}
 	user.Password = hash
 
 	// Attempt to create the user in the database.
-	createdUser, err := repo.CreateUser(&user)
+	createdUser, err := h.Users.CreateUser(c.Request.Context(), &user)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "User not created"})
+		c.Error(err)
 		return
 	}
 
+	// Invitations that waited for this account become memberships, unless they lapsed. The account
+	// exists either way, so a failure here is only logged.
+	if err := h.Organizations.AcceptInvitations(c.Request.Context(), createdUser.Email); err != nil {
+		slog.ErrorContext(c.Request.Context(), "signup: failed to accept invitations", "error", err)
+	}
+
+	// Ask the user to confirm their address. Until they do, they cannot join by email domain; they
+	// can ask for another email later, so a failure here is only logged.
+	if err := h.sendVerification(c.Request.Context(), createdUser.Email); err != nil && !errors.Is(err, mail.ErrNotConfigured) {
+		slog.ErrorContext(c.Request.Context(), "signup: failed to send the confirmation email", "error", err)
+	}
+
 	// Generate authentication tokens for the newly created user.
 	access_token, refresh_token, err := utils.GenerateTokens(createdUser.Name, createdUser.Email)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate tokens"})
+		c.Error(apperror.Internal("Failed to generate tokens").Wrap(err))
 		return
 	}
 
@@ -106,29 +127,54 @@ func SignupHandler(c *gin.Context) {
 }
 
 // RefreshTokenHandler issues new access and refresh tokens using a valid refresh token.
-func RefreshTokenHandler(c *gin.Context) {
+func (h *Handler) RefreshTokenHandler(c *gin.Context) {
 	// Parse the incoming JSON payload containing the refresh token.
 	var request models.RefreshToken
 
-	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON payload"})
+	if err := bindJSON(c, &request); err != nil {
+		c.Error(err)
 		return
 	}
 
 	// Verify the refresh token and extract the associated username and email.
-	username, email, err := utils.VerifyRefreshToken(request.Token)
+	claims, err := utils.ValidateToken(request.Token)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		metrics.TokenRefreshes.WithLabelValues(metrics.ResultFailure).Inc()
+		c.Error(apperror.Unauthorized("Invalid refresh token").Wrap(err))
+		return
+	}
+	if claims.Type != utils.TokenTypeRefresh {
+		metrics.TokenRefreshes.WithLabelValues(metrics.ResultFailure).Inc()
+		c.Error(apperror.Unauthorized("Invalid refresh token"))
+		return
+	}
+
+	// Refuse revoked tokens and accounts that were disabled or removed since the token was issued.
+	if err := middleware.CheckRevocation(c.Request.Context(), h.Tokens, claims); err != nil {
+		metrics.TokenRefreshes.WithLabelValues(metrics.ResultFailure).Inc()
+		c.Error(err)
+		return
+	}
+	user, err := h.Users.FindUserByEmail(c.Request.Context(), claims.Email)
+	if errors.Is(err, apperror.ErrNotFound) || (err == nil && user.Disabled) {
+		metrics.TokenRefreshes.WithLabelValues(metrics.ResultFailure).Inc()
+		c.Error(apperror.Unauthorized("Invalid refresh token"))
+		return
+	}
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
 	// Generate new access and refresh tokens for the user.
-	accessToken, refreshToken, err := utils.GenerateTokens(username, email)
+	accessToken, refreshToken, err := utils.GenerateTokens(claims.Username, claims.Email)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate tokens"})
+		c.Error(apperror.Internal("Failed to generate tokens").Wrap(err))
 		return
 	}
 
+	metrics.TokenRefreshes.WithLabelValues(metrics.ResultSuccess).Inc()
+
 	// Prepare the response with the new tokens.
 	response := models.AuthResponse{
 		Message:      "Tokens refreshed",
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"context"
+	"sync"
+
+	"github.com/organization_api/pkg/apperror"
+)
+
+// backgroundJobs runs work that outlives the request that started it, such as large imports.
+type backgroundJobs struct {
+	mu      sync.Mutex
+	closed  bool
+	stop    context.Context
+	stopNow context.CancelFunc
+	running sync.WaitGroup
+}
+
+func newBackgroundJobs() *backgroundJobs {
+	jobs := &backgroundJobs{}
+	jobs.stop, jobs.stopNow = context.WithCancel(context.Background())
+	return jobs
+}
+
+// start runs work in the background. Its context keeps the values of ctx, such as the trace, but
+// is cancelled only when shutdown gives up waiting.
+func (jobs *backgroundJobs) start(ctx context.Context, work func(ctx context.Context)) error {
+	jobs.mu.Lock()
+	defer jobs.mu.Unlock()
+	if jobs.closed {
+		return apperror.Conflict("The server is shutting down; try again shortly")
+	}
+
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stopCancelling := context.AfterFunc(jobs.stop, cancel)
+	jobs.running.Add(1)
+	go func() {
+		defer jobs.running.Done()
+		defer cancel()
+		defer stopCancelling()
+		work(ctx)
+	}()
+	return nil
+}
+
+// shutdown stops accepting work and waits for running work to finish. When ctx expires first,
+// the remaining work is cancelled and ctx's error is returned once it has stopped.
+func (jobs *backgroundJobs) shutdown(ctx context.Context) error {
+	jobs.mu.Lock()
+	jobs.closed = true
+	jobs.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		jobs.running.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		jobs.stopNow()
+		<-done
+		return ctx.Err()
+	}
+}
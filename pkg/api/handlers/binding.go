@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/organization_api/pkg/apperror"
+	"github.com/organization_api/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// bindJSON decodes the request body into obj and enforces its `validate` tags.
+// Malformed JSON is a bad request; rule violations are reported field by field.
+func bindJSON(c *gin.Context, obj interface{}) error {
+	err := c.ShouldBindJSON(obj)
+	if err == nil {
+		return nil
+	}
+
+	var validationErrors validator.ValidationErrors
+	if errors.As(err, &validationErrors) {
+		return utils.ValidationError(err)
+	}
+	return apperror.BadRequest("Invalid JSON payload").Wrap(err)
+}
+
+// bindStrictJSON is bindJSON for bodies that may only carry the fields obj defines.
+func bindStrictJSON(c *gin.Context, obj interface{}) error {
+	decoder := json.NewDecoder(c.Request.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(obj); err != nil {
+		return apperror.BadRequest("Invalid JSON payload: %s", strings.TrimPrefix(err.Error(), "json: ")).Wrap(err)
+	}
+
+	return utils.ValidationError(utils.Validator().Struct(obj))
+}
+
+// bindQuery decodes the query string into obj and enforces its `validate` tags.
+func bindQuery(c *gin.Context, obj interface{}) error {
+	err := c.ShouldBindQuery(obj)
+	if err == nil {
+		return nil
+	}
+
+	var validationErrors validator.ValidationErrors
+	if errors.As(err, &validationErrors) {
+		return utils.ValidationError(err)
+	}
+	return apperror.BadRequest("Invalid query parameters").Wrap(err)
+}
@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/organization_api/pkg/api/middleware"
+	"github.com/organization_api/pkg/apperror"
+	"github.com/organization_api/pkg/database/mongodb/models"
+	"github.com/organization_api/pkg/dnsverify"
+	"github.com/organization_api/pkg/webhook"
+
+	"github.com/gin-gonic/gin"
+)
+
+// domainClaimView is a claim together with the TXT record that verifies it.
+type domainClaimView struct {
+	*models.DomainClaim
+	RecordName  string `json:"record_name"`
+	RecordValue string `json:"record_value"`
+}
+
+func newDomainClaimView(claim *models.DomainClaim) domainClaimView {
+	return domainClaimView{
+		DomainClaim: claim,
+		RecordName:  dnsverify.RecordName(claim.Domain),
+		RecordValue: dnsverify.RecordValue(claim.Token),
+	}
+}
+
+// CreateDomainClaimHandler claims an email domain for an organization. The claim stays pending
+// until the TXT record in the response is published and verified.
+func (h *Handler) CreateDomainClaimHandler(c *gin.Context) {
+	organizationID := c.Param("organization_id")
+	var request models.DomainClaimRequest
+
+	if err := bindJSON(c, &request); err != nil {
+		c.Error(err)
+		return
+	}
+
+	token, err := dnsverify.NewToken()
+	if err != nil {
+		c.Error(apperror.Internal("Failed to generate a verification token").Wrap(err))
+		return
+	}
+	claim := &models.DomainClaim{
+		OrganizationId: organizationID,
+		Domain:         dnsverify.Normalize(request.Domain),
+		Token:          token,
+		Status:         models.DomainPending,
+		CreatedBy:      c.GetString(middleware.CallerEmailKey),
+		CreatedAt:      time.Now().UTC(),
+	}
+	if _, err := h.Domains.CreateDomainClaim(c.Request.Context(), claim); err != nil {
+		c.Error(err)
+		return
+	}
+
+	// Respond with the claim and the record to publish.
+	c.JSON(http.StatusCreated, newDomainClaimView(claim))
+}
+
+// GetDomainClaimsHandler lists the domains an organization has claimed.
+func (h *Handler) GetDomainClaimsHandler(c *gin.Context) {
+	claims, err := h.Domains.GetDomainClaims(c.Request.Context(), c.Param("organization_id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	views := make([]domainClaimView, 0, len(claims))
+	for _, claim := range claims {
+		views = append(views, newDomainClaimView(claim))
+	}
+	// Respond with the claims.
+	c.JSON(http.StatusOK, views)
+}
+
+// VerifyDomainClaimHandler looks up the TXT record of a pending claim and verifies the claim when
+// the record is published. Verifying a verified claim changes nothing.
+func (h *Handler) VerifyDomainClaimHandler(c *gin.Context) {
+	organizationID := c.Param("organization_id")
+	domain := dnsverify.Normalize(c.Param("domain"))
+
+	claim, err := h.Domains.GetDomainClaim(c.Request.Context(), organizationID, domain)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if claim.Status != models.DomainVerified {
+		found, err := dnsverify.Verify(c.Request.Context(), h.Resolver, domain, claim.Token)
+		if err != nil {
+			c.Error(lookupError(domain, err))
+			return
+		}
+		if !found {
+			c.Error(apperror.Conflict("No TXT record %q found at %s; publish it and try again",
+				dnsverify.RecordValue(claim.Token), dnsverify.RecordName(domain)))
+			return
+		}
+
+		claim, err = h.Domains.VerifyDomainClaim(c.Request.Context(), organizationID, domain, time.Now().UTC())
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}
+
+	// Respond with the verified claim.
+	c.JSON(http.StatusOK, newDomainClaimView(claim))
+}
+
+// DeleteDomainClaimHandler gives up an organization's claim to a domain.
+func (h *Handler) DeleteDomainClaimHandler(c *gin.Context) {
+	organizationID := c.Param("organization_id")
+	domain := dnsverify.Normalize(c.Param("domain"))
+
+	if err := h.Domains.DeleteDomainClaim(c.Request.Context(), organizationID, domain); err != nil {
+		c.Error(err)
+		return
+	}
+
+	// Respond with a success message.
+	c.JSON(http.StatusOK, gin.H{"message": "Domain claim deleted successfully"})
+}
+
+// JoinOrganizationHandler lets the caller join an organization that verified their email's domain
+// and lets people with it join.
+func (h *Handler) JoinOrganizationHandler(c *gin.Context) {
+	organizationID := c.Param("organization_id")
+	caller := c.GetString(middleware.CallerEmailKey)
+
+	match, err := h.domainOrganization(c.Request.Context(), caller)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if match == nil || match.OrganizationId != organizationID {
+		c.Error(apperror.Forbidden("The organization does not let people join with your email domain"))
+		return
+	}
+
+	if _, err := h.joinByDomain(c.Request.Context(), organizationID, caller); err != nil {
+		c.Error(err)
+		return
+	}
+
+	// Respond with a success message.
+	c.JSON(http.StatusOK, gin.H{"message": "Joined organization"})
+}
+
+// domainOrganization finds the organization that verified the domain of email, if it lets people
+// with the domain join. It returns nil when there is none.
+func (h *Handler) domainOrganization(ctx context.Context, email string) (*models.DomainMatch, error) {
+	claim, err := h.Domains.FindVerifiedDomainClaim(ctx, dnsverify.EmailDomain(email))
+	if errors.Is(err, apperror.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	settings, err := h.Settings.GetSettings(ctx, claim.OrganizationId)
+	if err != nil {
+		return nil, err
+	}
+	if settings.DomainJoin == models.DomainJoinOff {
+		return nil, nil
+	}
+
+	organization, err := h.Organizations.GetOrganizationById(ctx, claim.OrganizationId)
+	if err != nil {
+		return nil, err
+	}
+	return &models.DomainMatch{
+		OrganizationId: claim.OrganizationId,
+		Name:           organization.Name,
+		Joined:         settings.DomainJoin == models.DomainJoinAuto,
+	}, nil
+}
+
+// joinByDomain makes email a member of the organization. Only users who confirmed they receive mail
+// at the address may join by its domain. People joining by domain are always plain members, whatever
+// role invitations default to. It reports whether email was not a member yet.
+func (h *Handler) joinByDomain(ctx context.Context, organizationID, email string) (bool, error) {
+	user, err := h.Users.FindUserByEmail(ctx, email)
+	if err != nil {
+		return false, err
+	}
+	if !user.EmailVerified {
+		return false, apperror.Forbidden("Confirm your email address before joining by its domain")
+	}
+
+	invited, err := h.Organizations.InviteUsersToOrganization(ctx, organizationID, []string{email}, models.InviteOptions{})
+	if err != nil {
+		return false, err
+	}
+	if len(invited) == 0 {
+		return false, nil
+	}
+	webhook.Publish(ctx, organizationID, webhook.EventMemberInvited, gin.H{"user_email": email})
+	return true, nil
+}
+
+// lookupError reports a failed TXT lookup: a timeout when the DNS server may answer if asked again,
+// an internal error otherwise.
+func lookupError(domain string, err error) error {
+	var dnsErr *net.DNSError
+	if errors.Is(err, context.DeadlineExceeded) || errors.As(err, &dnsErr) && (dnsErr.IsTimeout || dnsErr.IsTemporary) {
+		return apperror.Timeout("DNS lookup for %s timed out; try again", domain).Wrap(err)
+	}
+	return apperror.Internal("DNS lookup for %s failed", domain).Wrap(err)
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/organization_api/config"
+	"github.com/organization_api/pkg/database/mongodb/repository"
+	"github.com/organization_api/pkg/dnsverify"
+	"github.com/organization_api/pkg/health"
+	"github.com/organization_api/pkg/mail"
+	"github.com/organization_api/pkg/webhook"
+)
+
+// Handler serves the API routes using the repositories it was constructed with.
+type Handler struct {
+	Organizations repository.OrganizationStore
+	Users         repository.UserStore
+	Webhooks      repository.WebhookStore
+	Dispatcher    *webhook.Dispatcher
+	Teams         repository.TeamStore
+	Features      config.FeatureConfig
+	Health        *health.Registry
+	Tokens        repository.TokenRevocationStore
+	ImportJobs    repository.ImportJobStore
+	Settings      repository.SettingsStore
+	Domains       repository.DomainStore
+	Resolver      dnsverify.Resolver
+	RateLimits    repository.RateLimitStore
+	Mailer        mail.Sender
+	VerifyURL     string
+
+	// invitations holds the batch invitation limits, swapped atomically when the configuration is reloaded.
+	invitations atomic.Pointer[config.InvitationConfig]
+	background  *backgroundJobs
+}
+
+// NewHandler initializes a Handler with every store it uses, so none can be left to a default.
+func NewHandler(
+	organizations repository.OrganizationStore,
+	users repository.UserStore,
+	webhooks repository.WebhookStore,
+	teams repository.TeamStore,
+	importJobs repository.ImportJobStore,
+	settings repository.SettingsStore,
+	domains repository.DomainStore,
+	tokens repository.TokenRevocationStore,
+	rateLimits repository.RateLimitStore,
+) *Handler {
+	h := &Handler{
+		Organizations: organizations,
+		Users:         users,
+		Webhooks:      webhooks,
+		Dispatcher:    webhook.NewDispatcher(webhooks),
+		Teams:         teams,
+		ImportJobs:    importJobs,
+		Settings:      settings,
+		Domains:       domains,
+		Tokens:        tokens,
+		RateLimits:    rateLimits,
+		Features:      config.FeatureConfig{Signup: true, Webhooks: true},
+		Health:        health.NewRegistry(),
+		Resolver:      net.DefaultResolver,
+		Mailer:        mail.Disabled{},
+		background:    newBackgroundJobs(),
+	}
+	h.ConfigureInvitations(config.InvitationConfig{BatchRateLimit: 10, BatchRateWindow: time.Minute})
+	return h
+}
+
+// ConfigureInvitations sets the batch invitation limits; it is safe to call while requests are served.
+func (h *Handler) ConfigureInvitations(cfg config.InvitationConfig) {
+	h.invitations.Store(&cfg)
+}
+
+// Shutdown stops starting background jobs and waits for running ones, such as imports, to finish.
+// When ctx expires first, the remaining jobs are cancelled.
+func (h *Handler) Shutdown(ctx context.Context) error {
+	return h.background.shutdown(ctx)
+}
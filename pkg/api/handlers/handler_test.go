@@ -0,0 +1,1687 @@
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/organization_api/config"
+	"github.com/organization_api/pkg/api/handlers"
+	"github.com/organization_api/pkg/api/routes"
+	"github.com/organization_api/pkg/apperror"
+	"github.com/organization_api/pkg/database/mongodb/models"
+	"github.com/organization_api/pkg/database/mongodb/repository"
+	"github.com/organization_api/pkg/dnsverify"
+	"github.com/organization_api/pkg/mail"
+	"github.com/organization_api/pkg/metrics"
+	"github.com/organization_api/pkg/utils"
+	"github.com/organization_api/pkg/webhook"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func newTestRouter(t *testing.T) (*gin.Engine, *handlers.Handler) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	h := newMemoryHandler()
+	router := gin.New()
+	routes.Routes(router, h)
+	return router, h
+}
+
+// newMemoryHandler builds a Handler on the in-memory stores.
+func newMemoryHandler() *handlers.Handler {
+	return handlers.NewHandler(
+		repository.NewMemoryOrganizationRepo(),
+		repository.NewMemoryUserRepository(),
+		repository.NewMemoryWebhookRepo(),
+		repository.NewMemoryTeamRepo(),
+		repository.NewMemoryImportJobRepo(),
+		repository.NewMemorySettingsRepo(),
+		repository.NewMemoryDomainRepo(),
+		repository.NewMemoryTokenRevocations(),
+		repository.NewMemoryRateLimits(),
+	)
+}
+
+func doJSON(t *testing.T, router *gin.Engine, method, path, token string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			t.Fatalf("encode body: %v", err)
+		}
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func tokenFor(t *testing.T, email string) string {
+	t.Helper()
+	access, _, err := utils.GenerateTokens("tester", email)
+	if err != nil {
+		t.Fatalf("GenerateTokens: %v", err)
+	}
+	return access
+}
+
+func TestSignupAndSignIn(t *testing.T) {
+	router, _ := newTestRouter(t)
+
+	rec := doJSON(t, router, http.MethodPost, "/auth/signup", "", gin.H{"name": "Ada", "email": "ada@example.com", "password": "password123"})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("signup: expected 201, got %d: %s", rec.Code, rec.Body)
+	}
+
+	rec = doJSON(t, router, http.MethodPost, "/auth/signup", "", gin.H{"name": "Ada", "email": "ada@example.com", "password": "password123"})
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("duplicate signup: expected 409, got %d: %s", rec.Code, rec.Body)
+	}
+
+	rec = doJSON(t, router, http.MethodPost, "/auth/signin", "", gin.H{"email": "ada@example.com", "password": "password123"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("signin: expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+
+	rec = doJSON(t, router, http.MethodPost, "/auth/signin", "", gin.H{"email": "ada@example.com", "password": "wrong-password"})
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("signin with wrong password: expected 401, got %d", rec.Code)
+	}
+
+	rec = doJSON(t, router, http.MethodPost, "/auth/signin", "", gin.H{"email": "nobody@example.com", "password": "password123"})
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("signin with unknown email: expected 401, got %d", rec.Code)
+	}
+}
+
+func TestOrganizationLifecycle(t *testing.T) {
+	ctx := context.Background()
+	router, h := newTestRouter(t)
+	token := tokenFor(t, "ada@example.com")
+
+	rec := doJSON(t, router, http.MethodPost, "/api/organization", token, gin.H{"name": "Acme", "description": "Widgets"})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create: expected 201, got %d: %s", rec.Code, rec.Body)
+	}
+	var created struct {
+		OrganizationID string `json:"organization_id"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &created)
+	path := "/api/organization/" + created.OrganizationID
+
+	// The creator owns the organization and can read it straight away.
+	rec = doJSON(t, router, http.MethodGet, path, token, nil)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"owner":"ada@example.com"`) {
+		t.Fatalf("get as owner: expected 200 with owner, got %d: %s", rec.Code, rec.Body)
+	}
+
+	// Anyone else needs an invitation.
+	bob := tokenFor(t, "bob@example.com")
+	rec = doJSON(t, router, http.MethodGet, path, bob, nil)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("get before invite: expected 403, got %d", rec.Code)
+	}
+
+	rec = doJSON(t, router, http.MethodPost, path+"/invite", token, gin.H{"user_email": "bob@example.com"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("invite: expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+
+	rec = doJSON(t, router, http.MethodGet, path, bob, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("get after invite: expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+
+	rec = doJSON(t, router, http.MethodPut, path, token, gin.H{"name": "Acme Corp", "description": "Gadgets"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("update: expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	org, _ := h.Organizations.GetOrganizationById(ctx, created.OrganizationID)
+	if org.Name != "Acme Corp" {
+		t.Fatalf("update not persisted: %+v", org)
+	}
+
+	rec = doJSON(t, router, http.MethodDelete, path, token, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("delete: expected 200, got %d", rec.Code)
+	}
+	orgs, _ := h.Organizations.GetAllOrganizations(ctx)
+	if len(orgs) != 0 {
+		t.Fatalf("expected no organizations after delete, got %d", len(orgs))
+	}
+
+	rec = doJSON(t, router, http.MethodDelete, path, token, nil)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("delete missing: expected 404, got %d", rec.Code)
+	}
+}
+
+func TestErrorsAreProblemDetails(t *testing.T) {
+	router, _ := newTestRouter(t)
+	token := tokenFor(t, "ada@example.com")
+
+	cases := []struct {
+		name   string
+		method string
+		path   string
+		status int
+		code   string
+	}{
+		{"malformed id", http.MethodGet, "/api/organization/Not_An_Id", http.StatusBadRequest, apperror.CodeInvalidID},
+		{"unknown slug", http.MethodGet, "/api/organization/no-such-org", http.StatusNotFound, apperror.CodeNotFound},
+		{"missing organization", http.MethodGet, "/api/organization/" + primitive.NewObjectID().Hex(), http.StatusNotFound, apperror.CodeNotFound},
+		{"unknown route", http.MethodGet, "/nowhere", http.StatusNotFound, apperror.CodeNotFound},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := doJSON(t, router, tc.method, tc.path, token, nil)
+			if rec.Code != tc.status {
+				t.Fatalf("expected %d, got %d: %s", tc.status, rec.Code, rec.Body)
+			}
+			if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, apperror.ProblemContentType) {
+				t.Fatalf("expected problem+json, got %q", ct)
+			}
+
+			var problem apperror.Problem
+			if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil {
+				t.Fatalf("decode problem: %v", err)
+			}
+			if problem.Status != tc.status || problem.Code != tc.code || problem.Instance != tc.path {
+				t.Fatalf("unexpected problem: %+v", problem)
+			}
+		})
+	}
+}
+
+func TestOrganizationRoutesRequireToken(t *testing.T) {
+	router, _ := newTestRouter(t)
+
+	rec := doJSON(t, router, http.MethodGet, "/api/organization", "", nil)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without token, got %d", rec.Code)
+	}
+
+	rec = doJSON(t, router, http.MethodGet, "/api/organization", "not-a-token", nil)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 with invalid token, got %d", rec.Code)
+	}
+}
+
+// slowOrganizations simulates a database that does not answer before the deadline.
+type slowOrganizations struct {
+	repository.OrganizationStore
+}
+
+func (slowOrganizations) FindOrganizationsByMember(ctx context.Context, email string) ([]*models.Organization, error) {
+	return nil, context.DeadlineExceeded
+}
+
+func TestTimeoutMapsToGatewayTimeout(t *testing.T) {
+	router, h := newTestRouter(t)
+	h.Organizations = slowOrganizations{h.Organizations}
+
+	rec := doJSON(t, router, http.MethodGet, "/api/organization", tokenFor(t, "ada@example.com"), nil)
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Fatalf("expected 504, got %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestCreateOrganizationValidatesBody(t *testing.T) {
+	router, h := newTestRouter(t)
+	ctx := context.Background()
+
+	rec := doJSON(t, router, http.MethodPost, "/api/organization", tokenFor(t, "ada@example.com"), gin.H{"name": "", "invited_users": []string{"nope"}})
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d: %s", rec.Code, rec.Body)
+	}
+
+	var problem apperror.Problem
+	json.Unmarshal(rec.Body.Bytes(), &problem)
+	fields := map[string]string{}
+	for _, field := range problem.Errors {
+		fields[field.Field] = field.Rule
+	}
+	if problem.Code != apperror.CodeValidation || fields["name"] != "required" || fields["description"] != "required" || fields["invited_users[0]"] != "email" {
+		t.Fatalf("unexpected problem: %+v", problem)
+	}
+
+	orgs, _ := h.Organizations.GetAllOrganizations(ctx)
+	if len(orgs) != 0 {
+		t.Fatalf("invalid organization was stored: %+v", orgs)
+	}
+}
+
+func TestMalformedJSONIsBadRequest(t *testing.T) {
+	router, _ := newTestRouter(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/auth/signin", strings.NewReader("{not json"))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), apperror.CodeBadRequest) {
+		t.Fatalf("expected bad_request problem, got %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestHealthEndpoints(t *testing.T) {
+	router, h := newTestRouter(t)
+
+	rec := doJSON(t, router, http.MethodGet, "/healthz", "", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("healthz: expected 200, got %d", rec.Code)
+	}
+
+	h.Health.Register("mongodb", func(ctx context.Context) error { return nil })
+	rec = doJSON(t, router, http.MethodGet, "/readyz", "", nil)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"mongodb":{"status":"up"`) {
+		t.Fatalf("readyz: expected 200 with mongodb up, got %d: %s", rec.Code, rec.Body)
+	}
+
+	h.Health.Register("redis", func(ctx context.Context) error { return context.DeadlineExceeded })
+	rec = doJSON(t, router, http.MethodGet, "/readyz", "", nil)
+	if rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), `"redis":{"status":"down"`) {
+		t.Fatalf("readyz: expected 503 with redis down, got %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	router, _ := newTestRouter(t)
+	failures := testutil.ToFloat64(metrics.SignIns.WithLabelValues(metrics.ResultFailure))
+
+	doJSON(t, router, http.MethodPost, "/auth/signin", "", gin.H{"email": "nobody@example.com", "password": "password123"})
+	doJSON(t, router, http.MethodGet, "/api/organization/"+primitive.NewObjectID().Hex(), tokenFor(t, "a@example.com"), nil)
+
+	if got := testutil.ToFloat64(metrics.SignIns.WithLabelValues(metrics.ResultFailure)); got != failures+1 {
+		t.Errorf("sign-in failures = %v, want %v", got, failures+1)
+	}
+
+	rec := doJSON(t, router, http.MethodGet, "/metrics", "", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("metrics: expected 200, got %d", rec.Code)
+	}
+	for _, want := range []string{
+		`organization_api_http_requests_total{method="GET",route="/api/organization/:organization_id",status="404"}`,
+		`organization_api_http_requests_total{method="POST",route="/auth/signin",status="401"}`,
+		"organization_api_http_requests_in_flight",
+		"go_goroutines",
+	} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("metrics output is missing %s", want)
+		}
+	}
+}
+
+func TestTracingContinuesIncomingTrace(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	gin.SetMode(gin.TestMode)
+	h := newMemoryHandler()
+	router := gin.New()
+	router.Use(otelgin.Middleware("test"))
+	routes.Routes(router, h)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/organization/"+primitive.NewObjectID().Hex(), nil)
+	req.Header.Set("Authorization", "Bearer "+tokenFor(t, "a@example.com"))
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		if span.SpanContext().TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+			t.Errorf("span %q did not continue the incoming trace", span.Name())
+		}
+		spans[span.Name()] = span
+	}
+
+	server, ok := spans["/api/organization/:organization_id"]
+	if !ok {
+		t.Fatalf("no server span recorded, got %v", spans)
+	}
+	for _, name := range []string{"middleware.Auth", "middleware.Invite"} {
+		span, ok := spans[name]
+		if !ok {
+			t.Fatalf("no %s span recorded, got %v", name, spans)
+		}
+		if span.Parent().SpanID() != server.SpanContext().SpanID() {
+			t.Errorf("%s is not a child of the server span", name)
+		}
+	}
+	if spans["middleware.Invite"].Status().Code != codes.Error {
+		t.Error("the failed invite check was not recorded on its span")
+	}
+}
+
+func TestRequestIDIsEchoedOrGenerated(t *testing.T) {
+	router, _ := newTestRouter(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	req.Header.Set("X-Request-ID", "caller-id-123")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if got := rec.Header().Get("X-Request-ID"); got != "caller-id-123" {
+		t.Errorf("caller's request ID not echoed, got %q", got)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	req.Header.Set("X-Request-ID", "not valid\nid")
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if got := rec.Header().Get("X-Request-ID"); len(got) != 32 {
+		t.Errorf("expected a generated request ID for an invalid one, got %q", got)
+	}
+}
+
+func TestRevokedAndDisabledAccounts(t *testing.T) {
+	ctx := context.Background()
+	router, h := newTestRouter(t)
+
+	rec := doJSON(t, router, http.MethodPost, "/auth/signup", "", gin.H{"name": "Ada", "email": "ada@example.com", "password": "password123"})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("signup: expected 201, got %d: %s", rec.Code, rec.Body)
+	}
+	var tokens struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &tokens); err != nil || tokens.AccessToken == "" || tokens.RefreshToken == "" {
+		t.Fatalf("decode tokens: %v: %s", err, rec.Body)
+	}
+
+	// Each kind of token is only accepted where it belongs.
+	rec = doJSON(t, router, http.MethodGet, "/api/organization", tokens.RefreshToken, nil)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("refresh token as access token: expected 401, got %d", rec.Code)
+	}
+	rec = doJSON(t, router, http.MethodPost, "/auth/refresh-token", "", gin.H{"token": tokens.AccessToken})
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("access token as refresh token: expected 401, got %d", rec.Code)
+	}
+
+	// Tokens issued up to and including the revocation millisecond are refused.
+	if err := h.Tokens.RevokeTokens(ctx, "ada@example.com", time.Now(), time.Hour); err != nil {
+		t.Fatalf("RevokeTokens: %v", err)
+	}
+	rec = doJSON(t, router, http.MethodGet, "/api/organization", tokens.AccessToken, nil)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("revoked access token: expected 401, got %d", rec.Code)
+	}
+	rec = doJSON(t, router, http.MethodPost, "/auth/refresh-token", "", gin.H{"token": tokens.RefreshToken})
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("revoked refresh token: expected 401, got %d", rec.Code)
+	}
+
+	// Tokens issued after the revocation work, even within the same second.
+	time.Sleep(2 * time.Millisecond)
+	rec = doJSON(t, router, http.MethodPost, "/auth/signin", "", gin.H{"email": "ada@example.com", "password": "password123"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("signin after revocation: expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &tokens); err != nil {
+		t.Fatalf("decode tokens: %v", err)
+	}
+	rec = doJSON(t, router, http.MethodGet, "/api/organization", tokens.AccessToken, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("token issued after revocation: expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+
+	if err := h.Users.SetUserDisabled(ctx, "ada@example.com", true); err != nil {
+		t.Fatalf("SetUserDisabled: %v", err)
+	}
+	rec = doJSON(t, router, http.MethodPost, "/auth/signin", "", gin.H{"email": "ada@example.com", "password": "password123"})
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("signin while disabled: expected 403, got %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestTransferOwnership(t *testing.T) {
+	ctx := context.Background()
+	router, h := newTestRouter(t)
+
+	for _, email := range []string{"ada@example.com", "bob@example.com"} {
+		rec := doJSON(t, router, http.MethodPost, "/auth/signup", "", gin.H{"name": "Tester", "email": email, "password": "password123"})
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("signup %s: expected 201, got %d: %s", email, rec.Code, rec.Body)
+		}
+	}
+	ada, bob := tokenFor(t, "ada@example.com"), tokenFor(t, "bob@example.com")
+
+	rec := doJSON(t, router, http.MethodPost, "/api/organization", ada, gin.H{"name": "Acme", "description": "Widgets"})
+	var created struct {
+		OrganizationID string `json:"organization_id"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &created)
+	path := "/api/organization/" + created.OrganizationID + "/transfer"
+
+	cases := []struct {
+		name   string
+		token  string
+		body   gin.H
+		status int
+	}{
+		{"missing password", ada, gin.H{"new_owner": "bob@example.com"}, http.StatusBadRequest},
+		{"target not a member", ada, gin.H{"new_owner": "bob@example.com", "password": "password123"}, http.StatusConflict},
+		{"wrong password", ada, gin.H{"new_owner": "bob@example.com", "password": "wrong-password"}, http.StatusForbidden},
+		{"caller not the owner", bob, gin.H{"new_owner": "bob@example.com", "password": "password123"}, http.StatusForbidden},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := doJSON(t, router, http.MethodPost, path, tc.token, tc.body)
+			if rec.Code != tc.status {
+				t.Fatalf("expected %d, got %d: %s", tc.status, rec.Code, rec.Body)
+			}
+		})
+		// Once the non-member case has been checked, make bob a member for the rest.
+		if tc.status == http.StatusConflict {
+			h.Organizations.InviteUserToOrganization(ctx, created.OrganizationID, "bob@example.com")
+		}
+	}
+
+	rec = doJSON(t, router, http.MethodPost, path, ada, gin.H{"new_owner": "bob@example.com", "password": "password123"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("transfer: expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	org, _ := h.Organizations.GetOrganizationById(ctx, created.OrganizationID)
+	if org.Owner != "bob@example.com" || !org.IsAdmin("ada@example.com") || len(org.OwnershipTransfers) != 1 {
+		t.Fatalf("expected bob to own the organization and ada to be an admin, got %+v", org)
+	}
+
+	// The previous owner can no longer transfer it.
+	rec = doJSON(t, router, http.MethodPost, path, ada, gin.H{"new_owner": "ada@example.com", "password": "password123"})
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("transfer by previous owner: expected 403, got %d", rec.Code)
+	}
+}
+
+func TestTeams(t *testing.T) {
+	ctx := context.Background()
+	router, h := newTestRouter(t)
+	ada, bob, cat := tokenFor(t, "ada@example.com"), tokenFor(t, "bob@example.com"), tokenFor(t, "cat@example.com")
+
+	rec := doJSON(t, router, http.MethodPost, "/api/organization", ada, gin.H{"name": "Acme", "description": "Widgets"})
+	var created struct {
+		OrganizationID string `json:"organization_id"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &created)
+	orgPath := "/api/organization/" + created.OrganizationID
+	for _, email := range []string{"bob@example.com", "cat@example.com"} {
+		h.Organizations.InviteUserToOrganization(ctx, created.OrganizationID, email)
+	}
+
+	// Plain members cannot create teams or invite people.
+	rec = doJSON(t, router, http.MethodPost, orgPath+"/teams", bob, gin.H{"name": "Platform"})
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("create team as member: expected 403, got %d", rec.Code)
+	}
+
+	rec = doJSON(t, router, http.MethodPost, orgPath+"/teams", ada, gin.H{"name": "Platform"})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create team: expected 201, got %d: %s", rec.Code, rec.Body)
+	}
+	var team models.Team
+	json.Unmarshal(rec.Body.Bytes(), &team)
+	teamPath := orgPath + "/teams/" + team.Id.Hex()
+
+	rec = doJSON(t, router, http.MethodPut, teamPath+"/members/dan@example.com", ada, nil)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("add non-member: expected 409, got %d", rec.Code)
+	}
+	rec = doJSON(t, router, http.MethodPut, teamPath+"/members/bob@example.com", ada, gin.H{"role": "maintainer"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("add maintainer: expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+
+	// A maintainer manages the team's members and name, but not its permissions.
+	rec = doJSON(t, router, http.MethodPut, teamPath+"/members/cat@example.com", bob, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("maintainer adds member: expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	rec = doJSON(t, router, http.MethodPatch, teamPath, bob, gin.H{"name": "Infrastructure"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("maintainer renames team: expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	rec = doJSON(t, router, http.MethodPatch, teamPath, cat, gin.H{"name": "Mine"})
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("member renames team: expected 403, got %d", rec.Code)
+	}
+	rec = doJSON(t, router, http.MethodPut, teamPath+"/permissions", bob, gin.H{"permissions": []string{models.PermissionInviteMembers}})
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("maintainer grants permission: expected 403, got %d", rec.Code)
+	}
+
+	// Granting a permission to the team extends it to every member.
+	rec = doJSON(t, router, http.MethodPost, orgPath+"/invite", cat, gin.H{"user_email": "dan@example.com"})
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("invite without permission: expected 403, got %d", rec.Code)
+	}
+	rec = doJSON(t, router, http.MethodPut, teamPath+"/permissions", ada, gin.H{"permissions": []string{"everything"}})
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("unknown permission: expected 400, got %d", rec.Code)
+	}
+	rec = doJSON(t, router, http.MethodPut, teamPath+"/permissions", ada, gin.H{"permissions": []string{models.PermissionInviteMembers}})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("grant permission: expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	rec = doJSON(t, router, http.MethodPost, orgPath+"/invite", cat, gin.H{"user_email": "dan@example.com"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("invite through team permission: expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+
+	rec = doJSON(t, router, http.MethodDelete, teamPath+"/members/cat@example.com", bob, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("remove member: expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	rec = doJSON(t, router, http.MethodPost, orgPath+"/invite", cat, gin.H{"user_email": "eve@example.com"})
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("invite after leaving the team: expected 403, got %d", rec.Code)
+	}
+
+	rec = doJSON(t, router, http.MethodDelete, teamPath, bob, nil)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("maintainer deletes team: expected 403, got %d", rec.Code)
+	}
+	rec = doJSON(t, router, http.MethodDelete, teamPath, ada, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("delete team: expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	rec = doJSON(t, router, http.MethodGet, orgPath+"/teams", cat, nil)
+	var teams []models.Team
+	json.Unmarshal(rec.Body.Bytes(), &teams)
+	if rec.Code != http.StatusOK || len(teams) != 0 {
+		t.Fatalf("list teams after delete: expected no teams, got %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestOrganizationHierarchy(t *testing.T) {
+	ctx := context.Background()
+	router, h := newTestRouter(t)
+	ada, bob := tokenFor(t, "ada@example.com"), tokenFor(t, "bob@example.com")
+
+	create := func(token string, body gin.H) (string, *httptest.ResponseRecorder) {
+		rec := doJSON(t, router, http.MethodPost, "/api/organization", token, body)
+		var created struct {
+			OrganizationID string `json:"organization_id"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &created)
+		return created.OrganizationID, rec
+	}
+
+	parentID, _ := create(ada, gin.H{"name": "Holding", "description": "Parent"})
+	childID, rec := create(ada, gin.H{"name": "Subsidiary", "description": "Child", "parent_id": parentID})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create child: expected 201, got %d: %s", rec.Code, rec.Body)
+	}
+	grandchildID, _ := create(ada, gin.H{"name": "Unit", "description": "Grandchild", "parent_id": childID})
+
+	// Only admins of the parent may add children to it.
+	if _, rec := create(bob, gin.H{"name": "Rogue", "description": "Child", "parent_id": parentID}); rec.Code != http.StatusForbidden {
+		t.Fatalf("create child of someone else's organization: expected 403, got %d", rec.Code)
+	}
+
+	rec = doJSON(t, router, http.MethodGet, "/api/organization/"+grandchildID+"/ancestors", ada, nil)
+	var ancestors []models.Organization
+	json.Unmarshal(rec.Body.Bytes(), &ancestors)
+	if rec.Code != http.StatusOK || len(ancestors) != 2 || ancestors[0].Id.Hex() != childID || ancestors[1].Id.Hex() != parentID {
+		t.Fatalf("ancestors: expected child then parent, got %d: %s", rec.Code, rec.Body)
+	}
+	rec = doJSON(t, router, http.MethodGet, "/api/organization/"+parentID+"/children", ada, nil)
+	var children []models.Organization
+	json.Unmarshal(rec.Body.Bytes(), &children)
+	if rec.Code != http.StatusOK || len(children) != 1 || children[0].Id.Hex() != childID {
+		t.Fatalf("children: expected the child, got %d: %s", rec.Code, rec.Body)
+	}
+
+	// Members and admins of the parent reach every descendant.
+	h.Organizations.InviteUserToOrganization(ctx, parentID, "bob@example.com")
+	rec = doJSON(t, router, http.MethodGet, "/api/organization/"+grandchildID, bob, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("inherited membership: expected 200, got %d", rec.Code)
+	}
+	rec = doJSON(t, router, http.MethodPut, "/api/organization/"+grandchildID, bob, gin.H{"name": "Renamed", "description": "Grandchild"})
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("inherited member updates: expected 403, got %d", rec.Code)
+	}
+	rec = doJSON(t, router, http.MethodPut, "/api/organization/"+grandchildID, ada, gin.H{"name": "Renamed", "description": "Grandchild"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("inherited admin updates: expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+
+	cases := []struct {
+		name     string
+		id       string
+		parentID string
+		status   int
+	}{
+		{"own parent", parentID, parentID, http.StatusConflict},
+		{"under a descendant", parentID, grandchildID, http.StatusConflict},
+		{"missing parent", childID, primitive.NewObjectID().Hex(), http.StatusBadRequest},
+		{"to the top level", grandchildID, "", http.StatusOK},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := doJSON(t, router, http.MethodPut, "/api/organization/"+tc.id+"/parent", ada, gin.H{"parent_id": tc.parentID})
+			if rec.Code != tc.status {
+				t.Fatalf("expected %d, got %d: %s", tc.status, rec.Code, rec.Body)
+			}
+		})
+	}
+
+	rec = doJSON(t, router, http.MethodDelete, "/api/organization/"+parentID, ada, nil)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("delete a parent: expected 409, got %d", rec.Code)
+	}
+}
+
+func TestOrganizationSlugs(t *testing.T) {
+	router, _ := newTestRouter(t)
+	ada := tokenFor(t, "ada@example.com")
+
+	rec := doJSON(t, router, http.MethodPost, "/api/organization", ada, gin.H{"name": "Acme Corp", "description": "Widgets"})
+	var created struct {
+		OrganizationID string `json:"organization_id"`
+		Slug           string `json:"slug"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &created)
+	if rec.Code != http.StatusCreated || created.Slug != "acme-corp" {
+		t.Fatalf("create: expected slug acme-corp, got %d: %s", rec.Code, rec.Body)
+	}
+	rec = doJSON(t, router, http.MethodPost, "/api/organization", ada, gin.H{"name": "ACME  corp.", "description": "Gadgets"})
+	if !strings.Contains(rec.Body.String(), `"slug":"acme-corp-2"`) {
+		t.Fatalf("create with a taken slug: expected acme-corp-2, got %s", rec.Body)
+	}
+
+	rec = doJSON(t, router, http.MethodGet, "/api/organization/acme-corp", ada, nil)
+	var org models.Organization
+	json.Unmarshal(rec.Body.Bytes(), &org)
+	if rec.Code != http.StatusOK || org.Id.Hex() != created.OrganizationID || org.Slug != "acme-corp" {
+		t.Fatalf("get by slug: expected the organization, got %d: %s", rec.Code, rec.Body)
+	}
+
+	// Renaming re-slugs the organization; the old slug redirects to the new one.
+	rec = doJSON(t, router, http.MethodPut, "/api/organization/acme-corp", ada, gin.H{"name": "Acme Industries", "description": "Widgets"})
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"slug":"acme-industries"`) {
+		t.Fatalf("rename by slug: expected slug acme-industries, got %d: %s", rec.Code, rec.Body)
+	}
+	rec = doJSON(t, router, http.MethodGet, "/api/organization/acme-corp/children?page=2", ada, nil)
+	if rec.Code != http.StatusPermanentRedirect || rec.Header().Get("Location") != "/api/organization/acme-industries/children?page=2" {
+		t.Fatalf("old slug: expected a redirect, got %d to %q", rec.Code, rec.Header().Get("Location"))
+	}
+	rec = doJSON(t, router, http.MethodGet, "/api/organization/acme-corp", tokenFor(t, "eve@example.com"), nil)
+	if rec.Code != http.StatusForbidden || rec.Header().Get("Location") != "" {
+		t.Fatalf("old slug for a non-member: expected 403 without a redirect, got %d to %q", rec.Code, rec.Header().Get("Location"))
+	}
+
+	// Nested routes resolve the slug as well.
+	rec = doJSON(t, router, http.MethodPost, "/api/organization/acme-industries/teams", ada, gin.H{"name": "Platform"})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create team by slug: expected 201, got %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	ctx := context.Background()
+	router, h := newTestRouter(t)
+	ada := tokenFor(t, "ada@example.com")
+
+	for _, user := range []models.User{
+		{Name: "Ada Lovelace", Email: "ada@example.com"},
+		{Name: "Grace Rocket", Email: "grace@example.com"},
+		{Name: "Rocket Outsider", Email: "eve@example.com"},
+	} {
+		h.Users.CreateUser(ctx, &models.User{Name: user.Name, Email: user.Email, Password: "secret-hash"})
+	}
+	parentID, _ := h.Organizations.CreateOrganization(ctx, &models.Organization{Name: "Rocket Works", Description: "Engines", Owner: "ada@example.com", InvitedUsers: []string{"ada@example.com", "grace@example.com"}})
+	h.Organizations.CreateOrganization(ctx, &models.Organization{Name: "Launch Pad", Description: "Ground support for rockets and rocket engines", ParentId: parentID})
+	h.Organizations.CreateOrganization(ctx, &models.Organization{Name: "Rocket Rivals", Description: "Hidden", Owner: "eve@example.com", InvitedUsers: []string{"eve@example.com"}})
+
+	search := func(query string) (*httptest.ResponseRecorder, []models.SearchHit, int64) {
+		rec := doJSON(t, router, http.MethodGet, "/api/search?"+query, ada, nil)
+		var page struct {
+			Results []models.SearchHit `json:"results"`
+			Total   int64              `json:"total"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &page)
+		return rec, page.Results, page.Total
+	}
+
+	// Only organizations the caller belongs to, their descendants and their members are found.
+	rec, results, total := search("q=rocket")
+	if rec.Code != http.StatusOK || total != 3 || len(results) != 3 {
+		t.Fatalf("search: expected three visible matches, got %d: %s", rec.Code, rec.Body)
+	}
+	if results[0].Type != models.SearchTypeOrganization || results[0].Organization.Name != "Rocket Works" {
+		t.Fatalf("expected the organization named Rocket first, got %+v", results[0])
+	}
+	for _, result := range results {
+		if result.User != nil && (result.User.Email == "eve@example.com" || result.User.Password != "") {
+			t.Fatalf("unexpected user in results: %+v", result.User)
+		}
+		if result.Organization != nil && (result.Organization.Name == "Rocket Rivals" || result.Organization.InvitedUsers != nil) {
+			t.Fatalf("unexpected organization in results: %+v", result.Organization)
+		}
+	}
+
+	_, results, total = search("q=rocket&type=user")
+	if total != 1 || len(results) != 1 || results[0].User.Email != "grace@example.com" {
+		t.Fatalf("type filter: expected Grace only, got %d: %+v", total, results)
+	}
+	_, results, total = search("q=rocket&limit=1&offset=2")
+	if total != 3 || len(results) != 1 || results[0].Organization == nil || results[0].Organization.Name != "Launch Pad" {
+		t.Fatalf("last page: expected the child organization, got %d: %+v", total, results)
+	}
+	rec, results, _ = search("q=nothing+matches")
+	if rec.Code != http.StatusOK || results == nil || len(results) != 0 {
+		t.Fatalf("no matches: expected an empty list, got %d: %s", rec.Code, rec.Body)
+	}
+
+	for _, query := range []string{"q=", "q=+", "q=rocket&type=team", "q=rocket&limit=0", "q=rocket&limit=101", "q=rocket&offset=-1"} {
+		if rec, _, _ := search(query); rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d: %s", query, rec.Code, rec.Body)
+		}
+	}
+}
+
+func doFile(t *testing.T, router *gin.Engine, method, path, token, contentType, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestCreateInvitesMembers(t *testing.T) {
+	ctx := context.Background()
+	router, h := newTestRouter(t)
+	h.Users.CreateUser(ctx, &models.User{Name: "Bob", Email: "bob@example.com", Password: "secret-hash"})
+
+	// Members named at creation are invited like any others: only those without an account get an
+	// invitation that lapses, and nobody becomes an admin by being named.
+	rec := doJSON(t, router, http.MethodPost, "/api/organization", tokenFor(t, "ada@example.com"), gin.H{
+		"name": "Acme", "description": "Widgets", "invited_users": []string{"bob@example.com", "cy@example.com", "ada@example.com"},
+	})
+	var created struct {
+		OrganizationID string `json:"organization_id"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &created)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create: expected 201, got %d: %s", rec.Code, rec.Body)
+	}
+
+	org, _ := h.Organizations.GetOrganizationById(ctx, created.OrganizationID)
+	if len(org.InvitedUsers) != 3 || len(org.Admins) != 0 {
+		t.Fatalf("expected ada, bob and cy as members and no admins, got %v and %v", org.InvitedUsers, org.Admins)
+	}
+	if len(org.Invitations) != 1 || org.Invitations[0].Email != "cy@example.com" || org.Invitations[0].ExpiresAt.IsZero() {
+		t.Fatalf("expected only cy to be invited pending sign-up, got %+v", org.Invitations)
+	}
+}
+
+func TestImportAndExport(t *testing.T) {
+	ctx := context.Background()
+	router, h := newTestRouter(t)
+	ada, bob := tokenFor(t, "ada@example.com"), tokenFor(t, "bob@example.com")
+	othersID, _ := h.Organizations.CreateOrganization(ctx, &models.Organization{Name: "Not Ada's", Description: "Other", Owner: "bob@example.com", InvitedUsers: []string{"bob@example.com"}})
+
+	file := "name,description,slug,parent,members\n" +
+		"Acme,Widgets,acme-src,,bob@example.com;cat@example.com\n" +
+		"Acme Labs,Research,,acme-src,cat@example.com\n" +
+		"X,Too short,,,not-an-email\n" +
+		"Stray,Child,," + othersID + ",\n" +
+		"Orphan,Child,,nowhere,\n"
+	report := func(rec *httptest.ResponseRecorder) models.ImportReport {
+		var report models.ImportReport
+		json.Unmarshal(rec.Body.Bytes(), &report)
+		return report
+	}
+
+	// A dry run reports every row and creates nothing.
+	rec := doFile(t, router, http.MethodPost, "/api/organization/import?dry_run=true", ada, "text/csv", file)
+	dry := report(rec)
+	if rec.Code != http.StatusOK || !dry.DryRun || dry.Total != 5 || dry.Created != 0 || dry.Failed != 3 {
+		t.Fatalf("dry run: expected 2 valid and 3 invalid rows, got %d: %s", rec.Code, rec.Body)
+	}
+	wantStatuses := []string{models.ImportRowValid, models.ImportRowValid, models.ImportRowInvalid, models.ImportRowInvalid, models.ImportRowInvalid}
+	for i, row := range dry.Rows {
+		if row.Row != i+1 || row.Status != wantStatuses[i] {
+			t.Fatalf("dry run row %d: expected %s, got %+v", i+1, wantStatuses[i], row)
+		}
+	}
+	if fields := dry.Rows[2].Errors; len(fields) != 2 || fields[0].Field != "name" || fields[1].Field != "members[0]" {
+		t.Fatalf("expected field errors for name and members, got %+v", fields)
+	}
+	if orgs, _ := h.Organizations.FindOrganizationsByMember(ctx, "ada@example.com"); len(orgs) != 0 {
+		t.Fatalf("dry run created %d organizations", len(orgs))
+	}
+
+	// A real import creates the valid rows, owned by the caller, and links children to earlier rows.
+	rec = doFile(t, router, http.MethodPost, "/api/organization/import", ada, "text/csv", file)
+	imported := report(rec)
+	if rec.Code != http.StatusOK || imported.Created != 2 || imported.Failed != 3 {
+		t.Fatalf("import: expected 2 created rows, got %d: %s", rec.Code, rec.Body)
+	}
+	parent, _ := h.Organizations.GetOrganizationById(ctx, imported.Rows[0].OrganizationId)
+	child, _ := h.Organizations.GetOrganizationById(ctx, imported.Rows[1].OrganizationId)
+	if parent.Owner != "ada@example.com" || len(parent.InvitedUsers) != 3 || parent.Slug != "acme" || child.ParentId != parent.Id.Hex() {
+		t.Fatalf("unexpected organizations: %+v, %+v", parent, child)
+	}
+	if len(parent.Invitations) != 2 {
+		t.Fatalf("expected bob and cat, who have no accounts, to be invited pending sign-up, got %+v", parent.Invitations)
+	}
+
+	// Large imports, or those asked to, run as jobs only their starter can poll.
+	rec = doFile(t, router, http.MethodPost, "/api/organization/import?async=true", ada, "application/x-ndjson",
+		`{"name":"Orbital","description":"Payloads","parent":"acme"}`+"\n"+`{"name":"Ground","description":"Stations","members":["dan@example.com"]}`)
+	location := rec.Header().Get("Location")
+	if rec.Code != http.StatusAccepted || !strings.HasPrefix(location, "/api/organization/import/") {
+		t.Fatalf("async import: expected 202 with a location, got %d: %s", rec.Code, rec.Body)
+	}
+	var job models.ImportJob
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		rec = doJSON(t, router, http.MethodGet, location, ada, nil)
+		json.Unmarshal(rec.Body.Bytes(), &job)
+		if job.Status != models.ImportJobRunning {
+			break
+		}
+	}
+	if job.Status != models.ImportJobCompleted || job.Processed != 2 || job.Created != 2 || len(job.Rows) != 2 {
+		t.Fatalf("job: expected two created rows, got %s", rec.Body)
+	}
+	if rec := doJSON(t, router, http.MethodGet, location, bob, nil); rec.Code != http.StatusNotFound {
+		t.Fatalf("another caller's job: expected 404, got %d", rec.Code)
+	}
+
+	// The export lists what the caller administers, parents first, in the format imports accept.
+	rec = doJSON(t, router, http.MethodGet, "/api/organization/export?format=csv", ada, nil)
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/csv") {
+		t.Fatalf("export: expected CSV, got %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	if len(lines) != 5 || lines[0] != "name,description,slug,parent,members" ||
+		lines[1] != "Acme,Widgets,acme,,ada@example.com;bob@example.com;cat@example.com" ||
+		!strings.Contains(rec.Body.String(), "Orbital,Payloads,orbital,acme,ada@example.com") ||
+		strings.Contains(rec.Body.String(), "Not Ada's") {
+		t.Fatalf("export: unexpected file:\n%s", rec.Body)
+	}
+	rec = doJSON(t, router, http.MethodGet, "/api/organization/export", bob, nil)
+	if rec.Code != http.StatusOK || strings.Count(rec.Body.String(), "\n") != 1 || !strings.Contains(rec.Body.String(), `"slug":"not-ada-s"`) {
+		t.Fatalf("export as NDJSON: expected Bob's organization only, got %d: %s", rec.Code, rec.Body)
+	}
+
+	cases := []struct {
+		name        string
+		path        string
+		contentType string
+		body        string
+		status      int
+	}{
+		{"unsupported type", "/api/organization/import", "application/json", "{}", http.StatusBadRequest},
+		{"empty file", "/api/organization/import", "text/csv", "name,description\n", http.StatusBadRequest},
+		{"missing column", "/api/organization/import", "text/csv", "title\nAcme\n", http.StatusBadRequest},
+		{"bad flag", "/api/organization/import?dry_run=maybe", "text/csv", file, http.StatusBadRequest},
+		{"bad export format", "/api/organization/export?format=xml", "", "", http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			method := http.MethodPost
+			if tc.contentType == "" {
+				method = http.MethodGet
+			}
+			if rec := doFile(t, router, method, tc.path, ada, tc.contentType, tc.body); rec.Code != tc.status {
+				t.Fatalf("expected %d, got %d: %s", tc.status, rec.Code, rec.Body)
+			}
+		})
+	}
+}
+
+func TestBatchInvite(t *testing.T) {
+	router, h := newTestRouter(t)
+	h.ConfigureInvitations(config.InvitationConfig{BatchRateLimit: 2, BatchRateWindow: time.Minute})
+	ada := tokenFor(t, "ada@example.com")
+
+	rec := doJSON(t, router, http.MethodPost, "/api/organization", ada, gin.H{"name": "Acme", "description": "Widgets"})
+	var created struct {
+		OrganizationID string `json:"organization_id"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &created)
+	path := "/api/organization/" + created.OrganizationID + "/invitations:batch"
+
+	h.Users.CreateUser(context.Background(), &models.User{Name: "Cy", Email: "Cy@Example.com", Password: "secret-hash"})
+	rec = doJSON(t, router, http.MethodPost, path, ada, gin.H{"emails": []string{
+		"bob@example.com", " ada@example.com", "not-an-email", "bob@example.com ", "Cy@Example.com",
+	}})
+	var response struct {
+		Results []models.InvitationResult `json:"results"`
+		Invited int                       `json:"invited"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &response)
+	want := []models.InvitationResult{
+		{Email: "bob@example.com", Status: models.InvitationInvited},
+		{Email: "ada@example.com", Status: models.InvitationAlreadyMember},
+		{Email: "not-an-email", Status: models.InvitationInvalid},
+		{Email: "bob@example.com", Status: models.InvitationDuplicate},
+		{Email: "Cy@Example.com", Status: models.InvitationInvited},
+	}
+	if rec.Code != http.StatusOK || response.Invited != 2 || len(response.Results) != len(want) {
+		t.Fatalf("batch: expected 2 invited, got %d: %s", rec.Code, rec.Body)
+	}
+	for i, result := range response.Results {
+		if result.Email != want[i].Email || result.Status != want[i].Status {
+			t.Errorf("result %d: expected %+v, got %+v", i, want[i], result)
+		}
+	}
+
+	// Invited users are members and can read the organization, with the email they signed up with.
+	rec = doJSON(t, router, http.MethodGet, "/api/organization/"+created.OrganizationID, tokenFor(t, "Cy@Example.com"), nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("invited member: expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	if org, _ := h.Organizations.GetOrganizationById(context.Background(), created.OrganizationID); len(org.Invitations) != 1 || org.Invitations[0].Email != "bob@example.com" {
+		t.Fatalf("expected only bob, who has no account, to have a pending invitation, got %+v", org.Invitations)
+	}
+
+	// Members need the invite permission; the path must end in the custom method.
+	rec = doJSON(t, router, http.MethodPost, path, tokenFor(t, "bob@example.com"), gin.H{"emails": []string{"dee@example.com"}})
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("plain member: expected 403, got %d: %s", rec.Code, rec.Body)
+	}
+	rec = doJSON(t, router, http.MethodPost, "/api/organization/"+created.OrganizationID+"/invitations:bulk", ada, gin.H{"emails": []string{"dee@example.com"}})
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("unknown custom method: expected 404, got %d: %s", rec.Code, rec.Body)
+	}
+	rec = doJSON(t, router, http.MethodPost, path, ada, gin.H{"emails": []string{}})
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("empty batch: expected 400, got %d: %s", rec.Code, rec.Body)
+	}
+
+	// The empty batch did not count; the next batch reaches the limit of 2 and the third is refused.
+	rec = doJSON(t, router, http.MethodPost, path, ada, gin.H{"emails": []string{"dee@example.com"}})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("second batch: expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	rec = doJSON(t, router, http.MethodPost, path, ada, gin.H{"emails": []string{"eve@example.com"}})
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("third batch: expected 429 with Retry-After, got %d: %s", rec.Code, rec.Body)
+	}
+	if !strings.Contains(rec.Body.String(), `"code":"rate_limited"`) {
+		t.Fatalf("third batch: expected a rate_limited problem, got %s", rec.Body)
+	}
+}
+
+func TestMetadataAndTags(t *testing.T) {
+	router, _ := newTestRouter(t)
+	ada := tokenFor(t, "ada@example.com")
+
+	rec := doJSON(t, router, http.MethodPost, "/api/organization", ada, gin.H{"name": "Acme", "description": "Widgets", "tags": []string{"enterprise"}})
+	var created struct {
+		OrganizationID string `json:"organization_id"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &created)
+	doJSON(t, router, http.MethodPost, "/api/organization", ada, gin.H{"name": "Globex", "description": "Gadgets"})
+	path := "/api/organization/" + created.OrganizationID
+
+	rec = doJSON(t, router, http.MethodPut, path+"/metadata", ada, gin.H{"metadata": gin.H{"region": "eu", "billing_id": "B-1"}})
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"region":"eu"`) {
+		t.Fatalf("set metadata: expected 200 with the metadata, got %d: %s", rec.Code, rec.Body)
+	}
+	rec = doJSON(t, router, http.MethodPut, path+"/tags", ada, gin.H{"tags": []string{"enterprise", "beta"}})
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"tags":["enterprise","beta"]`) {
+		t.Fatalf("set tags: expected 200 with the tags, got %d: %s", rec.Code, rec.Body)
+	}
+
+	// Updating the name and description leaves metadata and tags alone.
+	doJSON(t, router, http.MethodPut, path, ada, gin.H{"name": "Acme", "description": "More widgets"})
+	rec = doJSON(t, router, http.MethodGet, path, ada, nil)
+	if !strings.Contains(rec.Body.String(), `"billing_id":"B-1"`) || !strings.Contains(rec.Body.String(), `"beta"`) {
+		t.Fatalf("get: expected metadata and tags to survive an update, got %s", rec.Body)
+	}
+
+	// Another tenant's organization with the same metadata stays out of Ada's list.
+	eve := tokenFor(t, "eve@example.com")
+	rec = doJSON(t, router, http.MethodPost, "/api/organization", eve, gin.H{"name": "Initech", "description": "Reports", "invited_users": []string{"pending@example.com"}})
+	json.Unmarshal(rec.Body.Bytes(), &created)
+	doJSON(t, router, http.MethodPut, "/api/organization/"+created.OrganizationID+"/metadata", eve, gin.H{"metadata": gin.H{"region": "eu"}})
+	rec = doJSON(t, router, http.MethodGet, "/api/organization?metadata.region=eu", eve, nil)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "Initech") || strings.Contains(rec.Body.String(), "invited_users") {
+		t.Fatalf("list: expected Eve's organization as a summary, got %d: %s", rec.Code, rec.Body)
+	}
+
+	for query, want := range map[string]int{
+		"":                             2,
+		"?tag=enterprise":              1,
+		"?tag=enterprise&tag=beta":     1,
+		"?tag=beta&metadata.region=eu": 1,
+		"?metadata.region=us":          0,
+	} {
+		rec = doJSON(t, router, http.MethodGet, "/api/organization"+query, ada, nil)
+		var orgs []models.Organization
+		json.Unmarshal(rec.Body.Bytes(), &orgs)
+		if rec.Code != http.StatusOK || len(orgs) != want {
+			t.Errorf("list%s: expected %d organizations, got %d: %s", query, want, rec.Code, rec.Body)
+		}
+	}
+
+	// Keys, tags and sizes are validated, in requests and filters alike.
+	invalid := []struct {
+		method, path string
+		body         interface{}
+	}{
+		{http.MethodPut, path + "/metadata", gin.H{"metadata": gin.H{"bad.key": "x"}}},
+		{http.MethodPut, path + "/metadata", gin.H{"metadata": gin.H{"region": strings.Repeat("x", 501)}}},
+		{http.MethodPut, path + "/tags", gin.H{"tags": []string{"Enterprise"}}},
+		{http.MethodPut, path + "/tags", gin.H{"tags": []string{"beta", "beta"}}},
+		{http.MethodPut, path + "/tags", gin.H{"tags": make([]string, 21)}},
+		{http.MethodGet, "/api/organization?metadata.$where=1", nil},
+		{http.MethodGet, "/api/organization?metadata.region=eu&metadata.region=us", nil},
+	}
+	for _, tc := range invalid {
+		rec = doJSON(t, router, tc.method, tc.path, ada, tc.body)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s %s: expected 400, got %d: %s", tc.method, tc.path, rec.Code, rec.Body)
+		}
+	}
+
+	// Changing them needs organization:manage.
+	rec = doJSON(t, router, http.MethodPut, path+"/tags", tokenFor(t, "bob@example.com"), gin.H{"tags": []string{"beta"}})
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("non-member: expected 403, got %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestOrganizationSettings(t *testing.T) {
+	router, h := newTestRouter(t)
+	ada := tokenFor(t, "ada@example.com")
+
+	rec := doJSON(t, router, http.MethodPost, "/api/organization", ada, gin.H{"name": "Acme", "description": "Widgets"})
+	var created struct {
+		OrganizationID string `json:"organization_id"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &created)
+	path := "/api/organization/" + created.OrganizationID
+
+	rec = doJSON(t, router, http.MethodGet, path+"/settings", ada, nil)
+	var settings models.OrganizationSettings
+	json.Unmarshal(rec.Body.Bytes(), &settings)
+	if rec.Code != http.StatusOK || settings.DefaultRole != models.MemberRoleMember || settings.InvitationExpiryDays != 7 || settings.SchemaVersion != 2 || settings.DomainJoin != models.DomainJoinOff {
+		t.Fatalf("get: expected the defaults, got %d: %s", rec.Code, rec.Body)
+	}
+
+	rec = doJSON(t, router, http.MethodPatch, path+"/settings", ada, gin.H{"allowed_domains": []string{"example.com"}, "invitation_expiry_days": 3})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("patch: expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	rec = doJSON(t, router, http.MethodPatch, path+"/settings", ada, gin.H{"default_role": "admin"})
+	settings = models.OrganizationSettings{}
+	json.Unmarshal(rec.Body.Bytes(), &settings)
+	if rec.Code != http.StatusOK || settings.DefaultRole != models.MemberRoleAdmin || len(settings.AllowedDomains) != 1 || settings.InvitationExpiryDays != 3 {
+		t.Fatalf("second patch: expected both changes, got %d: %s", rec.Code, rec.Body)
+	}
+
+	for _, body := range []gin.H{
+		{"alowed_domains": []string{"example.com"}},
+		{"default_role": "owner"},
+		{"invitation_expiry_days": 0},
+		{"allowed_domains": []string{"not a domain"}},
+		{"require_mfa": true},
+		{"domain_join": "always"},
+	} {
+		rec = doJSON(t, router, http.MethodPatch, path+"/settings", ada, body)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("patch %v: expected 400, got %d: %s", body, rec.Code, rec.Body)
+		}
+	}
+
+	// Invitations respect the allowed domains and the default role. Bob has no account yet, so his
+	// invitation expires after the configured three days unless he signs up.
+	rec = doJSON(t, router, http.MethodPost, path+"/invite", ada, gin.H{"user_email": "eve@elsewhere.org"})
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), `"rule":"allowed_domain"`) {
+		t.Fatalf("invite outside the domains: expected 400, got %d: %s", rec.Code, rec.Body)
+	}
+	rec = doJSON(t, router, http.MethodPost, path+"/invite", ada, gin.H{"user_email": "bob@example.com"})
+	var invited struct {
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &invited)
+	if until := time.Until(invited.ExpiresAt); rec.Code != http.StatusOK || until < 71*time.Hour || until > 72*time.Hour {
+		t.Fatalf("invite: expected an invitation for three days, got %d: %s", rec.Code, rec.Body)
+	}
+	rec = doJSON(t, router, http.MethodPost, path+"/invitations:batch", ada, gin.H{"emails": []string{"eve@elsewhere.org", "cy@example.com"}})
+	if !strings.Contains(rec.Body.String(), `"status":"domain_not_allowed"`) || !strings.Contains(rec.Body.String(), `"status":"invited"`) {
+		t.Fatalf("batch: expected eve to be refused and cy invited, got %d: %s", rec.Code, rec.Body)
+	}
+
+	ctx := context.Background()
+	org, _ := h.Organizations.GetOrganizationById(ctx, created.OrganizationID)
+	if !org.IsAdmin("bob@example.com") || len(org.Invitations) != 2 {
+		t.Fatalf("expected bob and cy as pending admins, got admins %v and invitations %v", org.Admins, org.Invitations)
+	}
+
+	// Signing up in time makes the membership permanent.
+	rec = doJSON(t, router, http.MethodPost, "/auth/signup", "", gin.H{"name": "Bob", "email": "bob@example.com", "password": "password123"})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("signup: expected 201, got %d: %s", rec.Code, rec.Body)
+	}
+	org, _ = h.Organizations.GetOrganizationById(ctx, created.OrganizationID)
+	if len(org.Invitations) != 1 || org.Invitations[0].Email != "cy@example.com" {
+		t.Fatalf("expected only cy's invitation to remain pending, got %v", org.Invitations)
+	}
+
+	// Settings are for admins only. Bob became one through the default role.
+	rec = doJSON(t, router, http.MethodGet, path+"/settings", tokenFor(t, "bob@example.com"), nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("admin: expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	doJSON(t, router, http.MethodPatch, path+"/settings", ada, gin.H{"default_role": "member"})
+	doJSON(t, router, http.MethodPost, path+"/invite", ada, gin.H{"user_email": "dee@example.com"})
+	rec = doJSON(t, router, http.MethodGet, path+"/settings", tokenFor(t, "dee@example.com"), nil)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("member: expected 403, got %d: %s", rec.Code, rec.Body)
+	}
+}
+
+// brokenResolver fails every lookup with err.
+type brokenResolver struct{ err error }
+
+func (r brokenResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	return nil, r.err
+}
+
+// outbox records the messages a handler sends instead of delivering them.
+type outbox struct {
+	mu       sync.Mutex
+	messages []mail.Message
+}
+
+func (o *outbox) Send(ctx context.Context, msg mail.Message) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.messages = append(o.messages, msg)
+	return nil
+}
+
+// token returns the confirmation token last sent to email.
+func (o *outbox) token(t *testing.T, email string) string {
+	t.Helper()
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for i := len(o.messages) - 1; i >= 0; i-- {
+		if o.messages[i].To == email {
+			fields := strings.Fields(o.messages[i].Body)
+			return fields[len(fields)-1]
+		}
+	}
+	t.Fatalf("no message sent to %s", email)
+	return ""
+}
+
+func TestVerifyEmail(t *testing.T) {
+	router, h := newTestRouter(t)
+	sent := &outbox{}
+	h.Mailer = sent
+
+	rec := doJSON(t, router, http.MethodPost, "/auth/signup", "", gin.H{"name": "Ada", "email": "ada@example.com", "password": "password123"})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("signup: expected 201, got %d: %s", rec.Code, rec.Body)
+	}
+	var tokens models.AuthResponse
+	json.Unmarshal(rec.Body.Bytes(), &tokens)
+
+	// Resending works until the address is confirmed.
+	rec = doJSON(t, router, http.MethodPost, "/auth/verify-email/resend", tokens.AccessToken, nil)
+	if rec.Code != http.StatusOK || len(sent.messages) != 2 {
+		t.Fatalf("resend: expected 200 and a second message, got %d: %s", rec.Code, rec.Body)
+	}
+
+	// Access tokens cannot stand in for the emailed token, nor the other way round.
+	rec = doJSON(t, router, http.MethodPost, "/auth/verify-email", "", gin.H{"token": tokens.AccessToken})
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("access token: expected 401, got %d: %s", rec.Code, rec.Body)
+	}
+	token := sent.token(t, "ada@example.com")
+	rec = doJSON(t, router, http.MethodGet, "/api/organization", token, nil)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("verification token as access token: expected 401, got %d: %s", rec.Code, rec.Body)
+	}
+
+	rec = doJSON(t, router, http.MethodPost, "/auth/verify-email", "", gin.H{"token": token})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("verify: expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	if user, _ := h.Users.FindUserByEmail(context.Background(), "ada@example.com"); !user.EmailVerified {
+		t.Fatal("expected the address to be verified")
+	}
+
+	rec = doJSON(t, router, http.MethodPost, "/auth/verify-email/resend", tokens.AccessToken, nil)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("resend after verifying: expected 409, got %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestResendVerification(t *testing.T) {
+	router, h := newTestRouter(t)
+
+	// Without a relay sign-up still succeeds, but asking for the email again fails.
+	rec := doJSON(t, router, http.MethodPost, "/auth/signup", "", gin.H{"name": "Ada", "email": "ada@example.com", "password": "password123"})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("signup without a relay: expected 201, got %d: %s", rec.Code, rec.Body)
+	}
+	ada := tokenFor(t, "ada@example.com")
+	rec = doJSON(t, router, http.MethodPost, "/auth/verify-email/resend", ada, nil)
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("resend without a relay: expected 500, got %d: %s", rec.Code, rec.Body)
+	}
+
+	// Confirmation emails are limited per address.
+	sent := &outbox{}
+	h.Mailer = sent
+	for i := 1; i < 5; i++ {
+		if rec = doJSON(t, router, http.MethodPost, "/auth/verify-email/resend", ada, nil); rec.Code != http.StatusOK {
+			t.Fatalf("resend %d: expected 200, got %d: %s", i, rec.Code, rec.Body)
+		}
+	}
+	rec = doJSON(t, router, http.MethodPost, "/auth/verify-email/resend", ada, nil)
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("resend over the limit: expected 429 with Retry-After, got %d: %s", rec.Code, rec.Body)
+	}
+	if len(sent.messages) != 4 {
+		t.Fatalf("expected 4 messages, got %d", len(sent.messages))
+	}
+
+	rec = doJSON(t, router, http.MethodPost, "/auth/verify-email", "", gin.H{"token": "not-a-token"})
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("malformed token: expected 401, got %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestDomainClaims(t *testing.T) {
+	router, h := newTestRouter(t)
+	dns := dnsverify.Static{}
+	h.Resolver = dns
+	ada, bob := tokenFor(t, "ada@example.com"), tokenFor(t, "bob@example.com")
+
+	var created struct {
+		OrganizationID string `json:"organization_id"`
+	}
+	rec := doJSON(t, router, http.MethodPost, "/api/organization", ada, gin.H{"name": "Acme", "description": "Widgets"})
+	json.Unmarshal(rec.Body.Bytes(), &created)
+	acme := created.OrganizationID
+	path := "/api/organization/" + acme
+
+	type claimView struct {
+		Domain      string `json:"domain"`
+		Status      string `json:"status"`
+		RecordName  string `json:"record_name"`
+		RecordValue string `json:"record_value"`
+	}
+	rec = doJSON(t, router, http.MethodPost, path+"/domains", ada, gin.H{"domain": "Example.COM"})
+	var claim claimView
+	json.Unmarshal(rec.Body.Bytes(), &claim)
+	if rec.Code != http.StatusCreated || claim.Domain != "example.com" || claim.Status != models.DomainPending ||
+		claim.RecordName != "_orgapi-challenge.example.com" || !strings.HasPrefix(claim.RecordValue, "orgapi-verification=") {
+		t.Fatalf("claim: expected a pending claim with its record, got %d: %s", rec.Code, rec.Body)
+	}
+	for _, body := range []gin.H{{"domain": "example.com"}, {"domain": "not a domain"}} {
+		rec = doJSON(t, router, http.MethodPost, path+"/domains", ada, body)
+		if rec.Code != http.StatusConflict && rec.Code != http.StatusBadRequest {
+			t.Errorf("claim %v: expected it to be refused, got %d: %s", body, rec.Code, rec.Body)
+		}
+	}
+
+	// Verification waits for the record, and reports lookups that failed.
+	rec = doJSON(t, router, http.MethodPost, path+"/domains/example.com/verify", ada, nil)
+	if rec.Code != http.StatusConflict || !strings.Contains(rec.Body.String(), claim.RecordName) {
+		t.Fatalf("verify without a record: expected 409 naming the record, got %d: %s", rec.Code, rec.Body)
+	}
+	h.Resolver = brokenResolver{&net.DNSError{Err: "i/o timeout", IsTimeout: true}}
+	rec = doJSON(t, router, http.MethodPost, path+"/domains/example.com/verify", ada, nil)
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Fatalf("verify with a timeout: expected 504, got %d: %s", rec.Code, rec.Body)
+	}
+	h.Resolver = dns
+	dns[claim.RecordName] = []string{"v=spf1 -all", claim.RecordValue}
+	rec = doJSON(t, router, http.MethodPost, path+"/domains/example.com/verify", ada, nil)
+	json.Unmarshal(rec.Body.Bytes(), &claim)
+	if rec.Code != http.StatusOK || claim.Status != models.DomainVerified {
+		t.Fatalf("verify: expected a verified claim, got %d: %s", rec.Code, rec.Body)
+	}
+
+	// Another organization can claim the domain but not verify it too.
+	rec = doJSON(t, router, http.MethodPost, "/api/organization", bob, gin.H{"name": "Globex", "description": "Gadgets"})
+	json.Unmarshal(rec.Body.Bytes(), &created)
+	rec = doJSON(t, router, http.MethodPost, "/api/organization/"+created.OrganizationID+"/domains", bob, gin.H{"domain": "example.com"})
+	var rival claimView
+	json.Unmarshal(rec.Body.Bytes(), &rival)
+	dns[claim.RecordName] = append(dns[claim.RecordName], rival.RecordValue)
+	rec = doJSON(t, router, http.MethodPost, "/api/organization/"+created.OrganizationID+"/domains/example.com/verify", bob, nil)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("rival verify: expected 409, got %d: %s", rec.Code, rec.Body)
+	}
+	rec = doJSON(t, router, http.MethodGet, path+"/domains", bob, nil)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("non-member listing: expected 403, got %d: %s", rec.Code, rec.Body)
+	}
+
+	ctx := context.Background()
+	sent := &outbox{}
+	h.Mailer = sent
+	signup := func(email string) {
+		t.Helper()
+		rec := doJSON(t, router, http.MethodPost, "/auth/signup", "", gin.H{"name": "New", "email": email, "password": "password123"})
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("signup %s: expected 201, got %d: %s", email, rec.Code, rec.Body)
+		}
+	}
+	verify := func(email string) *models.DomainMatch {
+		t.Helper()
+		rec := doJSON(t, router, http.MethodPost, "/auth/verify-email", "", gin.H{"token": sent.token(t, email)})
+		var response models.VerifyEmailResponse
+		json.Unmarshal(rec.Body.Bytes(), &response)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("verify %s: expected 200, got %d: %s", email, rec.Code, rec.Body)
+		}
+		return response.DomainOrganization
+	}
+	isMember := func(email string) bool {
+		org, _ := h.Organizations.GetOrganizationById(ctx, acme)
+		for _, member := range org.InvitedUsers {
+			if member == email {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Joining by domain is off until the organization opts in.
+	signup("cy@example.com")
+	if match := verify("cy@example.com"); match != nil || isMember("cy@example.com") {
+		t.Fatalf("off: expected no offer and no membership, got %+v", match)
+	}
+
+	// Offered, people join themselves once they confirmed their address.
+	doJSON(t, router, http.MethodPatch, path+"/settings", ada, gin.H{"domain_join": "offer"})
+	signup("dee@example.com")
+	rec = doJSON(t, router, http.MethodPost, path+"/join", tokenFor(t, "dee@example.com"), nil)
+	if rec.Code != http.StatusForbidden || isMember("dee@example.com") {
+		t.Fatalf("join before confirming: expected 403, got %d: %s", rec.Code, rec.Body)
+	}
+	if match := verify("dee@example.com"); match == nil || match.OrganizationId != acme || match.Joined || isMember("dee@example.com") {
+		t.Fatalf("offer: expected an offer to join Acme, got %+v", match)
+	}
+	rec = doJSON(t, router, http.MethodPost, path+"/join", tokenFor(t, "dee@example.com"), nil)
+	if rec.Code != http.StatusOK || !isMember("dee@example.com") {
+		t.Fatalf("join: expected dee to become a member, got %d: %s", rec.Code, rec.Body)
+	}
+	rec = doJSON(t, router, http.MethodPost, path+"/join", tokenFor(t, "eve@elsewhere.org"), nil)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("join from another domain: expected 403, got %d: %s", rec.Code, rec.Body)
+	}
+
+	// Automatically, confirming the address is enough, and the member never gets the default role of invitations.
+	doJSON(t, router, http.MethodPatch, path+"/settings", ada, gin.H{"domain_join": "auto", "default_role": "admin"})
+	signup("fay@example.com")
+	if isMember("fay@example.com") {
+		t.Fatal("auto: expected fay to wait for confirmation")
+	}
+	if match := verify("fay@example.com"); match == nil || !match.Joined || !isMember("fay@example.com") {
+		t.Fatalf("auto: expected fay to join Acme, got %+v", match)
+	}
+	if org, _ := h.Organizations.GetOrganizationById(ctx, acme); org.IsAdmin("fay@example.com") {
+		t.Fatal("auto: expected fay to join as a plain member")
+	}
+
+	// Deleting the organization frees the domain.
+	doJSON(t, router, http.MethodDelete, path, ada, nil)
+	if _, err := h.Domains.FindVerifiedDomainClaim(ctx, "example.com"); !errors.Is(err, apperror.ErrNotFound) {
+		t.Fatalf("expected the claims to go with the organization, got %v", err)
+	}
+}
+
+func TestRemoveMemberAndLeave(t *testing.T) {
+	router, h := newTestRouter(t)
+	ctx := context.Background()
+	ada, bob, cat := tokenFor(t, "ada@example.com"), tokenFor(t, "bob@example.com"), tokenFor(t, "cat@example.com")
+
+	rec := doJSON(t, router, http.MethodPost, "/api/organization", ada, gin.H{"name": "Acme", "description": "Widgets"})
+	var created struct {
+		OrganizationID string `json:"organization_id"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &created)
+	orgPath := "/api/organization/" + created.OrganizationID
+	for _, email := range []string{"bob@example.com", "cat@example.com", "dan@example.com"} {
+		h.Organizations.InviteUserToOrganization(ctx, created.OrganizationID, email)
+	}
+	h.Organizations.InviteUsersToOrganization(ctx, created.OrganizationID, []string{"eve@example.com"}, models.InviteOptions{Admin: true})
+	rec = doJSON(t, router, http.MethodPost, orgPath+"/teams", ada, gin.H{"name": "Platform"})
+	var team models.Team
+	json.Unmarshal(rec.Body.Bytes(), &team)
+	h.Teams.SetTeamPermissions(ctx, created.OrganizationID, team.Id.Hex(), []string{models.PermissionInviteMembers})
+	doJSON(t, router, http.MethodPut, orgPath+"/teams/"+team.Id.Hex()+"/members/cat@example.com", ada, nil)
+
+	// Removing a member takes them off the organization's teams as well.
+	rec = doJSON(t, router, http.MethodDelete, orgPath+"/members/cat@example.com", bob, nil)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("remove as member: expected 403, got %d: %s", rec.Code, rec.Body)
+	}
+	rec = doJSON(t, router, http.MethodDelete, orgPath+"/members/cat@example.com", ada, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("remove: expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	if teams, _ := h.Teams.FindTeamsByMember(ctx, created.OrganizationID, "cat@example.com"); len(teams) != 0 {
+		t.Fatalf("expected cat to be off every team, got %d", len(teams))
+	}
+	rec = doJSON(t, router, http.MethodGet, orgPath, cat, nil)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("removed member reads: expected 403, got %d: %s", rec.Code, rec.Body)
+	}
+	rec = doJSON(t, router, http.MethodDelete, orgPath+"/members/cat@example.com", ada, nil)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("remove again: expected 404, got %d: %s", rec.Code, rec.Body)
+	}
+
+	// Admins cannot remove each other, and nobody can remove the owner.
+	eve := tokenFor(t, "eve@example.com")
+	h.Organizations.InviteUsersToOrganization(ctx, created.OrganizationID, []string{"fay@example.com"}, models.InviteOptions{Admin: true})
+	rec = doJSON(t, router, http.MethodDelete, orgPath+"/members/fay@example.com", eve, nil)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("admin removes admin: expected 403, got %d: %s", rec.Code, rec.Body)
+	}
+	rec = doJSON(t, router, http.MethodDelete, orgPath+"/members/ada@example.com", eve, nil)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("remove the owner: expected 409, got %d: %s", rec.Code, rec.Body)
+	}
+	rec = doJSON(t, router, http.MethodDelete, orgPath+"/members/fay@example.com", ada, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("owner removes admin: expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+
+	// Members leave on their own; the owner has to hand the organization over first.
+	rec = doJSON(t, router, http.MethodPost, orgPath+"/leave", bob, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("leave: expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	rec = doJSON(t, router, http.MethodPost, orgPath+"/leave", bob, nil)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("leave again: expected 403, got %d: %s", rec.Code, rec.Body)
+	}
+	rec = doJSON(t, router, http.MethodPost, orgPath+"/leave", ada, nil)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("owner leaves: expected 409, got %d: %s", rec.Code, rec.Body)
+	}
+
+	// Members of the parent belong to its children through it, so they leave the parent instead.
+	rec = doJSON(t, router, http.MethodPost, "/api/organization", ada, gin.H{"name": "Subsidiary", "description": "Child", "parent_id": created.OrganizationID})
+	var child struct {
+		OrganizationID string `json:"organization_id"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &child)
+	rec = doJSON(t, router, http.MethodPost, "/api/organization/"+child.OrganizationID+"/leave", tokenFor(t, "dan@example.com"), nil)
+	if rec.Code != http.StatusConflict || !strings.Contains(rec.Body.String(), "inherited") {
+		t.Fatalf("leave an inherited membership: expected 409, got %d: %s", rec.Code, rec.Body)
+	}
+
+	org, _ := h.Organizations.GetOrganizationById(ctx, created.OrganizationID)
+	if len(org.InvitedUsers) != 3 || org.IsAdmin("fay@example.com") {
+		t.Fatalf("expected ada, dan and eve to remain, got members %v and admins %v", org.InvitedUsers, org.Admins)
+	}
+}
+
+func TestWebhookAccessAndTargets(t *testing.T) {
+	router, h := newTestRouter(t)
+	ada, bob := tokenFor(t, "ada@example.com"), tokenFor(t, "bob@example.com")
+
+	rec := doJSON(t, router, http.MethodPost, "/api/organization", ada, gin.H{"name": "Acme", "description": "Widgets"})
+	var created struct {
+		OrganizationID string `json:"organization_id"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &created)
+	h.Organizations.InviteUserToOrganization(context.Background(), created.OrganizationID, "bob@example.com")
+	path := "/api/organization/" + created.OrganizationID + "/webhooks"
+
+	rec = doJSON(t, router, http.MethodPost, path, ada, gin.H{"url": "http://169.254.169.254/latest/meta-data"})
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), `"rule":"public_address"`) {
+		t.Fatalf("internal target: expected 400, got %d: %s", rec.Code, rec.Body)
+	}
+
+	// Plain members cannot see the webhooks or their delivery log.
+	rec = doJSON(t, router, http.MethodGet, path, bob, nil)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("member lists webhooks: expected 403, got %d: %s", rec.Code, rec.Body)
+	}
+	rec = doJSON(t, router, http.MethodGet, path, ada, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("admin lists webhooks: expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestSendTestWebhookEvent(t *testing.T) {
+	router, h := newTestRouter(t)
+	ada := tokenFor(t, "ada@example.com")
+	// The receiver listens on loopback, which the default client refuses to dial.
+	h.Dispatcher.Client = &http.Client{Timeout: time.Second}
+
+	var verified, failing atomic.Bool
+	var received webhook.Payload
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp, _ := strconv.ParseInt(r.Header.Get(webhook.TimestampHeader), 10, 64)
+		verified.Store(webhook.Verify("0123456789abcdef", timestamp, body, r.Header.Get(webhook.SignatureHeader)))
+		json.Unmarshal(body, &received)
+		if failing.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	rec := doJSON(t, router, http.MethodPost, "/api/organization", ada, gin.H{"name": "Acme", "description": "Widgets"})
+	var created struct {
+		OrganizationID string `json:"organization_id"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &created)
+	// The API refuses loopback targets, so the subscription is stored directly.
+	hookID, _ := h.Webhooks.CreateWebhook(context.Background(), &models.Webhook{
+		OrganizationId: created.OrganizationID, URL: receiver.URL, Secret: "0123456789abcdef", Active: true,
+	})
+	path := "/api/organization/" + created.OrganizationID + "/webhooks/" + hookID
+
+	rec = doJSON(t, router, http.MethodPost, path+"/test", ada, nil)
+	var delivery models.WebhookDelivery
+	json.Unmarshal(rec.Body.Bytes(), &delivery)
+	if rec.Code != http.StatusOK || delivery.Status != models.DeliverySucceeded || delivery.ResponseCode != http.StatusNoContent {
+		t.Fatalf("test event: expected a successful delivery, got %d: %s", rec.Code, rec.Body)
+	}
+	if !verified.Load() {
+		t.Fatal("receiver could not verify the signature")
+	}
+	if received.Event != webhook.EventTest || received.OrganizationId != created.OrganizationID || received.Id != delivery.Id.Hex() {
+		t.Fatalf("unexpected payload: %+v", received)
+	}
+
+	// Test events are attempted once; a failure is reported and logged rather than retried.
+	failing.Store(true)
+	rec = doJSON(t, router, http.MethodPost, path+"/test", ada, nil)
+	json.Unmarshal(rec.Body.Bytes(), &delivery)
+	if rec.Code != http.StatusOK || delivery.Status != models.DeliveryDead || delivery.Attempts != 1 {
+		t.Fatalf("failing receiver: expected a dead delivery after one attempt, got %d: %s", rec.Code, rec.Body)
+	}
+	rec = doJSON(t, router, http.MethodGet, path+"/deliveries", ada, nil)
+	var deliveries []models.WebhookDelivery
+	json.Unmarshal(rec.Body.Bytes(), &deliveries)
+	if rec.Code != http.StatusOK || len(deliveries) != 2 {
+		t.Fatalf("deliveries: expected both test events in the log, got %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestDeleteOrganizationLeavesNothingBehind(t *testing.T) {
+	ctx := context.Background()
+	router, h := newTestRouter(t)
+	ada := tokenFor(t, "ada@example.com")
+
+	rec := doJSON(t, router, http.MethodPost, "/api/organization", ada, gin.H{"name": "Acme", "description": "Widgets"})
+	var created struct {
+		OrganizationID string `json:"organization_id"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &created)
+	path := "/api/organization/" + created.OrganizationID
+	doJSON(t, router, http.MethodPost, path+"/teams", ada, gin.H{"name": "Platform"})
+	hook := &models.Webhook{OrganizationId: created.OrganizationID, URL: "https://hooks.example.com", Active: true}
+	h.Webhooks.CreateWebhook(ctx, hook)
+	h.Webhooks.SaveDelivery(ctx, &models.WebhookDelivery{WebhookId: hook.Id, OrganizationId: created.OrganizationID})
+
+	// Another organization's team and webhook stay.
+	othersID, _ := h.Organizations.CreateOrganization(ctx, &models.Organization{Name: "Globex", Description: "Gadgets", Owner: "bob@example.com", InvitedUsers: []string{"bob@example.com"}})
+	h.Teams.CreateTeam(ctx, &models.Team{OrganizationId: othersID, Name: "Platform"})
+	h.Webhooks.CreateWebhook(ctx, &models.Webhook{OrganizationId: othersID, URL: "https://hooks.example.com", Active: true})
+
+	rec = doJSON(t, router, http.MethodDelete, path, ada, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("delete: expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	teams, _ := h.Teams.GetTeamsByOrganization(ctx, created.OrganizationID)
+	hooks, _ := h.Webhooks.GetWebhooksByOrganization(ctx, created.OrganizationID)
+	deliveries, _ := h.Webhooks.GetDeliveriesByWebhook(ctx, hook.Id.Hex())
+	if len(teams) != 0 || len(hooks) != 0 || len(deliveries) != 0 {
+		t.Fatalf("expected nothing left, got %d teams, %d webhooks and %d deliveries", len(teams), len(hooks), len(deliveries))
+	}
+	teams, _ = h.Teams.GetTeamsByOrganization(ctx, othersID)
+	hooks, _ = h.Webhooks.GetWebhooksByOrganization(ctx, othersID)
+	if len(teams) != 1 || len(hooks) != 1 {
+		t.Fatalf("expected Globex to keep its team and webhook, got %d and %d", len(teams), len(hooks))
+	}
+}
@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/organization_api/pkg/health"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LivenessHandler reports that the process is running; it never touches dependencies.
+func (h *Handler) LivenessHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": health.StatusUp})
+}
+
+// ReadinessHandler reports whether every dependency is reachable, with per-dependency status and latency.
+func (h *Handler) ReadinessHandler(c *gin.Context) {
+	report := h.Health.Ready(c.Request.Context())
+
+	status := http.StatusOK
+	if report.Status != health.StatusUp {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, report)
+}
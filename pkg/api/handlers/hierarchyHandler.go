@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/organization_api/pkg/api/middleware"
+	"github.com/organization_api/pkg/apperror"
+	"github.com/organization_api/pkg/database/mongodb/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// GetChildOrganizationsHandler lists the direct children of an organization.
+func (h *Handler) GetChildOrganizationsHandler(c *gin.Context) {
+	children, err := h.Organizations.GetChildOrganizations(c.Request.Context(), c.Param("organization_id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, organizationSummaries(children))
+}
+
+// GetAncestorsHandler lists the parent of an organization, its parent's parent and so on, nearest first.
+func (h *Handler) GetAncestorsHandler(c *gin.Context) {
+	organization, err := h.Organizations.GetOrganizationById(c.Request.Context(), c.Param("organization_id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	ancestors, err := middleware.Ancestors(c.Request.Context(), h.Organizations, organization)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, organizationSummaries(ancestors))
+}
+
+// SetParentHandler moves an organization under another one, or to the top level. The caller must
+// be an admin of the organization, of the new parent and of the parent it leaves.
+func (h *Handler) SetParentHandler(c *gin.Context) {
+	organizationID := c.Param("organization_id")
+	var requestBody models.ParentRequestBody
+
+	if err := bindJSON(c, &requestBody); err != nil {
+		c.Error(err)
+		return
+	}
+
+	organization, err := h.Organizations.GetOrganizationById(c.Request.Context(), organizationID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if organization.ParentId == requestBody.ParentId {
+		c.JSON(http.StatusOK, organizationSummary(organization))
+		return
+	}
+
+	// Leaving a parent takes the organization out of its admins' reach, so they must agree.
+	if organization.ParentId != "" {
+		if err := h.requireParentAdmin(c, organization.ParentId); err != nil {
+			c.Error(err)
+			return
+		}
+	}
+	if requestBody.ParentId != "" {
+		if err := h.checkNewParent(c, organization, requestBody.ParentId); err != nil {
+			c.Error(err)
+			return
+		}
+	}
+
+	if err := h.Organizations.SetParent(c.Request.Context(), organizationID, requestBody.ParentId); err != nil {
+		c.Error(err)
+		return
+	}
+	organization.ParentId = requestBody.ParentId
+
+	c.JSON(http.StatusOK, organizationSummary(organization))
+}
+
+// checkNewParent verifies the caller may attach organization under parentID without creating a
+// cycle or exceeding the maximum depth.
+func (h *Handler) checkNewParent(c *gin.Context, organization *models.Organization, parentID string) error {
+	if parentID == organization.Id.Hex() {
+		return apperror.Conflict("An organization cannot be its own parent")
+	}
+	if err := h.requireParentAdmin(c, parentID); err != nil {
+		return err
+	}
+
+	parent, err := h.Organizations.GetOrganizationById(c.Request.Context(), parentID)
+	if err != nil {
+		return err
+	}
+	ancestors, err := middleware.Ancestors(c.Request.Context(), h.Organizations, parent)
+	if err != nil {
+		return err
+	}
+	for _, ancestor := range ancestors {
+		if ancestor.Id == organization.Id {
+			return apperror.Conflict("Moving the organization under one of its descendants would create a cycle")
+		}
+	}
+
+	// The parent's depth plus the height of the subtree being moved must stay within bounds.
+	height, err := h.subtreeHeight(c.Request.Context(), organization.Id.Hex(), 1)
+	if err != nil {
+		return err
+	}
+	if len(ancestors)+1+height > middleware.MaxOrganizationDepth {
+		return apperror.Conflict("Organization hierarchy cannot be deeper than %d levels", middleware.MaxOrganizationDepth)
+	}
+	return nil
+}
+
+// checkChildDepth verifies the caller may create a child of parentID without exceeding the maximum depth.
+func (h *Handler) checkChildDepth(c *gin.Context, parentID string) error {
+	if err := h.requireParentAdmin(c, parentID); err != nil {
+		return err
+	}
+
+	parent, err := h.Organizations.GetOrganizationById(c.Request.Context(), parentID)
+	if err != nil {
+		return err
+	}
+	ancestors, err := middleware.Ancestors(c.Request.Context(), h.Organizations, parent)
+	if err != nil {
+		return err
+	}
+	if len(ancestors)+2 > middleware.MaxOrganizationDepth {
+		return apperror.Conflict("Organization hierarchy cannot be deeper than %d levels", middleware.MaxOrganizationDepth)
+	}
+	return nil
+}
+
+// requireParentAdmin reports a forbidden error unless the caller is an admin of parentID or its ancestors.
+// A missing parent is a validation error rather than a missing resource.
+func (h *Handler) requireParentAdmin(c *gin.Context, parentID string) error {
+	parent, err := h.Organizations.GetOrganizationById(c.Request.Context(), parentID)
+	if errors.Is(err, apperror.ErrNotFound) {
+		return apperror.Validation("Parent organization %s does not exist", parentID)
+	}
+	if err != nil {
+		return err
+	}
+
+	allowed, err := middleware.HasPermission(c.Request.Context(), h.Organizations, h.Teams, parent, c.GetString(middleware.CallerEmailKey), "")
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return apperror.Forbidden("Only admins of the parent organization may do this")
+	}
+	return nil
+}
+
+// subtreeHeight counts the levels of the tree rooted at organizationID, which sits at level depth
+// of the walk; the walk gives up once it passes the maximum depth.
+func (h *Handler) subtreeHeight(ctx context.Context, organizationID string, depth int) (int, error) {
+	if depth > middleware.MaxOrganizationDepth {
+		return depth, nil
+	}
+
+	children, err := h.Organizations.GetChildOrganizations(ctx, organizationID)
+	if err != nil {
+		return 0, err
+	}
+	height := 1
+	for _, child := range children {
+		childHeight, err := h.subtreeHeight(ctx, child.Id.Hex(), depth+1)
+		if err != nil {
+			return 0, err
+		}
+		if childHeight+1 > height {
+			height = childHeight + 1
+		}
+	}
+	return height, nil
+}
+
+// visibleOrganizations returns the organizations email belongs to and all of their descendants.
+func (h *Handler) visibleOrganizations(ctx context.Context, email string) ([]*models.Organization, error) {
+	memberOf, err := h.Organizations.FindOrganizationsByMember(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+	return h.withDescendants(ctx, memberOf)
+}
+
+// administeredOrganizations returns the organizations email owns or administers and all of their descendants.
+func (h *Handler) administeredOrganizations(ctx context.Context, email string) ([]*models.Organization, error) {
+	memberOf, err := h.Organizations.FindOrganizationsByMember(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+
+	var administered []*models.Organization
+	for _, organization := range memberOf {
+		if organization.IsAdmin(email) {
+			administered = append(administered, organization)
+		}
+	}
+	return h.withDescendants(ctx, administered)
+}
+
+// withDescendants returns roots and every organization below them, level by level, so parents
+// always come before their children.
+func (h *Handler) withDescendants(ctx context.Context, roots []*models.Organization) ([]*models.Organization, error) {
+	seen := make(map[primitive.ObjectID]bool)
+	var organizations []*models.Organization
+	level := roots
+	for depth := 0; len(level) > 0 && depth < middleware.MaxOrganizationDepth; depth++ {
+		var next []*models.Organization
+		for _, organization := range level {
+			if seen[organization.Id] {
+				continue
+			}
+			seen[organization.Id] = true
+			organizations = append(organizations, organization)
+
+			children, err := h.Organizations.GetChildOrganizations(ctx, organization.Id.Hex())
+			if err != nil {
+				return nil, err
+			}
+			next = append(next, children...)
+		}
+		level = next
+	}
+	return organizations, nil
+}
+
+// organizationSummary is the public view of an organization, without its member list.
+func organizationSummary(organization *models.Organization) models.Organization {
+	return models.Organization{
+		Id:          organization.Id,
+		Slug:        organization.Slug,
+		Name:        organization.Name,
+		Description: organization.Description,
+		Owner:       organization.Owner,
+		Admins:      organization.Admins,
+		ParentId:    organization.ParentId,
+		Metadata:    organization.Metadata,
+		Tags:        organization.Tags,
+	}
+}
+
+func organizationSummaries(organizations []*models.Organization) []models.Organization {
+	summaries := make([]models.Organization, 0, len(organizations))
+	for _, organization := range organizations {
+		summaries = append(summaries, organizationSummary(organization))
+	}
+	return summaries
+}
@@ -0,0 +1,368 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/organization_api/pkg/api/middleware"
+	"github.com/organization_api/pkg/apperror"
+	"github.com/organization_api/pkg/bulk"
+	"github.com/organization_api/pkg/database/mongodb/models"
+	"github.com/organization_api/pkg/slug"
+	"github.com/organization_api/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Limits on imports and exports.
+const (
+	maxImportBytes = 5 << 20
+	maxImportRows  = 1000
+	// syncImportRows is the largest import run while the caller waits; larger ones run as jobs.
+	syncImportRows = 100
+	// importProgressRows is how many rows a job processes between saving its progress.
+	importProgressRows = 25
+	importJobRetention = 7 * 24 * time.Hour
+	// exportFlushRows is how many records an export writes between flushes to the client.
+	exportFlushRows = 100
+)
+
+// ImportOrganizationsHandler creates organizations, with their members, from a CSV or NDJSON file.
+// Small files are imported at once; large ones start a job whose progress can be polled.
+func (h *Handler) ImportOrganizationsHandler(c *gin.Context) {
+	format, ok := bulk.FormatOf(c.ContentType())
+	if !ok {
+		c.Error(apperror.BadRequest("Send the file as text/csv or application/x-ndjson, not %q", c.ContentType()))
+		return
+	}
+	var request models.ImportRequest
+	if err := bindQuery(c, &request); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportBytes)
+	rows, err := bulk.Read(c.Request.Body, format, maxImportRows)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		c.Error(apperror.BadRequest("An import file may be at most %d MB", maxImportBytes>>20).Wrap(err))
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if len(rows) == 0 {
+		c.Error(apperror.Validation("The file holds no organizations"))
+		return
+	}
+
+	importer := h.newImporter(c.GetString(middleware.CallerEmailKey), request.DryRun)
+	if request.DryRun || (!request.Async && len(rows) <= syncImportRows) {
+		c.JSON(http.StatusOK, importer.run(c.Request.Context(), rows, nil))
+		return
+	}
+	h.startImportJob(c, importer, rows)
+}
+
+// startImportJob records a job for the import and runs it in the background.
+func (h *Handler) startImportJob(c *gin.Context, importer *importer, rows []bulk.Row) {
+	now := time.Now().UTC()
+	job := &models.ImportJob{
+		Owner:        importer.caller,
+		Status:       models.ImportJobRunning,
+		ImportReport: models.ImportReport{Total: len(rows), Rows: []models.ImportRowResult{}},
+		CreatedAt:    now,
+		UpdatedAt:    now,
+		ExpiresAt:    now.Add(importJobRetention),
+	}
+	jobID, err := h.ImportJobs.CreateImportJob(c.Request.Context(), job)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	// The job changes once it runs, so respond with it as created.
+	created := *job
+	err = h.background.start(c.Request.Context(), func(ctx context.Context) {
+		h.runImportJob(ctx, job, importer, rows)
+	})
+	if err != nil {
+		created.Status, created.Error = models.ImportJobFailed, "The import did not start"
+		h.ImportJobs.UpdateImportJob(c.Request.Context(), &created)
+		c.Error(err)
+		return
+	}
+
+	c.Header("Location", "/api/organization/import/"+jobID)
+	c.JSON(http.StatusAccepted, created)
+}
+
+// runImportJob imports rows, saving the job's progress as it goes.
+func (h *Handler) runImportJob(ctx context.Context, job *models.ImportJob, importer *importer, rows []bulk.Row) {
+	save := func(ctx context.Context) {
+		job.UpdatedAt = time.Now().UTC()
+		if err := h.ImportJobs.UpdateImportJob(ctx, job); err != nil {
+			slog.ErrorContext(ctx, "import: failed to save job progress", "job_id", job.Id.Hex(), "error", err)
+		}
+	}
+
+	job.ImportReport = importer.run(ctx, rows, func(report models.ImportReport) {
+		if report.Processed%importProgressRows == 0 {
+			job.ImportReport = report
+			save(ctx)
+		}
+	})
+
+	job.Status = models.ImportJobCompleted
+	if job.Processed < job.Total {
+		job.Status, job.Error = models.ImportJobFailed, "The import stopped before every row was processed"
+	}
+	save(context.WithoutCancel(ctx))
+}
+
+// GetImportJobHandler reports the progress of an import job started by the caller.
+func (h *Handler) GetImportJobHandler(c *gin.Context) {
+	job, err := h.ImportJobs.GetImportJob(c.Request.Context(), c.GetString(middleware.CallerEmailKey), c.Param("job_id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// ExportOrganizationsHandler streams the organizations the caller owns or administers, their
+// descendants and their members, in the format imports accept.
+func (h *Handler) ExportOrganizationsHandler(c *gin.Context) {
+	var request models.ExportRequest
+	if err := bindQuery(c, &request); err != nil {
+		c.Error(err)
+		return
+	}
+
+	organizations, err := h.administeredOrganizations(c.Request.Context(), c.GetString(middleware.CallerEmailKey))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	// Children name exported parents by slug, so the file can be imported elsewhere.
+	slugs := make(map[string]string, len(organizations))
+	for _, organization := range organizations {
+		slugs[organization.Id.Hex()] = organization.Slug
+	}
+
+	c.Header("Content-Type", bulk.ContentType(request.Format))
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="organizations.%s"`, request.Format))
+	c.Status(http.StatusOK)
+
+	writer := bulk.NewWriter(c.Writer, request.Format)
+	for i, organization := range organizations {
+		parent := organization.ParentId
+		if parentSlug := slugs[parent]; parentSlug != "" {
+			parent = parentSlug
+		}
+		err := writer.Write(models.OrganizationRecord{
+			Name:        organization.Name,
+			Description: organization.Description,
+			Slug:        organization.Slug,
+			Parent:      parent,
+			Members:     organization.InvitedUsers,
+		})
+		if err == nil && (i+1)%exportFlushRows == 0 {
+			err = writer.Flush()
+			c.Writer.Flush()
+		}
+		if err != nil {
+			// The response has started, so the client can only notice the truncated file.
+			slog.ErrorContext(c.Request.Context(), "export: failed to write", "error", err)
+			return
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		slog.ErrorContext(c.Request.Context(), "export: failed to write", "error", err)
+	}
+}
+
+// importer imports the rows of one file on behalf of a caller, who owns what it creates.
+type importer struct {
+	h      *Handler
+	caller string
+	dryRun bool
+	// known maps the slugs of rows already imported, and of parents already looked up, to where
+	// they sit in the hierarchy.
+	known map[string]knownOrganization
+}
+
+// knownOrganization is an organization rows can name as their parent. In a dry run, rows have no ID.
+type knownOrganization struct {
+	id    string
+	level int
+}
+
+func (h *Handler) newImporter(caller string, dryRun bool) *importer {
+	return &importer{h: h, caller: caller, dryRun: dryRun, known: make(map[string]knownOrganization)}
+}
+
+// run imports rows in order, calling progress after each one, until every row is processed or
+// ctx is done.
+func (im *importer) run(ctx context.Context, rows []bulk.Row, progress func(models.ImportReport)) models.ImportReport {
+	report := models.ImportReport{DryRun: im.dryRun, Total: len(rows), Rows: make([]models.ImportRowResult, 0, len(rows))}
+	for _, row := range rows {
+		if ctx.Err() != nil {
+			break
+		}
+
+		result := im.importRow(ctx, row)
+		report.Rows = append(report.Rows, result)
+		report.Processed++
+		switch result.Status {
+		case models.ImportRowCreated:
+			report.Created++
+		case models.ImportRowInvalid, models.ImportRowFailed:
+			report.Failed++
+		}
+		if progress != nil {
+			progress(report)
+		}
+	}
+	return report
+}
+
+func (im *importer) importRow(ctx context.Context, row bulk.Row) models.ImportRowResult {
+	result := models.ImportRowResult{Row: row.Number}
+	reject := func(status string, errs ...models.ImportFieldError) models.ImportRowResult {
+		result.Status, result.Errors = status, errs
+		return result
+	}
+
+	if row.Err != nil {
+		return reject(models.ImportRowInvalid, models.ImportFieldError{Message: "Row " + row.Err.Error()})
+	}
+	record := row.Record
+	if errs := recordErrors(record); len(errs) > 0 {
+		return reject(models.ImportRowInvalid, errs...)
+	}
+
+	level, parentID := 1, ""
+	if record.Parent != "" {
+		parent, err := im.parent(ctx, record.Parent)
+		if errors.Is(err, apperror.ErrValidation) {
+			return reject(models.ImportRowInvalid, models.ImportFieldError{Field: "parent", Message: rowErrorMessage(err)})
+		}
+		if err != nil {
+			return reject(models.ImportRowFailed, models.ImportFieldError{Field: "parent", Message: rowErrorMessage(err)})
+		}
+		level, parentID = parent.level+1, parent.id
+		if level > middleware.MaxOrganizationDepth {
+			return reject(models.ImportRowInvalid, models.ImportFieldError{
+				Field:   "parent",
+				Message: fmt.Sprintf("Organization hierarchy cannot be deeper than %d levels", middleware.MaxOrganizationDepth),
+			})
+		}
+	}
+
+	key := record.Slug
+	if key == "" {
+		key = slug.Make(record.Name)
+	}
+	if im.dryRun {
+		im.known[key] = knownOrganization{level: level}
+		result.Status = models.ImportRowValid
+		return result
+	}
+
+	organization := &models.Organization{
+		Name:         record.Name,
+		Description:  record.Description,
+		ParentId:     parentID,
+		Owner:        im.caller,
+		InvitedUsers: []string{im.caller},
+	}
+	organizationID, err := im.h.Organizations.CreateOrganization(ctx, organization)
+	if err != nil {
+		slog.WarnContext(ctx, "import: failed to create organization", "row", row.Number, "error", err)
+		return reject(models.ImportRowFailed, models.ImportFieldError{Message: rowErrorMessage(err)})
+	}
+
+	created := knownOrganization{id: organizationID, level: level}
+	im.known[key] = created
+	im.known[organization.Slug] = created
+	result.OrganizationId, result.Slug = organizationID, organization.Slug
+
+	// Members are invited like any others; the organization exists even if that fails, so the
+	// row reports where it is.
+	if err := im.h.inviteInitialMembers(ctx, organizationID, im.caller, record.Members); err != nil {
+		slog.WarnContext(ctx, "import: failed to invite members", "row", row.Number, "error", err)
+		return reject(models.ImportRowFailed, models.ImportFieldError{Field: "members", Message: rowErrorMessage(err)})
+	}
+	result.Status = models.ImportRowCreated
+	return result
+}
+
+// parent finds the organization reference names: an earlier row, or an existing organization the
+// caller administers. A parent rows cannot use is reported as a validation error.
+func (im *importer) parent(ctx context.Context, reference string) (knownOrganization, error) {
+	if known, ok := im.known[reference]; ok {
+		return known, nil
+	}
+
+	var parent *models.Organization
+	var err error
+	if primitive.IsValidObjectID(reference) {
+		parent, err = im.h.Organizations.GetOrganizationById(ctx, reference)
+	} else {
+		parent, err = im.h.Organizations.GetOrganizationBySlug(ctx, reference)
+	}
+	if errors.Is(err, apperror.ErrNotFound) {
+		return knownOrganization{}, apperror.Validation("%q is neither an earlier row nor an existing organization", reference)
+	}
+	if err != nil {
+		return knownOrganization{}, err
+	}
+
+	allowed, err := middleware.HasPermission(ctx, im.h.Organizations, im.h.Teams, parent, im.caller, "")
+	if err != nil {
+		return knownOrganization{}, err
+	}
+	if !allowed {
+		return knownOrganization{}, apperror.Validation("Only admins of the parent organization may add children to it")
+	}
+	ancestors, err := middleware.Ancestors(ctx, im.h.Organizations, parent)
+	if err != nil {
+		return knownOrganization{}, err
+	}
+
+	known := knownOrganization{id: parent.Id.Hex(), level: len(ancestors) + 1}
+	im.known[reference] = known
+	return known, nil
+}
+
+// recordErrors enforces the `validate` tags of a record, field by field.
+func recordErrors(record models.OrganizationRecord) []models.ImportFieldError {
+	var appErr *apperror.Error
+	if !errors.As(utils.ValidationError(utils.Validator().Struct(record)), &appErr) {
+		return nil
+	}
+
+	errs := make([]models.ImportFieldError, 0, len(appErr.Fields))
+	for _, field := range appErr.Fields {
+		errs = append(errs, models.ImportFieldError{Field: field.Field, Message: field.Message})
+	}
+	return errs
+}
+
+// rowErrorMessage returns what the caller may be told about why a row was not imported.
+func rowErrorMessage(err error) string {
+	var appErr *apperror.Error
+	if errors.As(err, &appErr) && appErr.Code != apperror.CodeInternal {
+		return appErr.Message
+	}
+	return "The organization could not be saved"
+}
@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/organization_api/pkg/apperror"
+	"github.com/organization_api/pkg/database/mongodb/models"
+	"github.com/organization_api/pkg/utils"
+	"github.com/organization_api/pkg/webhook"
+
+	"github.com/gin-gonic/gin"
+)
+
+// batchInviteRateKey prefixes the rate limit counter of each organization's batch invitations.
+const batchInviteRateKey = "batch_invite:"
+
+// BatchInviteHandler invites several users to an organization in one write and reports the outcome
+// of every email, in the order given. Each organization may send a limited number of batches per window.
+func (h *Handler) BatchInviteHandler(c *gin.Context) {
+	organizationID := c.Param("organization_id")
+	var request models.BatchInviteRequest
+
+	if err := bindJSON(c, &request); err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := h.allowBatchInvite(c, organizationID); err != nil {
+		c.Error(err)
+		return
+	}
+
+	settings, err := h.Settings.GetSettings(c.Request.Context(), organizationID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	// Sort out malformed, disallowed and repeated emails before anything is written.
+	results := make([]models.InvitationResult, len(request.Emails))
+	seen := make(map[string]bool, len(request.Emails))
+	var emails []string
+	for i, email := range request.Emails {
+		email = normalizeEmail(email)
+		results[i] = models.InvitationResult{Email: email}
+		switch {
+		case utils.Validator().Struct(models.InviterequestBody{UserEmail: email}) != nil:
+			results[i].Status = models.InvitationInvalid
+		case !settings.AllowsEmail(email):
+			results[i].Status = models.InvitationDomainNotAllowed
+		case seen[email]:
+			results[i].Status = models.InvitationDuplicate
+		default:
+			seen[email] = true
+			emails = append(emails, email)
+		}
+	}
+
+	var invited []string
+	var pending map[string]time.Time
+	if len(emails) > 0 {
+		invited, pending, err = h.inviteUsers(c.Request.Context(), organizationID, settings, emails)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}
+
+	newlyInvited := make(map[string]bool, len(invited))
+	for _, email := range invited {
+		newlyInvited[email] = true
+		webhook.Publish(c.Request.Context(), organizationID, webhook.EventMemberInvited, gin.H{"user_email": email})
+	}
+	for i := range results {
+		if results[i].Status != "" {
+			continue
+		}
+		if newlyInvited[results[i].Email] {
+			results[i].Status = models.InvitationInvited
+			if expiresAt, ok := pending[results[i].Email]; ok {
+				results[i].ExpiresAt = &expiresAt
+			}
+		} else {
+			results[i].Status = models.InvitationAlreadyMember
+		}
+	}
+
+	// Respond with the outcome of every email and how many were invited.
+	c.JSON(http.StatusOK, gin.H{"results": results, "invited": len(invited)})
+}
+
+// inviteUsers invites emails the way the organization's settings say: newly invited members get the
+// default role, and those without an account get an invitation that lapses unless they sign up in
+// time. It returns the emails that were not members yet, and when the pending ones among them lapse.
+func (h *Handler) inviteUsers(ctx context.Context, organizationID string, settings *models.OrganizationSettings, emails []string) ([]string, map[string]time.Time, error) {
+	registered, err := h.Users.FindRegisteredEmails(ctx, emails)
+	if err != nil {
+		return nil, nil, err
+	}
+	hasAccount := make(map[string]bool, len(registered))
+	for _, email := range registered {
+		hasAccount[email] = true
+	}
+
+	opts := models.InviteOptions{Admin: settings.DefaultRole == models.MemberRoleAdmin}
+	expiresAt := time.Now().Add(settings.InvitationExpiry()).UTC()
+	for _, email := range emails {
+		if !hasAccount[email] {
+			opts.Pending = append(opts.Pending, models.Invitation{Email: email, ExpiresAt: expiresAt})
+		}
+	}
+
+	invited, err := h.Organizations.InviteUsersToOrganization(ctx, organizationID, emails, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pending := make(map[string]time.Time)
+	for _, email := range invited {
+		if !hasAccount[email] {
+			pending[email] = expiresAt
+		}
+	}
+	return invited, pending, nil
+}
+
+// inviteInitialMembers invites the members an organization is created or imported with. They go
+// through its settings like any other invitation, rather than being written in with the organization.
+func (h *Handler) inviteInitialMembers(ctx context.Context, organizationID, owner string, emails []string) error {
+	var members []string
+	for _, email := range emails {
+		if email = normalizeEmail(email); email != owner {
+			members = appendMissing(members, email)
+		}
+	}
+	if len(members) == 0 {
+		return nil
+	}
+
+	settings, err := h.Settings.GetSettings(ctx, organizationID)
+	if err != nil {
+		return err
+	}
+	for _, email := range members {
+		if !settings.AllowsEmail(email) {
+			return apperror.Validation("The organization does not allow members from the domain of %s", email)
+		}
+	}
+	_, _, err = h.inviteUsers(ctx, organizationID, settings, members)
+	return err
+}
+
+// normalizeEmail trims an email. Case is kept: accounts and tokens keep the email as it was signed
+// up with, so an invitation has to match it exactly.
+func normalizeEmail(email string) string {
+	return strings.TrimSpace(email)
+}
+
+// allowBatchInvite counts a batch against the organization's rate limit, setting Retry-After when
+// the limit is reached. The limit counts batches, not emails: a batch costs one whatever its size.
+func (h *Handler) allowBatchInvite(c *gin.Context, organizationID string) error {
+	limits := h.invitations.Load()
+	limit, window := limits.BatchRateLimit, limits.BatchRateWindow
+	allowed, retryAfter, err := h.RateLimits.Allow(c.Request.Context(), batchInviteRateKey+organizationID, limit, window)
+	if err != nil {
+		return apperror.Internal("Could not check the invitation rate limit").Wrap(err)
+	}
+	if allowed {
+		return nil
+	}
+
+	seconds := int(math.Max(1, math.Ceil(retryAfter.Seconds())))
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	return apperror.RateLimited("The organization may send %d batch invitations per %s; try again in %d seconds",
+		limit, window, seconds)
+}
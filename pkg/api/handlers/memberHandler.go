@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"slices"
+
+	"github.com/organization_api/pkg/api/middleware"
+	"github.com/organization_api/pkg/apperror"
+	"github.com/organization_api/pkg/database/mongodb/models"
+	"github.com/organization_api/pkg/webhook"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RemoveMemberHandler takes the member whose email is in the user parameter out of an organization.
+// Admins can remove members; only the owner can remove another admin, and nobody can remove the owner.
+func (h *Handler) RemoveMemberHandler(c *gin.Context) {
+	organizationID := c.Param("organization_id")
+	email := c.Param("user")
+	caller := c.GetString(middleware.CallerEmailKey)
+
+	organization, err := h.Organizations.GetOrganizationById(c.Request.Context(), organizationID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if organization.IsAdmin(email) && email != organization.Owner && caller != organization.Owner && caller != email {
+		c.Error(apperror.Forbidden("Only the owner can remove an admin"))
+		return
+	}
+
+	if err := h.removeMember(c.Request.Context(), organization, email, caller); err != nil {
+		c.Error(err)
+		return
+	}
+
+	// Respond with a success message.
+	c.JSON(http.StatusOK, gin.H{"message": "Member removed from organization"})
+}
+
+// LeaveOrganizationHandler takes the caller out of an organization. The owner has to transfer the
+// organization before leaving it, and members of a parent organization have to leave that one.
+func (h *Handler) LeaveOrganizationHandler(c *gin.Context) {
+	organizationID := c.Param("organization_id")
+	caller := c.GetString(middleware.CallerEmailKey)
+
+	organization, err := h.Organizations.GetOrganizationById(c.Request.Context(), organizationID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	// The middleware also admits members of an ancestor, who are not on this organization's list.
+	if !slices.Contains(organization.InvitedUsers, caller) {
+		c.Error(apperror.Conflict("Membership is inherited from a parent organization; leave that organization instead"))
+		return
+	}
+
+	if err := h.removeMember(c.Request.Context(), organization, caller, caller); err != nil {
+		c.Error(err)
+		return
+	}
+
+	// Respond with a success message.
+	c.JSON(http.StatusOK, gin.H{"message": "Left organization"})
+}
+
+// removeMember takes email off the organization's teams, then off its members, admins and pending
+// invitations. Teams go first: if the second step fails, the member can be removed again, rather
+// than keeping team memberships that would grant permissions again if they were invited back.
+func (h *Handler) removeMember(ctx context.Context, organization *models.Organization, email, removedBy string) error {
+	organizationID := organization.Id.Hex()
+	// The store refuses to remove the owner as well; checking here keeps their teams untouched.
+	if email == organization.Owner {
+		return apperror.Conflict("%s owns the organization; transfer ownership first", email)
+	}
+
+	if err := h.Teams.RemoveMemberFromTeams(ctx, organizationID, email); err != nil {
+		return err
+	}
+	if err := h.Organizations.RemoveMember(ctx, organizationID, email); err != nil {
+		return err
+	}
+
+	webhook.Publish(ctx, organizationID, webhook.EventMemberRemoved, gin.H{"user_email": email, "removed_by": removedBy})
+	return nil
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/organization_api/pkg/apperror"
+	"github.com/organization_api/pkg/database/mongodb/models"
+	"github.com/organization_api/pkg/utils"
+	"github.com/organization_api/pkg/webhook"
+
+	"github.com/gin-gonic/gin"
+)
+
+// metadataFilterPrefix marks query parameters that filter on a metadata key, e.g. metadata.region=eu.
+const metadataFilterPrefix = "metadata."
+
+// SetMetadataHandler replaces the metadata of an organization.
+func (h *Handler) SetMetadataHandler(c *gin.Context) {
+	organizationID := c.Param("organization_id")
+	var request models.MetadataRequest
+
+	if err := bindJSON(c, &request); err != nil {
+		c.Error(err)
+		return
+	}
+
+	organization, err := h.Organizations.SetMetadata(c.Request.Context(), organizationID, request.Metadata)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	webhook.Publish(c.Request.Context(), organizationID, webhook.EventOrganizationUpdated, gin.H{
+		"organization_id": organization.Id,
+		"slug":            organization.Slug,
+		"metadata":        organization.Metadata,
+	})
+
+	// Respond with the organization and its new metadata.
+	c.JSON(http.StatusOK, organizationSummary(organization))
+}
+
+// SetTagsHandler replaces the tags of an organization.
+func (h *Handler) SetTagsHandler(c *gin.Context) {
+	organizationID := c.Param("organization_id")
+	var request models.TagsRequest
+
+	if err := bindJSON(c, &request); err != nil {
+		c.Error(err)
+		return
+	}
+
+	organization, err := h.Organizations.SetTags(c.Request.Context(), organizationID, request.Tags)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	webhook.Publish(c.Request.Context(), organizationID, webhook.EventOrganizationUpdated, gin.H{
+		"organization_id": organization.Id,
+		"slug":            organization.Slug,
+		"tags":            organization.Tags,
+	})
+
+	// Respond with the organization and its new tags.
+	c.JSON(http.StatusOK, organizationSummary(organization))
+}
+
+// organizationFilter reads the tag and metadata.<key> query parameters. Every tag must be present;
+// each metadata key may be given once. Other parameters are ignored.
+func organizationFilter(c *gin.Context) (models.OrganizationFilter, error) {
+	var filter models.OrganizationFilter
+	var fields []apperror.FieldError
+
+	query := c.Request.URL.Query()
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		values := query[name]
+		switch {
+		case name == "tag":
+			for _, tag := range values {
+				if utils.Validator().Var(tag, "tag") != nil {
+					fields = append(fields, apperror.FieldError{Field: name, Rule: "tag", Message: "must be a valid tag"})
+					continue
+				}
+				filter.Tags = append(filter.Tags, tag)
+			}
+		case strings.HasPrefix(name, metadataFilterPrefix):
+			key := strings.TrimPrefix(name, metadataFilterPrefix)
+			if utils.Validator().Var(key, "metakey") != nil {
+				fields = append(fields, apperror.FieldError{Field: name, Rule: "metakey", Message: "must name a valid metadata key"})
+				continue
+			}
+			if len(values) > 1 {
+				fields = append(fields, apperror.FieldError{Field: name, Rule: "once", Message: "may only be given once"})
+				continue
+			}
+			if filter.Metadata == nil {
+				filter.Metadata = make(map[string]string)
+			}
+			filter.Metadata[key] = values[0]
+		}
+	}
+
+	if len(fields) > 0 {
+		return filter, apperror.Validation("The filter contains invalid parameters").WithFields(fields)
+	}
+	return filter, nil
+}
@@ -1,125 +1,289 @@
 package handlers
 
 import (
-	"fmt"
+	"errors"
+	"log/slog"
 	"net/http"
 
+	"github.com/organization_api/pkg/api/middleware"
+	"github.com/organization_api/pkg/apperror"
 	"github.com/organization_api/pkg/database/mongodb/models"
-	"github.com/organization_api/pkg/database/mongodb/repository"
+	"github.com/organization_api/pkg/utils"
+	"github.com/organization_api/pkg/webhook"
 
 	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-// GetAllOrganizationsHandler lists all organizations.
-func GetAllOrganizationsHandler(c *gin.Context) {
-	repo := repository.NewOrganizationRepo()
-	organizations, err := repo.GetAllOrganizations()
+// GetAllOrganizationsHandler lists the organizations the caller belongs to and their descendants,
+// optionally only those with the tags and metadata given in the query string.
+func (h *Handler) GetAllOrganizationsHandler(c *gin.Context) {
+	filter, err := organizationFilter(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch organizations"})
+		c.Error(err)
+		return
+	}
+
+	// Only the organizations the caller can see are listed, so the filter cannot probe other tenants.
+	visible, err := h.visibleOrganizations(c.Request.Context(), c.GetString(middleware.CallerEmailKey))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	filter.Ids = make([]primitive.ObjectID, len(visible))
+	for i, organization := range visible {
+		filter.Ids[i] = organization.Id
+	}
+
+	organizations, err := h.Organizations.FindOrganizations(c.Request.Context(), filter)
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
 	// Respond with a success message and the list of organizations.
-	c.JSON(http.StatusOK, organizations)
+	c.JSON(http.StatusOK, organizationSummaries(organizations))
 }
 
 // CreateOrganizationHandler creates a new organization record.
-func CreateOrganizationHandler(c *gin.Context) {
+func (h *Handler) CreateOrganizationHandler(c *gin.Context) {
 	var org models.Organization
-	repo := repository.NewOrganizationRepo()
 
-	err := c.ShouldBindJSON(&org)
+	err := bindJSON(c, &org)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON payload"})
+		c.Error(err)
 		return
 	}
-	orgID, err := repo.CreateOrganization(&org)
+
+	// The caller owns the organization they create and is its first member. Anyone else named in
+	// the request is invited once it exists.
+	owner := c.GetString(middleware.CallerEmailKey)
+	members := org.InvitedUsers
+	org.Owner = owner
+	org.Admins = nil
+	org.OwnershipTransfers = nil
+	org.Invitations = nil
+	org.Slug = ""
+	org.InvitedUsers = []string{owner}
+
+	// Only admins of the parent may add children to it.
+	if org.ParentId != "" {
+		if err := h.checkChildDepth(c, org.ParentId); err != nil {
+			c.Error(err)
+			return
+		}
+	}
+
+	orgID, err := h.Organizations.CreateOrganization(c.Request.Context(), &org)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create organization"})
+		c.Error(err)
 		return
 	}
-	// Respond with a success message and the organization ID.
-	c.JSON(http.StatusCreated, gin.H{"organization_id": orgID})
+	if err := h.inviteInitialMembers(c.Request.Context(), orgID, owner, members); err != nil {
+		slog.ErrorContext(c.Request.Context(), "create organization: failed to invite members", "organization_id", orgID, "error", err)
+		c.Error(err)
+		return
+	}
+	// Respond with a success message and the organization ID and slug.
+	c.JSON(http.StatusCreated, gin.H{"organization_id": orgID, "slug": org.Slug})
 }
 
 // GetOrganizationByIdHandler retrieves an organization by its ID.
-func GetOrganizationByIdHandler(c *gin.Context) {
+func (h *Handler) GetOrganizationByIdHandler(c *gin.Context) {
 	organizationID := c.Param("organization_id")
 
-	repo := repository.NewOrganizationRepo()
-	organization, err := repo.GetOrganizationById(organizationID)
+	organization, err := h.Organizations.GetOrganizationById(c.Request.Context(), organizationID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch organization details"})
+		c.Error(err)
 		return
 	}
 
 	// Respond with a success message and the organization details.
-	c.JSON(http.StatusOK, models.Organization{
-		Id:          organization.Id,
-		Name:        organization.Name,
-		Description: organization.Description,
-	})
+	c.JSON(http.StatusOK, organizationSummary(organization))
 }
 
 // UpdateOrganizationHandler updates an existing organization's details.
-func UpdateOrganizationHandler(c *gin.Context) {
+func (h *Handler) UpdateOrganizationHandler(c *gin.Context) {
 	organizationID := c.Param("organization_id")
 
 	var updateData models.OrganizationUpdate
-	if err := c.ShouldBindJSON(&updateData); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := bindJSON(c, &updateData); err != nil {
+		c.Error(err)
 		return
 	}
-	fmt.Println("req body", updateData)
-	repo := repository.NewOrganizationRepo()
 
-	organization, err := repo.UpdateOrganization(organizationID, &updateData)
+	organization, err := h.Organizations.UpdateOrganization(c.Request.Context(), organizationID, &updateData)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update organization"})
+		c.Error(err)
 		return
 	}
+	webhook.Publish(c.Request.Context(), organizationID, webhook.EventOrganizationUpdated, gin.H{
+		"organization_id": organization.Id,
+		"slug":            organization.Slug,
+		"name":            organization.Name,
+		"description":     organization.Description,
+	})
 
 	// Respond with a success message and the updated organization details.
 	c.JSON(http.StatusOK, gin.H{
 		"organization_id": organization.Id,
+		"slug":            organization.Slug,
 		"name":            organization.Name,
 		"description":     organization.Description,
 	})
 }
 
 // DeleteOrganizationHandler removes an organization from the database.
-func DeleteOrganizationHandler(c *gin.Context) {
+func (h *Handler) DeleteOrganizationHandler(c *gin.Context) {
 	organizationID := c.Param("organization_id")
 
-	repo := repository.NewOrganizationRepo()
+	// Children would be left pointing at a parent that no longer exists.
+	children, err := h.Organizations.GetChildOrganizations(c.Request.Context(), organizationID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if len(children) > 0 {
+		c.Error(apperror.Conflict("Organization has %d child organizations; move or delete them first", len(children)))
+		return
+	}
 
-	err := repo.DeleteOrganization(organizationID)
+	err = h.Organizations.DeleteOrganization(c.Request.Context(), organizationID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete organization"})
+		c.Error(err)
 		return
 	}
+	// The organization's webhooks are looked up for the event before they are deleted below.
+	webhook.Publish(c.Request.Context(), organizationID, webhook.EventOrganizationDeleted, gin.H{"organization_id": organizationID})
+
+	// The organization is gone either way; what it leaves behind is only untidy.
+	if err := h.Settings.DeleteSettings(c.Request.Context(), organizationID); err != nil {
+		slog.ErrorContext(c.Request.Context(), "organization: failed to delete settings", "organization_id", organizationID, "error", err)
+	}
+	if err := h.Domains.DeleteDomainClaims(c.Request.Context(), organizationID); err != nil {
+		slog.ErrorContext(c.Request.Context(), "organization: failed to delete domain claims", "organization_id", organizationID, "error", err)
+	}
+	if err := h.Teams.DeleteTeams(c.Request.Context(), organizationID); err != nil {
+		slog.ErrorContext(c.Request.Context(), "organization: failed to delete teams", "organization_id", organizationID, "error", err)
+	}
+	if err := h.Webhooks.DeleteWebhooks(c.Request.Context(), organizationID); err != nil {
+		slog.ErrorContext(c.Request.Context(), "organization: failed to delete webhooks", "organization_id", organizationID, "error", err)
+	}
 	// Respond with a success message.
 	c.JSON(http.StatusOK, gin.H{"message": "Organization deleted successfully"})
 }
 
 // InviteUserToOrganizationHandler sends an invitation to join an organization.
-func InviteUserToOrganizationHandler(c *gin.Context) {
+func (h *Handler) InviteUserToOrganizationHandler(c *gin.Context) {
 	organizationID := c.Param("organization_id")
 	var requestBody models.InviterequestBody
 
-	if err := c.ShouldBindJSON(&requestBody); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON payload"})
+	if err := bindJSON(c, &requestBody); err != nil {
+		c.Error(err)
 		return
 	}
+	requestBody.UserEmail = normalizeEmail(requestBody.UserEmail)
 
-	repo := repository.NewOrganizationRepo()
-	// Call the InviteUserToOrganization method in the repository
-	err := repo.InviteUserToOrganization(organizationID, requestBody.UserEmail)
+	// The organization's settings decide who may be invited and how they join.
+	settings, err := h.Settings.GetSettings(c.Request.Context(), organizationID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to invite user to organization"})
+		c.Error(err)
+		return
+	}
+	if !settings.AllowsEmail(requestBody.UserEmail) {
+		c.Error(apperror.Validation("The organization does not allow members from this domain").WithFields([]apperror.FieldError{{
+			Field:   "user_email",
+			Rule:    "allowed_domain",
+			Message: "must be in one of the organization's allowed domains",
+		}}))
 		return
 	}
 
-	// Respond with a success message.
-	c.JSON(http.StatusOK, gin.H{"message": "User invited to organization"})
+	_, pending, err := h.inviteUsers(c.Request.Context(), organizationID, settings, []string{requestBody.UserEmail})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	webhook.Publish(c.Request.Context(), organizationID, webhook.EventMemberInvited, gin.H{"user_email": requestBody.UserEmail})
+
+	// Respond with a success message, and when the invitation lapses if the user has no account yet.
+	response := gin.H{"message": "User invited to organization"}
+	if expiresAt, ok := pending[requestBody.UserEmail]; ok {
+		response["expires_at"] = expiresAt
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// TransferOwnershipHandler hands an organization to another member. Only the owner may do so, after
+// re-entering their password; they stay on as an admin.
+func (h *Handler) TransferOwnershipHandler(c *gin.Context) {
+	organizationID := c.Param("organization_id")
+	var request models.TransferOwnershipRequest
+
+	if err := bindJSON(c, &request); err != nil {
+		c.Error(err)
+		return
+	}
+
+	// Only the current owner may give the organization away.
+	caller := c.GetString(middleware.CallerEmailKey)
+	organization, err := h.Organizations.GetOrganizationById(c.Request.Context(), organizationID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if organization.Owner != caller {
+		c.Error(apperror.Forbidden("Only the owner can transfer the organization"))
+		return
+	}
+
+	// A stolen access token alone must not be enough to give the organization away.
+	if err := h.confirmPassword(c, caller, request.Password); err != nil {
+		c.Error(err)
+		return
+	}
+
+	err = h.Organizations.TransferOwnership(c.Request.Context(), organizationID, caller, request.NewOwner)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	webhook.Publish(c.Request.Context(), organizationID, webhook.EventOwnershipTransferred, gin.H{
+		"previous_owner": caller,
+		"owner":          request.NewOwner,
+	})
+
+	// Respond with a success message and the new owner.
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Ownership transferred",
+		"owner":          request.NewOwner,
+		"previous_owner": caller,
+	})
+}
+
+// confirmPassword checks the caller's password before a sensitive action.
+func (h *Handler) confirmPassword(c *gin.Context, email, password string) error {
+	user, err := h.Users.FindUserByEmail(c.Request.Context(), email)
+	if errors.Is(err, apperror.ErrNotFound) {
+		return apperror.Forbidden("Password confirmation failed")
+	}
+	if err != nil {
+		return err
+	}
+
+	isMatch, err := utils.CheckPasswordHash(password, user.Password)
+	if err != nil || !isMatch {
+		return apperror.Forbidden("Password confirmation failed")
+	}
+	return nil
+}
+
+// appendMissing appends value to values unless it is already present.
+func appendMissing(values []string, value string) []string {
+	for _, existing := range values {
+		if existing == value {
+			return values
+		}
+	}
+	return append(values, value)
 }
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/organization_api/pkg/api/middleware"
+	"github.com/organization_api/pkg/apperror"
+	"github.com/organization_api/pkg/database/mongodb/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SearchHandler searches the organizations the caller can see and the users who belong to them,
+// best matches first.
+func (h *Handler) SearchHandler(c *gin.Context) {
+	var request models.SearchRequest
+	if err := bindQuery(c, &request); err != nil {
+		c.Error(err)
+		return
+	}
+	request.Query = strings.TrimSpace(request.Query)
+	if request.Query == "" {
+		c.Error(apperror.Validation("Query parameter q must contain a word to search for"))
+		return
+	}
+	types, err := searchTypes(request.Types)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	visible, err := h.visibleOrganizations(c.Request.Context(), c.GetString(middleware.CallerEmailKey))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	// Results of both types are ranked together, so each type must supply enough hits to fill the page.
+	window := request.Offset + request.Limit
+	var hits []*models.SearchHit
+	var total int64
+	for _, searchType := range types {
+		var found []*models.SearchHit
+		var matches int64
+		switch searchType {
+		case models.SearchTypeOrganization:
+			found, matches, err = h.Organizations.SearchOrganizations(c.Request.Context(), models.SearchQuery{
+				Text: request.Query, Within: organizationIDs(visible), Limit: window,
+			})
+		case models.SearchTypeUser:
+			found, matches, err = h.Users.SearchUsers(c.Request.Context(), models.SearchQuery{
+				Text: request.Query, Within: organizationMembers(visible), Limit: window,
+			})
+		}
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		hits = append(hits, found...)
+		total += matches
+	}
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].Score > hits[j].Score })
+
+	results := make([]models.SearchHit, 0, request.Limit)
+	for i := request.Offset; i < len(hits) && i < window; i++ {
+		results = append(results, searchResult(hits[i]))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"results": results,
+		"total":   total,
+		"limit":   request.Limit,
+		"offset":  request.Offset,
+	})
+}
+
+// searchTypes splits the requested types, which may be comma-separated, defaulting to every type.
+func searchTypes(requested []string) ([]string, error) {
+	var types []string
+	for _, value := range requested {
+		for _, searchType := range strings.Split(value, ",") {
+			searchType = strings.TrimSpace(searchType)
+			if !containsString(models.SearchTypes, searchType) {
+				return nil, apperror.Validation("Unknown search type %q; use %s", searchType, strings.Join(models.SearchTypes, " or "))
+			}
+			if !containsString(types, searchType) {
+				types = append(types, searchType)
+			}
+		}
+	}
+	if len(types) == 0 {
+		return models.SearchTypes, nil
+	}
+	return types, nil
+}
+
+func organizationIDs(organizations []*models.Organization) []string {
+	ids := make([]string, 0, len(organizations))
+	for _, organization := range organizations {
+		ids = append(ids, organization.Id.Hex())
+	}
+	return ids
+}
+
+// organizationMembers returns the emails of everyone who belongs to one of organizations.
+func organizationMembers(organizations []*models.Organization) []string {
+	seen := make(map[string]bool)
+	var emails []string
+	for _, organization := range organizations {
+		members := append(append([]string{organization.Owner}, organization.Admins...), organization.InvitedUsers...)
+		for _, email := range members {
+			if email != "" && !seen[email] {
+				seen[email] = true
+				emails = append(emails, email)
+			}
+		}
+	}
+	return emails
+}
+
+// searchResult is the public view of a hit: an organization summary, or a user's name and email.
+func searchResult(hit *models.SearchHit) models.SearchHit {
+	result := models.SearchHit{Type: hit.Type, Score: hit.Score}
+	if hit.Organization != nil {
+		summary := organizationSummary(hit.Organization)
+		result.Organization = &summary
+	}
+	if hit.User != nil {
+		result.User = &models.User{Id: hit.User.Id, Name: hit.User.Name, Email: hit.User.Email}
+	}
+	return result
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/organization_api/pkg/api/middleware"
+	"github.com/organization_api/pkg/apperror"
+	"github.com/organization_api/pkg/database/mongodb/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetSettingsHandler returns the settings of an organization, with defaults for those it has not set.
+func (h *Handler) GetSettingsHandler(c *gin.Context) {
+	organizationID := c.Param("organization_id")
+
+	settings, err := h.Settings.GetSettings(c.Request.Context(), organizationID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	// Respond with the settings.
+	c.JSON(http.StatusOK, settings)
+}
+
+// UpdateSettingsHandler changes the settings named in the request and leaves the others as they are.
+func (h *Handler) UpdateSettingsHandler(c *gin.Context) {
+	organizationID := c.Param("organization_id")
+	var patch models.SettingsPatch
+
+	// A misspelt setting must not be silently ignored.
+	if err := bindStrictJSON(c, &patch); err != nil {
+		c.Error(err)
+		return
+	}
+
+	// Turning the requirement on before MFA exists would suggest a protection that is not there.
+	if patch.RequireMFA != nil && *patch.RequireMFA {
+		c.Error(apperror.Validation("Multi-factor authentication is not available yet").WithFields([]apperror.FieldError{{
+			Field:   "require_mfa",
+			Rule:    "unavailable",
+			Message: "cannot be enabled until multi-factor authentication is available",
+		}}))
+		return
+	}
+
+	caller := c.GetString(middleware.CallerEmailKey)
+	settings, err := h.Settings.UpdateSettings(c.Request.Context(), organizationID, patch, caller)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	// Respond with the settings as they are now.
+	c.JSON(http.StatusOK, settings)
+}
@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/organization_api/pkg/api/middleware"
+	"github.com/organization_api/pkg/apperror"
+	"github.com/organization_api/pkg/database/mongodb/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateTeamHandler creates a team within an organization.
+func (h *Handler) CreateTeamHandler(c *gin.Context) {
+	organizationID := c.Param("organization_id")
+	var requestBody models.TeamRequestBody
+
+	if err := bindJSON(c, &requestBody); err != nil {
+		c.Error(err)
+		return
+	}
+
+	team := models.Team{
+		OrganizationId: organizationID,
+		Name:           requestBody.Name,
+		CreatedAt:      time.Now().UTC(),
+	}
+	if _, err := h.Teams.CreateTeam(c.Request.Context(), &team); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, team)
+}
+
+// GetTeamsHandler lists the teams of an organization.
+func (h *Handler) GetTeamsHandler(c *gin.Context) {
+	organizationID := c.Param("organization_id")
+
+	teams, err := h.Teams.GetTeamsByOrganization(c.Request.Context(), organizationID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, teams)
+}
+
+// GetTeamHandler retrieves a single team with its members and permissions.
+func (h *Handler) GetTeamHandler(c *gin.Context) {
+	team, err := h.Teams.GetTeamById(c.Request.Context(), c.Param("organization_id"), c.Param("team_id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, team)
+}
+
+// RenameTeamHandler renames a team. Team maintainers may rename their own team.
+func (h *Handler) RenameTeamHandler(c *gin.Context) {
+	organizationID, teamID := c.Param("organization_id"), c.Param("team_id")
+	var requestBody models.TeamRequestBody
+
+	if err := bindJSON(c, &requestBody); err != nil {
+		c.Error(err)
+		return
+	}
+	if err := h.authorizeTeamChange(c, organizationID, teamID); err != nil {
+		c.Error(err)
+		return
+	}
+
+	team, err := h.Teams.RenameTeam(c.Request.Context(), organizationID, teamID, requestBody.Name)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, team)
+}
+
+// SetTeamPermissionsHandler replaces the permissions granted to a team.
+func (h *Handler) SetTeamPermissionsHandler(c *gin.Context) {
+	var requestBody models.TeamPermissionsRequestBody
+
+	if err := bindJSON(c, &requestBody); err != nil {
+		c.Error(err)
+		return
+	}
+
+	team, err := h.Teams.SetTeamPermissions(c.Request.Context(), c.Param("organization_id"), c.Param("team_id"), requestBody.Permissions)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, team)
+}
+
+// DeleteTeamHandler removes a team; its members stay in the organization.
+func (h *Handler) DeleteTeamHandler(c *gin.Context) {
+	if err := h.Teams.DeleteTeam(c.Request.Context(), c.Param("organization_id"), c.Param("team_id")); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Team deleted successfully"})
+}
+
+// SetTeamMemberHandler adds an organization member to a team or changes their team role.
+// The role defaults to member. Team maintainers may manage their own team's members.
+func (h *Handler) SetTeamMemberHandler(c *gin.Context) {
+	organizationID, teamID, email := c.Param("organization_id"), c.Param("team_id"), c.Param("email")
+
+	// The body is optional.
+	var requestBody models.TeamMemberRequestBody
+	if c.Request.ContentLength != 0 {
+		if err := bindJSON(c, &requestBody); err != nil {
+			c.Error(err)
+			return
+		}
+	}
+	role := requestBody.Role
+	if role == "" {
+		role = models.TeamRoleMember
+	}
+
+	if err := h.authorizeTeamChange(c, organizationID, teamID); err != nil {
+		c.Error(err)
+		return
+	}
+
+	// Only members of the organization can join its teams.
+	organization, err := h.Organizations.GetOrganizationById(c.Request.Context(), organizationID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if !containsString(organization.InvitedUsers, email) {
+		c.Error(apperror.Conflict("%s is not a member of the organization", email))
+		return
+	}
+
+	team, err := h.Teams.SetTeamMember(c.Request.Context(), organizationID, teamID, models.TeamMember{Email: email, Role: role})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, team)
+}
+
+// RemoveTeamMemberHandler removes a member from a team; they stay in the organization.
+func (h *Handler) RemoveTeamMemberHandler(c *gin.Context) {
+	organizationID, teamID := c.Param("organization_id"), c.Param("team_id")
+
+	if err := h.authorizeTeamChange(c, organizationID, teamID); err != nil {
+		c.Error(err)
+		return
+	}
+
+	team, err := h.Teams.RemoveTeamMember(c.Request.Context(), organizationID, teamID, c.Param("email"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, team)
+}
+
+// authorizeTeamChange lets maintainers of the team through, as well as anyone holding teams:manage.
+func (h *Handler) authorizeTeamChange(c *gin.Context, organizationID, teamID string) error {
+	caller := c.GetString(middleware.CallerEmailKey)
+
+	team, err := h.Teams.GetTeamById(c.Request.Context(), organizationID, teamID)
+	if err != nil {
+		return err
+	}
+	if member, ok := team.Member(caller); ok && member.Role == models.TeamRoleMaintainer {
+		return nil
+	}
+
+	organization, err := h.Organizations.GetOrganizationById(c.Request.Context(), organizationID)
+	if err != nil {
+		return err
+	}
+	allowed, err := middleware.HasPermission(c.Request.Context(), h.Organizations, h.Teams, organization, caller, models.PermissionManageTeams)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return apperror.Forbidden("Only team maintainers and members with %s may change the team", models.PermissionManageTeams)
+	}
+	return nil
+}
+
+// containsString reports whether values contains value.
+func containsString(values []string, value string) bool {
+	for _, existing := range values {
+		if existing == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/organization_api/pkg/api/middleware"
+	"github.com/organization_api/pkg/apperror"
+	"github.com/organization_api/pkg/database/mongodb/models"
+	"github.com/organization_api/pkg/mail"
+	"github.com/organization_api/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// verificationRateKey prefixes the rate limit on confirmation emails sent to one address.
+const verificationRateKey = "email_verification:"
+
+// Confirmation emails a user may ask for per window, so the endpoint cannot be used to flood an inbox.
+const (
+	verificationRateLimit  = 5
+	verificationRateWindow = time.Hour
+)
+
+// VerifyEmailHandler confirms an email address with the token emailed to it. An organization that
+// verified the address's domain and adds people with it automatically takes the user in now.
+func (h *Handler) VerifyEmailHandler(c *gin.Context) {
+	var request models.VerifyEmailRequest
+
+	if err := bindJSON(c, &request); err != nil {
+		c.Error(err)
+		return
+	}
+
+	claims, err := utils.ValidateToken(request.Token)
+	if err != nil {
+		c.Error(apperror.Unauthorized("Invalid verification token").Wrap(err))
+		return
+	}
+	if claims.Type != utils.TokenTypeEmailVerification {
+		c.Error(apperror.Unauthorized("Invalid verification token"))
+		return
+	}
+
+	user, err := h.Users.FindUserByEmail(c.Request.Context(), claims.Email)
+	if errors.Is(err, apperror.ErrNotFound) {
+		c.Error(apperror.Unauthorized("Invalid verification token"))
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if !user.EmailVerified {
+		if err := h.Users.SetEmailVerified(c.Request.Context(), user.Email); err != nil {
+			c.Error(err)
+			return
+		}
+	}
+
+	// The address is confirmed either way, so a failure to join is only logged.
+	match, err := h.domainOrganization(c.Request.Context(), user.Email)
+	if err == nil && match != nil && match.Joined {
+		_, err = h.joinByDomain(c.Request.Context(), match.OrganizationId, user.Email)
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "verify email: failed to join by email domain", "error", err)
+		match = nil
+	}
+
+	c.JSON(http.StatusOK, models.VerifyEmailResponse{
+		Message:            "Email address verified",
+		DomainOrganization: match,
+	})
+}
+
+// ResendVerificationHandler emails the caller another confirmation token.
+func (h *Handler) ResendVerificationHandler(c *gin.Context) {
+	caller := c.GetString(middleware.CallerEmailKey)
+
+	user, err := h.Users.FindUserByEmail(c.Request.Context(), caller)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if user.EmailVerified {
+		c.Error(apperror.Conflict("Email address is already verified"))
+		return
+	}
+
+	allowed, retryAfter, err := h.RateLimits.Allow(c.Request.Context(), verificationRateKey+caller, verificationRateLimit, verificationRateWindow)
+	if err != nil {
+		c.Error(apperror.Internal("Could not check the confirmation email rate limit").Wrap(err))
+		return
+	}
+	if !allowed {
+		seconds := int(math.Max(1, math.Ceil(retryAfter.Seconds())))
+		c.Header("Retry-After", strconv.Itoa(seconds))
+		c.Error(apperror.RateLimited("At most %d confirmation emails may be sent per %s; try again in %d seconds",
+			verificationRateLimit, verificationRateWindow, seconds))
+		return
+	}
+
+	if err := h.sendVerification(c.Request.Context(), caller); err != nil {
+		c.Error(apperror.Internal("Could not send the confirmation email").Wrap(err))
+		return
+	}
+
+	// Respond with a success message.
+	c.JSON(http.StatusOK, gin.H{"message": "Confirmation email sent"})
+}
+
+// sendVerification emails a confirmation token to email, as a link when VerifyURL is set.
+func (h *Handler) sendVerification(ctx context.Context, email string) error {
+	token, err := utils.GenerateEmailVerificationToken(email)
+	if err != nil {
+		return err
+	}
+
+	hours := int(utils.EmailVerificationExpiry.Hours())
+	body := fmt.Sprintf("Post this token to /auth/verify-email within %d hours to confirm your email address:\n\n%s\n",
+		hours, token)
+	if h.VerifyURL != "" {
+		link, err := url.Parse(h.VerifyURL)
+		if err != nil {
+			return err
+		}
+		query := link.Query()
+		query.Set("token", token)
+		link.RawQuery = query.Encode()
+		body = fmt.Sprintf("Follow this link within %d hours to confirm your email address:\n\n%s\n",
+			hours, link)
+	}
+
+	return h.Mailer.Send(ctx, mail.Message{
+		To:      email,
+		Subject: "Confirm your email address",
+		Body:    body,
+	})
+}
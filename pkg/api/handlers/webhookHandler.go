@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/organization_api/pkg/apperror"
+	"github.com/organization_api/pkg/database/mongodb/models"
+	"github.com/organization_api/pkg/webhook"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateWebhookHandler registers a webhook subscription for an organization.
+func (h *Handler) CreateWebhookHandler(c *gin.Context) {
+	organizationID := c.Param("organization_id")
+	var requestBody models.WebhookRequestBody
+
+	if err := bindJSON(c, &requestBody); err != nil {
+		c.Error(err)
+		return
+	}
+
+	// Deliveries must not become a way to reach hosts inside the API's network.
+	if err := webhook.CheckTarget(c.Request.Context(), requestBody.URL); err != nil {
+		c.Error(apperror.Validation("Invalid webhook target").WithFields([]apperror.FieldError{{
+			Field:   "url",
+			Rule:    "public_address",
+			Message: err.Error(),
+		}}))
+		return
+	}
+
+	// Generate a signing secret unless the caller supplied one.
+	secret := requestBody.Secret
+	if secret == "" {
+		generated, err := webhook.GenerateSecret()
+		if err != nil {
+			c.Error(apperror.Internal("Failed to generate webhook secret").Wrap(err))
+			return
+		}
+		secret = generated
+	}
+
+	hook := models.Webhook{
+		OrganizationId: organizationID,
+		URL:            requestBody.URL,
+		Secret:         secret,
+		Events:         requestBody.Events,
+		Active:         true,
+		CreatedAt:      time.Now().UTC(),
+	}
+
+	if _, err := h.Webhooks.CreateWebhook(c.Request.Context(), &hook); err != nil {
+		c.Error(err)
+		return
+	}
+
+	// The secret is only returned once, on creation.
+	c.JSON(http.StatusCreated, hook)
+}
+
+// GetWebhooksHandler lists the webhook subscriptions of an organization.
+func (h *Handler) GetWebhooksHandler(c *gin.Context) {
+	organizationID := c.Param("organization_id")
+
+	hooks, err := h.Webhooks.GetWebhooksByOrganization(c.Request.Context(), organizationID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	// Never echo signing secrets back.
+	for _, hook := range hooks {
+		hook.Secret = ""
+	}
+
+	c.JSON(http.StatusOK, hooks)
+}
+
+// DeleteWebhookHandler removes a webhook subscription.
+func (h *Handler) DeleteWebhookHandler(c *gin.Context) {
+	organizationID := c.Param("organization_id")
+	webhookID := c.Param("webhook_id")
+
+	if err := h.Webhooks.DeleteWebhook(c.Request.Context(), organizationID, webhookID); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted successfully"})
+}
+
+// GetWebhookDeliveriesHandler returns the delivery log of a webhook.
+func (h *Handler) GetWebhookDeliveriesHandler(c *gin.Context) {
+	organizationID := c.Param("organization_id")
+	webhookID := c.Param("webhook_id")
+
+	if _, err := h.Webhooks.GetWebhookById(c.Request.Context(), organizationID, webhookID); err != nil {
+		c.Error(err)
+		return
+	}
+
+	deliveries, err := h.Webhooks.GetDeliveriesByWebhook(c.Request.Context(), webhookID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// TestWebhookHandler sends a single test event to a webhook and reports the outcome.
+func (h *Handler) TestWebhookHandler(c *gin.Context) {
+	organizationID := c.Param("organization_id")
+	webhookID := c.Param("webhook_id")
+
+	hook, err := h.Webhooks.GetWebhookById(c.Request.Context(), organizationID, webhookID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	// Test events are attempted once so the caller gets an immediate answer.
+	delivery := h.Dispatcher.Deliver(c.Request.Context(), hook, webhook.EventTest, gin.H{"message": "This is a test event"}, 1)
+
+	c.JSON(http.StatusOK, delivery)
+}
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/organization_api/pkg/apperror"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrorHandler renders the last error recorded with c.Error as an application/problem+json response.
+func ErrorHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		// Nothing to do when no error was recorded or a response has already been written.
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+
+		err := c.Errors.Last().Err
+		problem := apperror.NewProblem(err, c.Request.URL.Path)
+		if problem.Status >= http.StatusInternalServerError {
+			slog.ErrorContext(c.Request.Context(), "http: request failed", "method", c.Request.Method, "path", c.Request.URL.Path, "error", err)
+		}
+
+		c.Header("Content-Type", apperror.ProblemContentType)
+		c.JSON(problem.Status, problem)
+	}
+}
+
+// NoRouteHandler reports unknown routes as a not found problem.
+func NoRouteHandler(c *gin.Context) {
+	c.Error(apperror.NotFound("No route matches %s %s", c.Request.Method, c.Request.URL.Path))
+}
+
+// Recovered logs a panic caught by gin.CustomRecovery and answers with an internal error problem.
+func Recovered(c *gin.Context, recovered interface{}) {
+	slog.ErrorContext(c.Request.Context(), "http: panic serving request", "method", c.Request.Method, "path", c.Request.URL.Path, "panic", recovered)
+
+	problem := apperror.NewProblem(apperror.Internal("Internal server error"), c.Request.URL.Path)
+	c.Header("Content-Type", apperror.ProblemContentType)
+	c.AbortWithStatusJSON(problem.Status, problem)
+}
@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/organization_api/pkg/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// unmatchedRoute labels requests that matched no route, keeping label cardinality bounded.
+const unmatchedRoute = "unmatched"
+
+// Metrics records request counts, latency and in-flight requests by route template.
+// It must run before ErrorHandler so the status it records is the one sent to the client.
+func Metrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		metrics.HTTPInFlight.Inc()
+		defer metrics.HTTPInFlight.Dec()
+
+		start := time.Now()
+		c.Next()
+
+		// Label by the route template, e.g. /api/organization/:organization_id, never the raw path.
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		status := strconv.Itoa(c.Writer.Status())
+
+		metrics.HTTPRequests.WithLabelValues(c.Request.Method, route, status).Inc()
+		metrics.HTTPRequestDuration.WithLabelValues(c.Request.Method, route, status).Observe(time.Since(start).Seconds())
+	}
+}
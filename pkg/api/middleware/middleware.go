@@ -1,34 +1,29 @@
 package middleware
 
 import (
-	"net/http"
+	"context"
+	"errors"
 	"strings"
 
+	"github.com/organization_api/pkg/apperror"
+	"github.com/organization_api/pkg/database/mongodb/models"
 	"github.com/organization_api/pkg/database/mongodb/repository"
 	"github.com/organization_api/pkg/utils"
 
 	"github.com/gin-gonic/gin"
 )
 
-// AuthMiddleware checks for a valid authorization token in the request headers.
-func AuthMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		// Retrieve the Authorization header from the request.
-		header := c.GetHeader("Authorization")
-		if header == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Missing Authorization header"})
-			c.Abort()
-			return
-		}
-
-		// Extract the token string after removing the "Bearer" prefix.
-		tokenString := strings.TrimPrefix(header, "Bearer ")
+// CallerEmailKey is the gin context key under which AuthMiddleware stores the caller's email.
+const CallerEmailKey = "caller_email"
 
-		// Validate the extracted token.
-		_, err := utils.ValidateToken(tokenString)
+// AuthMiddleware checks for a valid, unrevoked authorization token in the request headers.
+func AuthMiddleware(revocations repository.TokenRevocationStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		end := startSpan(c, "middleware.Auth")
+		err := authenticate(c, revocations)
+		end(err)
 		if err != nil {
-			// If the token is invalid, respond with an Unauthorized status.
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			c.Error(err)
 			c.Abort()
 			return
 		}
@@ -38,54 +33,218 @@ func AuthMiddleware() gin.HandlerFunc {
 	}
 }
 
+// authenticate validates the bearer token of the request and records the caller's email.
+func authenticate(c *gin.Context, revocations repository.TokenRevocationStore) error {
+	// Retrieve the Authorization header from the request.
+	header := c.GetHeader("Authorization")
+	if header == "" {
+		return apperror.Unauthorized("Missing Authorization header")
+	}
+
+	// Extract the token string after removing the "Bearer" prefix.
+	tokenString := strings.TrimPrefix(header, "Bearer ")
+
+	// Validate the extracted token.
+	claims, err := utils.ValidateToken(tokenString)
+	if err != nil {
+		return apperror.Unauthorized("Invalid token").Wrap(err)
+	}
+	// Only access tokens grant access; tokens issued before typ existed are access tokens.
+	if claims.Type != utils.TokenTypeAccess && claims.Type != "" {
+		return apperror.Unauthorized("Invalid token")
+	}
+
+	// Reject tokens issued before the user's tokens were last revoked.
+	if err := CheckRevocation(c.Request.Context(), revocations, claims); err != nil {
+		return err
+	}
+
+	c.Set(CallerEmailKey, claims.Email)
+	return nil
+}
+
+// CheckRevocation reports an unauthorized error when the token was revoked after it was issued.
+func CheckRevocation(ctx context.Context, revocations repository.TokenRevocationStore, claims *utils.Claims) error {
+	revokedAt, err := revocations.RevokedAt(ctx, claims.Email)
+	if err != nil {
+		return apperror.Internal("Could not check token revocation").Wrap(err)
+	}
+	if !revokedAt.IsZero() && claims.IssuedNoLaterThan(revokedAt) {
+		return apperror.Unauthorized("Token has been revoked")
+	}
+	return nil
+}
+
 // InviteMiddleware verifies if the user is authorized to perform actions related to invitations.
-func InviteMiddleware() gin.HandlerFunc {
+func InviteMiddleware(organizations repository.OrganizationStore) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Retrieve the organization ID from the URL parameter.
-		organizationID := c.Param("organization_id")
-		header := c.GetHeader("Authorization")
-		if header == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Missing Authorization header"})
+		end := startSpan(c, "middleware.Invite")
+		err := checkInvited(c, organizations)
+		end(err)
+		if err != nil {
+			c.Error(err)
 			c.Abort()
 			return
 		}
 
-		// Extract the token string after removing the "Bearer" prefix.
-		tokenString := strings.TrimPrefix(header, "Bearer ")
+		// Proceed to the next handler if the user is invited.
+		c.Next()
+	}
+}
+
+// checkInvited reports whether the caller is invited to the organization in the URL.
+func checkInvited(c *gin.Context, organizations repository.OrganizationStore) error {
+	// Retrieve the organization ID from the URL parameter.
+	organizationID := c.Param("organization_id")
+	header := c.GetHeader("Authorization")
+	if header == "" {
+		return apperror.Unauthorized("Missing Authorization header")
+	}
+
+	// Extract the token string after removing the "Bearer" prefix.
+	tokenString := strings.TrimPrefix(header, "Bearer ")
+
+	userEmail, err := utils.GetEmailFromToken(tokenString)
+	if err != nil {
+		return apperror.Unauthorized("Invalid token").Wrap(err)
+	}
 
-		userEmail, err := utils.GetEmailFromToken(tokenString)
+	organization, err := organizations.GetOrganizationById(c.Request.Context(), organizationID)
+	if err != nil {
+		return err
+	}
+
+	// Check if the user is invited to the organization or to one of its ancestors.
+	member, err := IsMember(c.Request.Context(), organizations, organization, userEmail)
+	if err != nil {
+		return err
+	}
+	if member {
+		return nil
+	}
+
+	// If the user is not invited, respond with a Forbidden status.
+	return apperror.Forbidden("User is not invited to the organization")
+}
+
+// PermissionMiddleware lets through the owner and admins of the organization in the URL, and
+// members of one of its teams that was granted permission. An empty permission admits admins only.
+func PermissionMiddleware(organizations repository.OrganizationStore, teams repository.TeamStore, permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		end := startSpan(c, "middleware.Permission")
+		err := checkPermission(c, organizations, teams, permission)
+		end(err)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			c.Error(err)
 			c.Abort()
 			return
 		}
 
-		// Create a new instance of the Organization repository.
-		repo := repository.NewOrganizationRepo()
-		organization, err := repo.GetOrganizationById(organizationID)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve organization"})
-			c.Abort()
-			return
+		// Proceed to the next handler if the caller holds the permission.
+		c.Next()
+	}
+}
+
+// checkPermission reports whether the caller holds permission in the organization in the URL.
+func checkPermission(c *gin.Context, organizations repository.OrganizationStore, teams repository.TeamStore, permission string) error {
+	organization, err := organizations.GetOrganizationById(c.Request.Context(), c.Param("organization_id"))
+	if err != nil {
+		return err
+	}
+
+	allowed, err := HasPermission(c.Request.Context(), organizations, teams, organization, c.GetString(CallerEmailKey), permission)
+	if err != nil {
+		return err
+	}
+	if allowed {
+		return nil
+	}
+	if permission == "" {
+		return apperror.Forbidden("Only organization admins may do this")
+	}
+	return apperror.Forbidden("Missing permission %s", permission)
+}
+
+// MaxOrganizationDepth bounds how many levels an organization tree may have.
+const MaxOrganizationDepth = 10
+
+// HasPermission reports whether email may act with permission in organization. Admins of the
+// organization or of any of its ancestors always may; other members only through a team of the
+// organization that was granted it. An empty permission is held by admins only.
+func HasPermission(ctx context.Context, organizations repository.OrganizationStore, teams repository.TeamStore, organization *models.Organization, email, permission string) (bool, error) {
+	if organization.IsAdmin(email) {
+		return true, nil
+	}
+	ancestors, err := Ancestors(ctx, organizations, organization)
+	if err != nil {
+		return false, err
+	}
+	for _, ancestor := range ancestors {
+		if ancestor.IsAdmin(email) {
+			return true, nil
+		}
+	}
+	if permission == "" || !isMember(organization, email) {
+		return false, nil
+	}
+
+	memberOf, err := teams.FindTeamsByMember(ctx, organization.Id.Hex(), email)
+	if err != nil {
+		return false, err
+	}
+	for _, team := range memberOf {
+		if team.HasPermission(permission) {
+			return true, nil
 		}
+	}
+	return false, nil
+}
 
-		// Check if the user is in the list of invited users for the organization.
-		isInvited := false
-		for _, invitedUser := range organization.InvitedUsers {
-			if invitedUser == userEmail {
-				isInvited = true
-				break
-			}
+// IsMember reports whether email belongs to organization, directly or through one of its ancestors.
+func IsMember(ctx context.Context, organizations repository.OrganizationStore, organization *models.Organization, email string) (bool, error) {
+	if isMember(organization, email) || organization.IsAdmin(email) {
+		return true, nil
+	}
+	ancestors, err := Ancestors(ctx, organizations, organization)
+	if err != nil {
+		return false, err
+	}
+	for _, ancestor := range ancestors {
+		if isMember(ancestor, email) || ancestor.IsAdmin(email) {
+			return true, nil
 		}
+	}
+	return false, nil
+}
 
-		// If the user is not invited, respond with an Unauthorized status.
-		if !isInvited {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User is not invited to the organization"})
-			c.Abort()
-			return
+// Ancestors returns the parent of organization, its parent's parent and so on, nearest first.
+// A parent that no longer exists ends the chain.
+func Ancestors(ctx context.Context, organizations repository.OrganizationStore, organization *models.Organization) ([]*models.Organization, error) {
+	var ancestors []*models.Organization
+	for parentID := organization.ParentId; parentID != ""; {
+		// Stop walking a chain that has grown too long, or that a concurrent move turned into a cycle.
+		if len(ancestors) >= MaxOrganizationDepth {
+			return nil, apperror.Conflict("Organization hierarchy is deeper than %d levels", MaxOrganizationDepth)
 		}
 
-		// Proceed to the next handler if the user is invited.
-		c.Next()
+		parent, err := organizations.GetOrganizationById(ctx, parentID)
+		if errors.Is(err, apperror.ErrNotFound) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		ancestors = append(ancestors, parent)
+		parentID = parent.ParentId
+	}
+	return ancestors, nil
+}
+
+func isMember(organization *models.Organization, email string) bool {
+	for _, member := range organization.InvitedUsers {
+		if member == email {
+			return true
+		}
 	}
+	return false
 }
@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/organization_api/pkg/apperror"
+	"github.com/organization_api/pkg/database/mongodb/repository"
+	"github.com/organization_api/pkg/slug"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// organizationParam is the route parameter that addresses an organization by ID or slug.
+const organizationParam = "organization_id"
+
+// ResolveOrganization lets routes address an organization by slug as well as by ID. A current slug
+// is replaced by the ID before later handlers run; a previous slug redirects members to the current one.
+func ResolveOrganization(organizations repository.OrganizationStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		reference := c.Param(organizationParam)
+		if reference == "" || primitive.IsValidObjectID(reference) {
+			c.Next()
+			return
+		}
+
+		end := startSpan(c, "middleware.ResolveOrganization")
+		location, err := resolveOrganization(c, organizations, reference)
+		end(err)
+		if err != nil {
+			c.Error(err)
+			c.Abort()
+			return
+		}
+		if location != "" {
+			// 308 keeps the method and body, so writes through an old slug are redirected too.
+			c.Redirect(http.StatusPermanentRedirect, location)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// resolveOrganization rewrites a current slug in the URL to the organization's ID, or returns the
+// location to redirect a previous slug to.
+func resolveOrganization(c *gin.Context, organizations repository.OrganizationStore, reference string) (string, error) {
+	if !slug.Valid(reference) {
+		return "", apperror.InvalidID("Invalid organization id %q", reference)
+	}
+
+	organization, err := organizations.GetOrganizationBySlug(c.Request.Context(), reference)
+	if err != nil {
+		return "", err
+	}
+
+	if organization.Slug != reference {
+		// Only members learn where a previous slug went; anyone else could use it to follow renames.
+		member, err := IsMember(c.Request.Context(), organizations, organization, c.GetString(CallerEmailKey))
+		if err != nil {
+			return "", err
+		}
+		if !member {
+			return "", apperror.Forbidden("User is not invited to the organization")
+		}
+
+		location := strings.Replace(c.Request.URL.Path, "/organization/"+reference, "/organization/"+organization.Slug, 1)
+		if c.Request.URL.RawQuery != "" {
+			location += "?" + c.Request.URL.RawQuery
+		}
+		return location, nil
+	}
+
+	for i := range c.Params {
+		if c.Params[i].Key == organizationParam {
+			c.Params[i].Value = organization.Id.Hex()
+		}
+	}
+	return "", nil
+}
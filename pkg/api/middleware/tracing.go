@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"reflect"
+	"runtime"
+	"strings"
+
+	"github.com/organization_api/pkg/tracing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// Trace wraps a handler in a span named after it, e.g. handler.CreateOrganizationHandler.
+func Trace(handler gin.HandlerFunc) gin.HandlerFunc {
+	name := "handler." + functionName(handler)
+	return func(c *gin.Context) {
+		end := startSpan(c, name)
+		handler(c)
+
+		var err error
+		if last := c.Errors.Last(); last != nil {
+			err = last.Err
+		}
+		end(err)
+	}
+}
+
+// startSpan opens a span for one step of the request and makes it current for the step's
+// own calls. The returned function records err, if any, ends the span and restores the
+// parent, so work done after the step is not attributed to it.
+func startSpan(c *gin.Context, name string) func(err error) {
+	parent := c.Request.Context()
+	ctx, span := tracing.Start(parent, name)
+	c.Request = c.Request.WithContext(ctx)
+
+	return func(err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+		c.Request = c.Request.WithContext(parent)
+	}
+}
+
+// functionName returns the bare name of a function or method value, e.g. SignupHandler.
+func functionName(fn interface{}) string {
+	name := runtime.FuncForPC(reflect.ValueOf(fn).Pointer()).Name()
+	name = strings.TrimSuffix(name, "-fm")
+	return name[strings.LastIndex(name, ".")+1:]
+}
@@ -3,30 +3,130 @@ package routes
 import (
 	"github.com/organization_api/pkg/api/handlers"
 	"github.com/organization_api/pkg/api/middleware"
+	"github.com/organization_api/pkg/database/mongodb/models"
+	"github.com/organization_api/pkg/metrics"
+	"github.com/organization_api/pkg/utils"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 )
 
 // Routes sets up the application's HTTP routes.
-func Routes(router *gin.Engine) {
+func Routes(router *gin.Engine, h *handlers.Handler) {
+	// Enforce the `validate` struct tags whenever a request body is bound.
+	binding.Validator = utils.StructValidator{}
+
+	// Tag the request with an ID and log it, record request metrics, then render errors
+	// recorded by handlers and middleware as problem+json.
+	router.Use(middleware.RequestID(), middleware.RequestLogger(), middleware.Metrics(), middleware.ErrorHandler())
+	router.NoRoute(middleware.NoRouteHandler)
+
+	// Define health routes for the orchestrator.
+	router.GET("/healthz", h.LivenessHandler)            // Handle liveness probe
+	router.GET("/readyz", h.ReadinessHandler)            // Handle readiness probe
+	router.GET("/metrics", gin.WrapH(metrics.Handler())) // Handle Prometheus scrapes
 
 	// Define authentication routes.
 	auth := router.Group("/auth")
 	{
-		auth.POST("/signup", handlers.SignupHandler)              // Handle user registration
-		auth.POST("/signin", handlers.SignInHandler)              // Handle user login
-		auth.POST("/refresh-token", handlers.RefreshTokenHandler) // Handle token refresh
+		auth.POST("/signup", middleware.Trace(h.SignupHandler))              // Handle user registration
+		auth.POST("/signin", middleware.Trace(h.SignInHandler))              // Handle user login
+		auth.POST("/refresh-token", middleware.Trace(h.RefreshTokenHandler)) // Handle token refresh
+		auth.POST("/verify-email", middleware.Trace(h.VerifyEmailHandler))   // Handle email address confirmation
+
+		// Handle sending another confirmation email to the signed-in user.
+		auth.POST("/verify-email/resend", middleware.AuthMiddleware(h.Tokens), middleware.Trace(h.ResendVerificationHandler))
 	}
 
-	// Define organization routes, secured with authentication.
+	// Define organization routes, secured with authentication. An organization can be addressed by
+	// ID or slug. Changes require the matching permission, held by the owner, admins and members of
+	// teams that were granted it.
 	organization := router.Group("/api")
-	organization.Use(middleware.AuthMiddleware())
+	organization.Use(middleware.AuthMiddleware(h.Tokens), middleware.ResolveOrganization(h.Organizations))
+	{
+		organization.POST("organization", middleware.Trace(h.CreateOrganizationHandler))                                                                               // Handle organization creation
+		organization.GET("/organization/:organization_id", middleware.InviteMiddleware(h.Organizations), middleware.Trace(h.GetOrganizationByIdHandler))               // Handle organization retrieval with invitation check
+		organization.GET("/organization", middleware.Trace(h.GetAllOrganizationsHandler))                                                                              // Handle all organizations retrieval
+		organization.GET("/search", middleware.Trace(h.SearchHandler))                                                                                                 // Handle search over visible organizations and their members
+		organization.POST("/organization/import", middleware.Trace(h.ImportOrganizationsHandler))                                                                      // Handle bulk import
+		organization.GET("/organization/import/:job_id", middleware.Trace(h.GetImportJobHandler))                                                                      // Handle import job polling
+		organization.GET("/organization/export", middleware.Trace(h.ExportOrganizationsHandler))                                                                       // Handle bulk export
+		organization.PUT("/organization/:organization_id", permission(h, models.PermissionManageOrganization), middleware.Trace(h.UpdateOrganizationHandler))          // Handle organization update
+		organization.DELETE("/organization/:organization_id", permission(h, adminsOnly), middleware.Trace(h.DeleteOrganizationHandler))                                // Handle organization deletion
+		organization.POST("/organization/:organization_id/invite", permission(h, models.PermissionInviteMembers), middleware.Trace(h.InviteUserToOrganizationHandler)) // Handle organization invitation
+		organization.POST("/organization/:organization_id/transfer", middleware.Trace(h.TransferOwnershipHandler))                                                     // Handle ownership transfer
+		organization.GET("/organization/:organization_id/children", middleware.InviteMiddleware(h.Organizations), middleware.Trace(h.GetChildOrganizationsHandler))    // Handle child organization listing
+		organization.GET("/organization/:organization_id/ancestors", middleware.InviteMiddleware(h.Organizations), middleware.Trace(h.GetAncestorsHandler))            // Handle ancestor listing
+		organization.PUT("/organization/:organization_id/parent", permission(h, adminsOnly), middleware.Trace(h.SetParentHandler))                                     // Handle re-parenting
+		organization.PUT("/organization/:organization_id/metadata", permission(h, models.PermissionManageOrganization), middleware.Trace(h.SetMetadataHandler))        // Handle metadata replacement
+		organization.PUT("/organization/:organization_id/tags", permission(h, models.PermissionManageOrganization), middleware.Trace(h.SetTagsHandler))                // Handle tag replacement
+		organization.DELETE("/organization/:organization_id/members/:user", permission(h, adminsOnly), middleware.Trace(h.RemoveMemberHandler))                        // Handle member removal
+		organization.POST("/organization/:organization_id/leave", middleware.InviteMiddleware(h.Organizations), middleware.Trace(h.LeaveOrganizationHandler))          // Handle leaving
+		organization.GET("/organization/:organization_id/settings", permission(h, adminsOnly), middleware.Trace(h.GetSettingsHandler))                                 // Handle settings retrieval
+		organization.PATCH("/organization/:organization_id/settings", permission(h, adminsOnly), middleware.Trace(h.UpdateSettingsHandler))                            // Handle settings changes
+
+		// Handle joining an organization that verified the caller's email domain.
+		organization.POST("/organization/:organization_id/join", middleware.Trace(h.JoinOrganizationHandler))
+
+		// Handle batch invitations. The path ends in a custom method, which gin reads as a parameter.
+		organization.POST("/organization/:organization_id/invitations:batch", customMethod("batch"), permission(h, models.PermissionInviteMembers), middleware.Trace(h.BatchInviteHandler))
+	}
+
+	// Define team routes, restricted to members of the organization.
+	teams := organization.Group("/organization/:organization_id/teams")
+	teams.Use(middleware.InviteMiddleware(h.Organizations))
+	{
+		teams.POST("", permission(h, models.PermissionManageTeams), middleware.Trace(h.CreateTeamHandler))            // Handle team creation
+		teams.GET("", middleware.Trace(h.GetTeamsHandler))                                                            // Handle team listing
+		teams.GET("/:team_id", middleware.Trace(h.GetTeamHandler))                                                    // Handle team retrieval
+		teams.PATCH("/:team_id", middleware.Trace(h.RenameTeamHandler))                                               // Handle team rename by maintainers or team managers
+		teams.DELETE("/:team_id", permission(h, models.PermissionManageTeams), middleware.Trace(h.DeleteTeamHandler)) // Handle team removal
+		teams.PUT("/:team_id/permissions", permission(h, adminsOnly), middleware.Trace(h.SetTeamPermissionsHandler))  // Handle permission grants
+		teams.PUT("/:team_id/members/:email", middleware.Trace(h.SetTeamMemberHandler))                               // Handle adding a member or changing their role
+		teams.DELETE("/:team_id/members/:email", middleware.Trace(h.RemoveTeamMemberHandler))                         // Handle member removal
+	}
+
+	// Define domain claim routes, restricted to the owner and admins.
+	domains := organization.Group("/organization/:organization_id/domains")
+	domains.Use(permission(h, adminsOnly))
 	{
-		organization.POST("organization", handlers.CreateOrganizationHandler)                                                  // Handle organization creation
-		organization.GET("/organization/:organization_id", middleware.InviteMiddleware(), handlers.GetOrganizationByIdHandler) // Handle organization retrieval with invitation check
-		organization.GET("/organization", handlers.GetAllOrganizationsHandler)                                                 // Handle all organizations retrieval
-		organization.PUT("/organization/:organization_id", handlers.UpdateOrganizationHandler)                                 // Handle organization update
-		organization.DELETE("/organization/:organization_id", handlers.DeleteOrganizationHandler)                              // Handle organization deletion
-		organization.POST("/organization/:organization_id/invite", handlers.InviteUserToOrganizationHandler)                   // Handle organization invitation
+		domains.POST("", middleware.Trace(h.CreateDomainClaimHandler))                // Handle domain claims
+		domains.GET("", middleware.Trace(h.GetDomainClaimsHandler))                   // Handle domain claim listing
+		domains.POST("/:domain/verify", middleware.Trace(h.VerifyDomainClaimHandler)) // Handle DNS verification
+		domains.DELETE("/:domain", middleware.Trace(h.DeleteDomainClaimHandler))      // Handle domain claim removal
 	}
+
+	// Define webhook routes, restricted to those who manage the organization's webhooks: their
+	// targets, payloads and delivery log are not for every member.
+	if !h.Features.Webhooks {
+		return
+	}
+	webhooks := organization.Group("/organization/:organization_id/webhooks")
+	webhooks.Use(permission(h, models.PermissionManageWebhooks))
+	{
+		webhooks.POST("", middleware.Trace(h.CreateWebhookHandler))                              // Handle webhook subscription
+		webhooks.GET("", middleware.Trace(h.GetWebhooksHandler))                                 // Handle webhook listing
+		webhooks.DELETE("/:webhook_id", middleware.Trace(h.DeleteWebhookHandler))                // Handle webhook removal
+		webhooks.GET("/:webhook_id/deliveries", middleware.Trace(h.GetWebhookDeliveriesHandler)) // Handle delivery log retrieval
+		webhooks.POST("/:webhook_id/test", middleware.Trace(h.TestWebhookHandler))               // Handle test event delivery
+	}
+}
+
+// adminsOnly is the permission no team can be granted; only the owner and admins hold it.
+const adminsOnly = ""
+
+// customMethod guards a route ending in a custom method, such as "/invitations:batch". Gin reads
+// ":batch" as a parameter named batch, so any other value there is not this route.
+func customMethod(name string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Param(name) != ":"+name {
+			middleware.NoRouteHandler(c)
+			c.Abort()
+		}
+	}
+}
+
+// permission restricts a route to callers holding required in the organization in the URL.
+func permission(h *handlers.Handler, required string) gin.HandlerFunc {
+	return middleware.PermissionMiddleware(h.Organizations, h.Teams, required)
 }
@@ -1,18 +1,199 @@
 package pkg
 
 import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/organization_api/config"
+	"github.com/organization_api/pkg/api/handlers"
+	"github.com/organization_api/pkg/api/middleware"
 	"github.com/organization_api/pkg/api/routes"
+	"github.com/organization_api/pkg/database"
+	"github.com/organization_api/pkg/database/mongodb/migrations"
+	"github.com/organization_api/pkg/database/mongodb/repository"
+	"github.com/organization_api/pkg/health"
+	"github.com/organization_api/pkg/logging"
+	"github.com/organization_api/pkg/mail"
+	"github.com/organization_api/pkg/tracing"
+	"github.com/organization_api/pkg/utils"
+	"github.com/organization_api/pkg/webhook"
 
 	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
-// Run starts the web server and registers the API routes.
-func Init() {
-	// Initialize the Gin router with default middleware.
-	router := gin.Default()
+// logLevel is the minimum level of the default logger; it follows app.log_level.
+var logLevel = new(slog.LevelVar)
+
+// Init starts the web server, registers the API routes and blocks until SIGINT or SIGTERM.
+// On a signal the server stops accepting connections, drains in-flight requests and
+// webhook deliveries within http.shutdown_timeout, and disconnects from MongoDB and Redis.
+func Init(watcher *config.Watcher) error {
+	cfg := watcher.Current()
+
+	logLevel.UnmarshalText([]byte(cfg.App.LogLevel))
+	slog.SetDefault(logging.New(os.Stderr, cfg.App.LogFormat, logLevel))
+	gin.SetMode(cfg.App.Mode)
+	utils.ConfigureTokens(cfg.JWT)
+
+	// Export spans before anything else starts so no request goes untraced.
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing, cfg.App.Name)
+	if err != nil {
+		return err
+	}
+
+	// Stop on the first SIGINT or SIGTERM; a second one kills the process immediately.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// Apply reloadable settings whenever the configuration changes.
+	watcher.OnChange(func(old, next *config.Config) {
+		logLevel.UnmarshalText([]byte(next.App.LogLevel))
+		utils.ConfigureTokens(next.JWT)
+	})
+	go watcher.Watch(ctx)
+
+	// Build the handlers on top of the MongoDB repositories.
+	redisClient := config.Init_redis(cfg.Redis)
+	h := handlers.NewHandler(
+		repository.NewOrganizationRepo(),
+		repository.NewUserRepository(),
+		repository.NewWebhookRepo(),
+		repository.NewTeamRepo(),
+		repository.NewImportJobRepo(),
+		repository.NewSettingsRepo(),
+		repository.NewDomainRepo(),
+		repository.NewRedisTokenRevocations(redisClient),
+		repository.NewRedisRateLimits(redisClient),
+	)
+	h.Features = cfg.Features
+	if cfg.Mail.SMTPAddr != "" {
+		h.Mailer = mail.SMTP{Addr: cfg.Mail.SMTPAddr, From: cfg.Mail.From, Username: cfg.Mail.Username, Password: cfg.Mail.Password}
+	}
+	h.VerifyURL = cfg.Mail.VerifyURL
+	h.ConfigureInvitations(cfg.Invitations)
+	watcher.OnChange(func(old, next *config.Config) {
+		h.ConfigureInvitations(next.Invitations)
+	})
+	deps := dependencies{handler: h, health: h.Health, redis: redisClient, tracing: shutdownTracing}
+
+	// Bring the schema up to date; other replicas wait for whichever one takes the lock.
+	migrator := migrations.New(database.GetDatabase())
+	if cfg.Mongo.MigrateOnStart {
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			shutdown(nil, nil, deps, cfg.HTTP)
+			return err
+		}
+		slog.Info("migrations: schema up to date", "applied", applied)
+	}
+
+	// Report readiness only while every dependency answers.
+	h.Health.Register("mongodb", database.Ping)
+	h.Health.Register("migrations", migrator.Check)
+	h.Health.Register("redis", func(ctx context.Context) error {
+		return redisClient.WithContext(ctx).Ping().Err()
+	})
+
+	// Deliver organization events to registered webhooks, through the dispatcher that also sends test events.
+	var dispatcher *webhook.Dispatcher
+	if cfg.Features.Webhooks {
+		dispatcher = h.Dispatcher
+		webhook.SetDefault(dispatcher)
+	}
+
+	// Initialize the Gin router, recovering from panics and continuing any trace from the caller.
+	// Requests are logged by the routes' own structured logger.
+	router := gin.New()
+	router.Use(gin.CustomRecovery(middleware.Recovered), otelgin.Middleware(cfg.App.Name))
 	// Register the API routes with the router.
-	routes.Routes(router)
+	routes.Routes(router, h)
+
+	server := NewServer(cfg.HTTP, router)
+	serveErr := make(chan error, 1)
+	go func() {
+		slog.Info("http: listening", "addr", cfg.HTTP.Addr)
+		serveErr <- server.ListenAndServe()
+	}()
+
+	// Wait for a shutdown signal or for the listener to fail.
+	select {
+	case err := <-serveErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			shutdown(nil, dispatcher, deps, cfg.HTTP)
+			return err
+		}
+	case <-ctx.Done():
+		stop()
+		slog.Info("http: shutting down", "timeout", cfg.HTTP.ShutdownTimeout)
+	}
+
+	return shutdown(server, dispatcher, deps, cfg.HTTP)
+}
+
+// NewServer wraps handler in an http.Server with the configured timeouts and header limit.
+func NewServer(cfg config.HTTPConfig, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           handler,
+		ReadTimeout:       cfg.ReadTimeout,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+	}
+}
+
+// dependencies are the background work and connections stopped on shutdown.
+type dependencies struct {
+	handler *handlers.Handler
+	health  *health.Registry
+	redis   *redis.Client
+	tracing func(context.Context) error
+}
+
+// shutdown fails readiness, drains the server, then the background workers, then closes the
+// connections, sharing a single http.shutdown_timeout deadline. Every step runs even if an earlier one fails.
+func shutdown(server *http.Server, dispatcher *webhook.Dispatcher, deps dependencies, cfg config.HTTPConfig) error {
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	deps.health.SetShuttingDown()
+
+	var errs []error
+	if server != nil {
+		if err := server.Shutdown(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := deps.handler.Shutdown(ctx); err != nil {
+		errs = append(errs, err)
+	}
+	if dispatcher != nil {
+		if err := dispatcher.Shutdown(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := database.Disconnect(ctx); err != nil {
+		errs = append(errs, err)
+	}
+	if err := deps.redis.Close(); err != nil {
+		errs = append(errs, err)
+	}
+	// Flush spans last so those recorded while draining are exported.
+	if err := deps.tracing(ctx); err != nil {
+		errs = append(errs, err)
+	}
 
-	// Start the web server on port  8080.
-	router.Run(":8080")
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	slog.Info("http: shutdown complete")
+	return nil
 }
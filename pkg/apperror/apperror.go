@@ -0,0 +1,169 @@
+package apperror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Stable error codes exposed to API clients in the "code" member of problem responses.
+const (
+	CodeBadRequest   = "bad_request"
+	CodeValidation   = "validation_failed"
+	CodeInvalidID    = "invalid_id"
+	CodeUnauthorized = "unauthorized"
+	CodeForbidden    = "forbidden"
+	CodeNotFound     = "not_found"
+	CodeConflict     = "conflict"
+	CodeRateLimited  = "rate_limited"
+	CodeTimeout      = "timeout"
+	CodeInternal     = "internal_error"
+)
+
+// Error is a typed domain error carrying a stable code and a client-safe message.
+type Error struct {
+	Code    string
+	Message string
+	Fields  []FieldError
+	Err     error
+}
+
+// FieldError describes why a single request field failed validation.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Sentinels for matching with errors.Is; any *Error with the same code matches.
+var (
+	ErrBadRequest   = &Error{Code: CodeBadRequest}
+	ErrValidation   = &Error{Code: CodeValidation}
+	ErrInvalidID    = &Error{Code: CodeInvalidID}
+	ErrUnauthorized = &Error{Code: CodeUnauthorized}
+	ErrForbidden    = &Error{Code: CodeForbidden}
+	ErrNotFound     = &Error{Code: CodeNotFound}
+	ErrConflict     = &Error{Code: CodeConflict}
+	ErrRateLimited  = &Error{Code: CodeRateLimited}
+	ErrTimeout      = &Error{Code: CodeTimeout}
+	ErrInternal     = &Error{Code: CodeInternal}
+)
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Message, e.Err)
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is matches any *Error with the same code.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// WithFields attaches field-level validation details to the error.
+func (e *Error) WithFields(fields []FieldError) *Error {
+	e.Fields = fields
+	return e
+}
+
+// Wrap attaches an underlying cause to the error.
+func (e *Error) Wrap(err error) *Error {
+	e.Err = err
+	return e
+}
+
+func newError(code, format string, args ...interface{}) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// BadRequest reports a malformed request.
+func BadRequest(format string, args ...interface{}) *Error {
+	return newError(CodeBadRequest, format, args...)
+}
+
+// Validation reports a request that is well-formed but violates a rule.
+func Validation(format string, args ...interface{}) *Error {
+	return newError(CodeValidation, format, args...)
+}
+
+// InvalidID reports an identifier that is not in the expected format.
+func InvalidID(format string, args ...interface{}) *Error {
+	return newError(CodeInvalidID, format, args...)
+}
+
+// Unauthorized reports missing or invalid credentials.
+func Unauthorized(format string, args ...interface{}) *Error {
+	return newError(CodeUnauthorized, format, args...)
+}
+
+// Forbidden reports an authenticated caller that may not perform the action.
+func Forbidden(format string, args ...interface{}) *Error {
+	return newError(CodeForbidden, format, args...)
+}
+
+// NotFound reports a missing resource.
+func NotFound(format string, args ...interface{}) *Error {
+	return newError(CodeNotFound, format, args...)
+}
+
+// Conflict reports a request that clashes with existing state, such as a duplicate email.
+func Conflict(format string, args ...interface{}) *Error {
+	return newError(CodeConflict, format, args...)
+}
+
+// RateLimited reports a caller that made too many requests and must wait before retrying.
+func RateLimited(format string, args ...interface{}) *Error {
+	return newError(CodeRateLimited, format, args...)
+}
+
+// Timeout reports an operation that ran past its deadline.
+func Timeout(format string, args ...interface{}) *Error {
+	return newError(CodeTimeout, format, args...)
+}
+
+// Internal reports an unexpected failure; the message is shown to clients, the cause is not.
+func Internal(format string, args ...interface{}) *Error {
+	return newError(CodeInternal, format, args...)
+}
+
+// From converts any error into an *Error. Deadline errors become timeouts and
+// anything unrecognised becomes an internal error.
+func From(err error) *Error {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return Timeout("The operation timed out").Wrap(err)
+	}
+	return Internal("An unexpected error occurred").Wrap(err)
+}
+
+// Status returns the HTTP status code for an error.
+func Status(err error) int {
+	switch From(err).Code {
+	case CodeBadRequest, CodeValidation, CodeInvalidID:
+		return http.StatusBadRequest
+	case CodeUnauthorized:
+		return http.StatusUnauthorized
+	case CodeForbidden:
+		return http.StatusForbidden
+	case CodeNotFound:
+		return http.StatusNotFound
+	case CodeConflict:
+		return http.StatusConflict
+	case CodeRateLimited:
+		return http.StatusTooManyRequests
+	case CodeTimeout:
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
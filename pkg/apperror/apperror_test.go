@@ -0,0 +1,51 @@
+package apperror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestStatus(t *testing.T) {
+	cases := []struct {
+		err    error
+		status int
+	}{
+		{NotFound("Organization not found"), http.StatusNotFound},
+		{InvalidID("Invalid organization id"), http.StatusBadRequest},
+		{Validation("Name is required"), http.StatusBadRequest},
+		{Conflict("Email taken"), http.StatusConflict},
+		{Forbidden("No"), http.StatusForbidden},
+		{Unauthorized("Who?"), http.StatusUnauthorized},
+		{RateLimited("Slow down"), http.StatusTooManyRequests},
+		{fmt.Errorf("query: %w", context.DeadlineExceeded), http.StatusGatewayTimeout},
+		{errors.New("boom"), http.StatusInternalServerError},
+	}
+	for _, tc := range cases {
+		if got := Status(tc.err); got != tc.status {
+			t.Errorf("Status(%v) = %d, want %d", tc.err, got, tc.status)
+		}
+	}
+}
+
+func TestIsMatchesByCode(t *testing.T) {
+	err := fmt.Errorf("wrapped: %w", NotFound("Webhook not found"))
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatal("expected wrapped not found error to match ErrNotFound")
+	}
+	if errors.Is(err, ErrConflict) {
+		t.Fatal("not found error must not match ErrConflict")
+	}
+}
+
+func TestProblemHidesInternalCause(t *testing.T) {
+	problem := NewProblem(errors.New("connection string mongodb://admin:pass@db"), "/api/organization")
+	if problem.Status != http.StatusInternalServerError || problem.Code != CodeInternal {
+		t.Fatalf("unexpected problem: %+v", problem)
+	}
+	if problem.Detail != "An unexpected error occurred" {
+		t.Fatalf("internal cause leaked into detail: %q", problem.Detail)
+	}
+}
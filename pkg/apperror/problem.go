@@ -0,0 +1,33 @@
+package apperror
+
+import "net/http"
+
+// ProblemContentType is the media type of RFC 7807 problem details.
+const ProblemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details body, extended with a stable error code.
+type Problem struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Code     string       `json:"code"`
+	Errors   []FieldError `json:"errors,omitempty"`
+}
+
+// NewProblem builds the problem details for an error raised while serving instance.
+func NewProblem(err error, instance string) Problem {
+	appErr := From(err)
+	status := Status(appErr)
+
+	return Problem{
+		Type:     "urn:organization-api:problem:" + appErr.Code,
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   appErr.Message,
+		Instance: instance,
+		Code:     appErr.Code,
+		Errors:   appErr.Fields,
+	}
+}
@@ -0,0 +1,221 @@
+// Package bulk reads and writes organizations, with their members, as CSV or NDJSON files.
+package bulk
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/organization_api/pkg/apperror"
+	"github.com/organization_api/pkg/database/mongodb/models"
+)
+
+// Formats of import and export files.
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+)
+
+// maxLineBytes bounds a single NDJSON line.
+const maxLineBytes = 1 << 20
+
+// columns are the CSV columns, in the order they are written. Members are separated by semicolons.
+var columns = []string{"name", "description", "slug", "parent", "members"}
+
+// mediaTypes maps the media types files are accepted as to their format.
+var mediaTypes = map[string]string{
+	"text/csv":             FormatCSV,
+	"application/csv":      FormatCSV,
+	"application/x-ndjson": FormatNDJSON,
+	"application/ndjson":   FormatNDJSON,
+	"application/jsonl":    FormatNDJSON,
+}
+
+// FormatOf returns the format of files sent as mediaType.
+func FormatOf(mediaType string) (string, bool) {
+	format, ok := mediaTypes[strings.ToLower(mediaType)]
+	return format, ok
+}
+
+// ContentType returns the media type files of format are written as.
+func ContentType(format string) string {
+	if format == FormatCSV {
+		return "text/csv; charset=utf-8"
+	}
+	return "application/x-ndjson"
+}
+
+// Row is a record read from a file, or the reason it could not be read.
+type Row struct {
+	// Number counts records from 1, not counting the CSV header or blank lines.
+	Number int
+	Record models.OrganizationRecord
+	Err    error
+}
+
+// Read reads every record of a file of format, allowing at most maxRows of them. A record that
+// cannot be decoded is reported in its row; a file that cannot be read as a whole is an error.
+func Read(r io.Reader, format string, maxRows int) ([]Row, error) {
+	if format == FormatCSV {
+		return readCSV(r, maxRows)
+	}
+	return readNDJSON(r, maxRows)
+}
+
+func readCSV(r io.Reader, maxRows int) ([]Row, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, apperror.BadRequest("Malformed CSV: %v", err).Wrap(err)
+	}
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		// Spreadsheets often start the file with a byte order mark.
+		name = strings.TrimPrefix(name, "\uFEFF")
+		index[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"name", "description"} {
+		if _, ok := index[required]; !ok {
+			return nil, apperror.BadRequest("The CSV header must have a %s column", required)
+		}
+	}
+
+	var rows []Row
+	for {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, apperror.BadRequest("Malformed CSV: %v", err).Wrap(err)
+		}
+		if len(rows) == maxRows {
+			return nil, tooManyRows(maxRows)
+		}
+
+		row := Row{Number: len(rows) + 1}
+		if len(fields) != len(header) {
+			row.Err = fmt.Errorf("has %d fields, the header has %d", len(fields), len(header))
+			rows = append(rows, row)
+			continue
+		}
+		column := func(name string) string {
+			if i, ok := index[name]; ok {
+				return strings.TrimSpace(fields[i])
+			}
+			return ""
+		}
+		row.Record = models.OrganizationRecord{
+			Name:        column("name"),
+			Description: column("description"),
+			Slug:        column("slug"),
+			Parent:      column("parent"),
+			Members:     splitMembers(column("members")),
+		}
+		rows = append(rows, row)
+	}
+}
+
+// splitMembers splits a list of emails separated by semicolons, commas or spaces.
+func splitMembers(value string) []string {
+	members := strings.FieldsFunc(value, func(r rune) bool {
+		return r == ';' || r == ',' || r == ' ' || r == '\t' || r == '\n'
+	})
+	if len(members) == 0 {
+		return nil
+	}
+	return members
+}
+
+func readNDJSON(r io.Reader, maxRows int) ([]Row, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineBytes)
+
+	var rows []Row
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if len(rows) == maxRows {
+			return nil, tooManyRows(maxRows)
+		}
+
+		row := Row{Number: len(rows) + 1}
+		if err := json.Unmarshal(line, &row.Record); err != nil {
+			row.Err = fmt.Errorf("is not a JSON object with string fields: %v", err)
+		}
+		rows = append(rows, row)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, apperror.BadRequest("Malformed NDJSON: %v", err).Wrap(err)
+	}
+	return rows, nil
+}
+
+func tooManyRows(maxRows int) error {
+	return apperror.Validation("An import may hold at most %d rows; split the file", maxRows)
+}
+
+// Writer writes records to a file of one format.
+type Writer struct {
+	format string
+	csv    *csv.Writer
+	json   *json.Encoder
+	buf    *bufio.Writer
+	header bool
+}
+
+// NewWriter returns a Writer that writes records of format to w.
+func NewWriter(w io.Writer, format string) *Writer {
+	buf := bufio.NewWriter(w)
+	if format == FormatCSV {
+		return &Writer{format: format, csv: csv.NewWriter(buf), buf: buf}
+	}
+	return &Writer{format: format, json: json.NewEncoder(buf), buf: buf}
+}
+
+// Write writes a record, preceded by the header if it is the first record of a CSV file.
+func (w *Writer) Write(record models.OrganizationRecord) error {
+	if w.format != FormatCSV {
+		return w.json.Encode(record)
+	}
+
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	return w.csv.Write([]string{record.Name, record.Description, record.Slug, record.Parent, strings.Join(record.Members, ";")})
+}
+
+// Flush writes any buffered records to the underlying writer. A CSV file without records still
+// gets its header.
+func (w *Writer) Flush() error {
+	if w.csv != nil {
+		if err := w.writeHeader(); err != nil {
+			return err
+		}
+		w.csv.Flush()
+		if err := w.csv.Error(); err != nil {
+			return err
+		}
+	}
+	return w.buf.Flush()
+}
+
+func (w *Writer) writeHeader() error {
+	if w.header {
+		return nil
+	}
+	w.header = true
+	return w.csv.Write(columns)
+}
@@ -0,0 +1,126 @@
+package bulk
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/organization_api/pkg/apperror"
+	"github.com/organization_api/pkg/database/mongodb/models"
+)
+
+func TestReadCSV(t *testing.T) {
+	file := "\uFEFFMembers, Name ,description,notes\n" +
+		"ada@example.com; bob@example.com,Acme,Widgets,ignored\n" +
+		"\"cat@example.com,dan@example.com\",\"Acme, Inc.\",\"Quoted, with commas\",\n" +
+		"too,few\n"
+
+	rows, err := Read(strings.NewReader(file), FormatCSV, 10)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("expected 3 rows, got %+v", rows)
+	}
+
+	want := models.OrganizationRecord{Name: "Acme", Description: "Widgets", Members: []string{"ada@example.com", "bob@example.com"}}
+	if rows[0].Err != nil || !reflect.DeepEqual(rows[0].Record, want) {
+		t.Errorf("row 1: got %+v, %v", rows[0].Record, rows[0].Err)
+	}
+	if rows[1].Record.Name != "Acme, Inc." || len(rows[1].Record.Members) != 2 {
+		t.Errorf("row 2: got %+v", rows[1].Record)
+	}
+	if rows[2].Number != 3 || rows[2].Err == nil {
+		t.Errorf("row 3: expected a field count error, got %+v", rows[2])
+	}
+}
+
+func TestReadRejectsUnreadableFiles(t *testing.T) {
+	cases := []struct {
+		name   string
+		format string
+		file   string
+		code   error
+	}{
+		{"missing column", FormatCSV, "name,members\nAcme,\n", apperror.ErrBadRequest},
+		{"bad quoting", FormatCSV, "name,description\n\"Acme,Widgets\n", apperror.ErrBadRequest},
+		{"too many csv rows", FormatCSV, "name,description\na,b\nc,d\ne,f\n", apperror.ErrValidation},
+		{"too many ndjson rows", FormatNDJSON, "{}\n{}\n{}\n", apperror.ErrValidation},
+		{"line too long", FormatNDJSON, strings.Repeat("x", maxLineBytes+1), apperror.ErrBadRequest},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := Read(strings.NewReader(tc.file), tc.format, 2); !errors.Is(err, tc.code) {
+				t.Fatalf("expected %v, got %v", tc.code, err)
+			}
+		})
+	}
+}
+
+func TestReadNDJSON(t *testing.T) {
+	file := `{"name":"Acme","description":"Widgets","members":["ada@example.com"]}` + "\n\n" +
+		`{"name": 42}` + "\n" +
+		`{"name":"Orbital","description":"Payloads","parent":"acme"}`
+
+	rows, err := Read(strings.NewReader(file), FormatNDJSON, 10)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("expected 3 rows, blank lines skipped, got %+v", rows)
+	}
+	if rows[0].Err != nil || rows[0].Record.Members[0] != "ada@example.com" {
+		t.Errorf("row 1: got %+v, %v", rows[0].Record, rows[0].Err)
+	}
+	if rows[1].Number != 2 || rows[1].Err == nil {
+		t.Errorf("row 2: expected a decoding error, got %+v", rows[1])
+	}
+	if rows[2].Record.Parent != "acme" {
+		t.Errorf("row 3: got %+v", rows[2].Record)
+	}
+}
+
+func TestWriteReadsBack(t *testing.T) {
+	records := []models.OrganizationRecord{
+		{Name: "Acme, Inc.", Description: "Widgets \"and\" gadgets", Slug: "acme-inc", Members: []string{"ada@example.com", "bob@example.com"}},
+		{Name: "Orbital", Description: "Payloads", Slug: "orbital", Parent: "acme-inc"},
+	}
+
+	for _, format := range []string{FormatCSV, FormatNDJSON} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			w := NewWriter(&buf, format)
+			for _, record := range records {
+				if err := w.Write(record); err != nil {
+					t.Fatalf("Write: %v", err)
+				}
+			}
+			if err := w.Flush(); err != nil {
+				t.Fatalf("Flush: %v", err)
+			}
+
+			rows, err := Read(&buf, format, 10)
+			if err != nil {
+				t.Fatalf("Read: %v", err)
+			}
+			for i, row := range rows {
+				if row.Err != nil || !reflect.DeepEqual(row.Record, records[i]) {
+					t.Errorf("record %d: wrote %+v, read %+v (%v)", i, records[i], row.Record, row.Err)
+				}
+			}
+		})
+	}
+}
+
+func TestFormatOf(t *testing.T) {
+	for mediaType, want := range map[string]string{"text/csv": FormatCSV, "Application/X-NDJSON": FormatNDJSON} {
+		if format, ok := FormatOf(mediaType); !ok || format != want {
+			t.Errorf("FormatOf(%q) = %q, %v", mediaType, format, ok)
+		}
+	}
+	if _, ok := FormatOf("application/json"); ok {
+		t.Error("expected application/json to be unsupported")
+	}
+}
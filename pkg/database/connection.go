@@ -3,51 +3,87 @@ package database
 import (
 	"context"
 	"fmt"
+	"log/slog"
+	"time"
 
 	"github.com/organization_api/config"
 
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.opentelemetry.io/contrib/instrumentation/go.mongodb.org/mongo-driver/mongo/otelmongo"
 )
 
 // Global variables for the MongoDB client and database instances.
 var (
 	client   *mongo.Client
 	database *mongo.Database
+	timeouts = config.DbTimeouts{
+		Connect: 10 * time.Second,
+		Read:    5 * time.Second,
+		Write:   10 * time.Second,
+	}
 )
 
 // Connect establishes a connection to the MongoDB server.
-func Connect() error {
-	// Load configuration settings.
-	cfg, err := config.LoadConfig()
-	if err != nil {
-		fmt.Println(err)
+func Connect(cfg config.DbConfig) error {
+	// Keep the default for any operation timeout left unset.
+	if cfg.Timeouts.Connect > 0 {
+		timeouts.Connect = cfg.Timeouts.Connect
+	}
+	if cfg.Timeouts.Read > 0 {
+		timeouts.Read = cfg.Timeouts.Read
+	}
+	if cfg.Timeouts.Write > 0 {
+		timeouts.Write = cfg.Timeouts.Write
 	}
-	fmt.Println(cfg.URI)
 
-	// Set up client options with the MongoDB URI.
-	clientOptions := options.Client().ApplyURI(cfg.URI)
+	// Set up client options with the MongoDB URI, tracing every command the driver sends.
+	clientOptions := options.Client().ApplyURI(cfg.URI).SetMonitor(otelmongo.NewMonitor())
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Connect)
+	defer cancel()
 
 	// Attempt to connect to the MongoDB server.
-	client, err = mongo.Connect(context.Background(), clientOptions)
+	var err error
+	client, err = mongo.Connect(ctx, clientOptions)
 	if err != nil {
 		return fmt.Errorf("error connecting to MongoDB: %v", err)
 	}
 
 	// Ping the MongoDB server to ensure it's responsive.
-	err = client.Ping(context.Background(), nil)
+	err = client.Ping(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("error pinging MongoDB: %v", err)
 	}
 
 	// Select the database to use.
-	database = client.Database("organization_db")
+	database = client.Database(cfg.DbName)
 
-	fmt.Println("Connected to MongoDB!")
+	slog.Info("mongodb: connected", "database", cfg.DbName)
 
 	return nil
 }
 
+// Ping checks that the MongoDB server is reachable.
+func Ping(ctx context.Context) error {
+	if client == nil {
+		return fmt.Errorf("MongoDB is not connected")
+	}
+	return client.Ping(ctx, nil)
+}
+
+// Disconnect closes the MongoDB client, waiting for in-use connections until ctx expires.
+func Disconnect(ctx context.Context) error {
+	if client == nil {
+		return nil
+	}
+	if err := client.Disconnect(ctx); err != nil {
+		return fmt.Errorf("error disconnecting from MongoDB: %v", err)
+	}
+	client, database = nil, nil
+	return nil
+}
+
 // GetClient retrieves the global MongoDB client instance.
 func GetClient() *mongo.Client {
 	// Return the MongoDB client instance.
@@ -59,3 +95,8 @@ func GetDatabase() *mongo.Database {
 	// Return the MongoDB database instance.
 	return database
 }
+
+// GetTimeouts retrieves the per-operation timeouts applied by the repositories.
+func GetTimeouts() config.DbTimeouts {
+	return timeouts
+}
@@ -0,0 +1,240 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/organization_api/pkg/slug"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// SlugIndex is the name of the unique index over every current and previous organization slug.
+const SlugIndex = "slugs_unique"
+
+// All returns every migration in version order. Append new migrations with the next
+// version; never renumber or edit one that has shipped.
+func All() []Migration {
+	return []Migration{
+		{
+			Version:     1,
+			Description: "remove the id field written by users created before Id was mapped to _id",
+			Up: func(ctx context.Context, db *mongo.Database) error {
+				_, err := db.Collection("user").UpdateMany(ctx,
+					bson.M{"id": bson.M{"$exists": true}},
+					bson.M{"$unset": bson.M{"id": ""}},
+				)
+				return err
+			},
+		},
+		{
+			Version:     2,
+			Description: "unique index on user email",
+			Up: func(ctx context.Context, db *mongo.Database) error {
+				err := createIndexes(ctx, db.Collection("user"), mongo.IndexModel{
+					Keys:    bson.D{{Key: "email", Value: 1}},
+					Options: options.Index().SetName("email_unique").SetUnique(true),
+				})
+				if mongo.IsDuplicateKeyError(err) {
+					return fmt.Errorf("duplicate user emails must be merged before the index can be built: %w", err)
+				}
+				return err
+			},
+		},
+		{
+			Version:     3,
+			Description: "index organizations by invited users and name",
+			Up: func(ctx context.Context, db *mongo.Database) error {
+				return createIndexes(ctx, db.Collection("organization"),
+					mongo.IndexModel{Keys: bson.D{{Key: "invited_users", Value: 1}}, Options: options.Index().SetName("invited_users")},
+					mongo.IndexModel{Keys: bson.D{{Key: "name", Value: 1}}, Options: options.Index().SetName("name")},
+				)
+			},
+		},
+		{
+			Version:     4,
+			Description: "index webhooks by organization and deliveries by webhook",
+			Up: func(ctx context.Context, db *mongo.Database) error {
+				err := createIndexes(ctx, db.Collection("webhook"), mongo.IndexModel{
+					Keys:    bson.D{{Key: "organization_id", Value: 1}, {Key: "active", Value: 1}},
+					Options: options.Index().SetName("organization_active"),
+				})
+				if err != nil {
+					return err
+				}
+				return createIndexes(ctx, db.Collection("webhook_delivery"), mongo.IndexModel{
+					Keys:    bson.D{{Key: "webhook_id", Value: 1}, {Key: "created_at", Value: -1}},
+					Options: options.Index().SetName("webhook_created_at"),
+				})
+			},
+		},
+		{
+			Version:     5,
+			Description: "unique team names per organization and index teams by member",
+			Up: func(ctx context.Context, db *mongo.Database) error {
+				return createIndexes(ctx, db.Collection("team"),
+					mongo.IndexModel{
+						Keys:    bson.D{{Key: "organization_id", Value: 1}, {Key: "name", Value: 1}},
+						Options: options.Index().SetName("organization_name_unique").SetUnique(true),
+					},
+					mongo.IndexModel{
+						Keys:    bson.D{{Key: "organization_id", Value: 1}, {Key: "members.email", Value: 1}},
+						Options: options.Index().SetName("organization_member"),
+					},
+				)
+			},
+		},
+		{
+			Version:     6,
+			Description: "index organizations by parent",
+			Up: func(ctx context.Context, db *mongo.Database) error {
+				return createIndexes(ctx, db.Collection("organization"), mongo.IndexModel{
+					Keys:    bson.D{{Key: "parent_id", Value: 1}},
+					Options: options.Index().SetName("parent_id").SetSparse(true),
+				})
+			},
+		},
+		{
+			Version:     7,
+			Description: "unique organization slugs, backfilled from names",
+			Up: func(ctx context.Context, db *mongo.Database) error {
+				organizations := db.Collection("organization")
+				err := createIndexes(ctx, organizations, mongo.IndexModel{
+					Keys: bson.D{{Key: "slugs", Value: 1}},
+					Options: options.Index().SetName(SlugIndex).SetUnique(true).
+						SetPartialFilterExpression(bson.M{"slugs": bson.M{"$exists": true}}),
+				})
+				if err != nil {
+					return err
+				}
+				return backfillSlugs(ctx, organizations)
+			},
+		},
+		{
+			Version:     8,
+			Description: "text indexes for searching organizations and users",
+			Up: func(ctx context.Context, db *mongo.Database) error {
+				// Names and emails are not prose, so words are matched as written: no stemming or stop words.
+				err := createIndexes(ctx, db.Collection("organization"), mongo.IndexModel{
+					Keys: bson.D{{Key: "name", Value: "text"}, {Key: "description", Value: "text"}},
+					Options: options.Index().SetName("organization_text").SetDefaultLanguage("none").
+						SetWeights(bson.D{{Key: "name", Value: 10}, {Key: "description", Value: 1}}),
+				})
+				if err != nil {
+					return err
+				}
+				return createIndexes(ctx, db.Collection("user"), mongo.IndexModel{
+					Keys: bson.D{{Key: "name", Value: "text"}, {Key: "email", Value: "text"}},
+					Options: options.Index().SetName("user_text").SetDefaultLanguage("none").
+						SetWeights(bson.D{{Key: "name", Value: 10}, {Key: "email", Value: 5}}),
+				})
+			},
+		},
+		{
+			Version:     9,
+			Description: "expire import jobs once they are no longer kept",
+			Up: func(ctx context.Context, db *mongo.Database) error {
+				return createIndexes(ctx, db.Collection("import_job"), mongo.IndexModel{
+					Keys:    bson.D{{Key: "expires_at", Value: 1}},
+					Options: options.Index().SetName("expires_at_ttl").SetExpireAfterSeconds(0),
+				})
+			},
+		},
+		{
+			Version:     10,
+			Description: "index organizations by tag and metadata for filtered listing",
+			Up: func(ctx context.Context, db *mongo.Database) error {
+				// Metadata keys are chosen by clients, so a wildcard index covers whichever ones they filter on.
+				return createIndexes(ctx, db.Collection("organization"),
+					mongo.IndexModel{
+						Keys:    bson.D{{Key: "tags", Value: 1}},
+						Options: options.Index().SetName("tags").SetSparse(true),
+					},
+					mongo.IndexModel{
+						Keys:    bson.D{{Key: "metadata.$**", Value: 1}},
+						Options: options.Index().SetName("metadata_wildcard"),
+					},
+				)
+			},
+		},
+		{
+			Version:     11,
+			Description: "index pending invitations by email",
+			Up: func(ctx context.Context, db *mongo.Database) error {
+				// Signing up settles the invitations of the new account across every organization.
+				return createIndexes(ctx, db.Collection("organization"), mongo.IndexModel{
+					Keys:    bson.D{{Key: "invitations.email", Value: 1}},
+					Options: options.Index().SetName("invitations_email").SetSparse(true),
+				})
+			},
+		},
+		{
+			Version:     12,
+			Description: "create domain claim indexes",
+			Up: func(ctx context.Context, db *mongo.Database) error {
+				// Any number of organizations may claim a domain, but only one can verify it.
+				return createIndexes(ctx, db.Collection("domain_claim"),
+					mongo.IndexModel{
+						Keys:    bson.D{{Key: "organization_id", Value: 1}, {Key: "domain", Value: 1}},
+						Options: options.Index().SetName("organization_domain").SetUnique(true),
+					},
+					mongo.IndexModel{
+						Keys: bson.D{{Key: "domain", Value: 1}},
+						Options: options.Index().SetName("verified_domain").SetUnique(true).
+							SetPartialFilterExpression(bson.M{"status": "verified"}),
+					},
+				)
+			},
+		},
+	}
+}
+
+// backfillSlugs gives every organization without a slug one derived from its name, letting the
+// unique index settle collisions.
+func backfillSlugs(ctx context.Context, organizations *mongo.Collection) error {
+	cursor, err := organizations.Find(ctx, bson.M{"slug": bson.M{"$exists": false}},
+		options.Find().SetProjection(bson.M{"name": 1}))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var org struct {
+			Id   primitive.ObjectID `bson:"_id"`
+			Name string             `bson:"name"`
+		}
+		if err := cursor.Decode(&org); err != nil {
+			return err
+		}
+
+		base := slug.Make(org.Name)
+		for n := 1; ; n++ {
+			if n > slug.MaxAttempts {
+				return fmt.Errorf("no free slug for organization %s (%q)", org.Id.Hex(), org.Name)
+			}
+			candidate := slug.Candidate(base, n)
+			_, err := organizations.UpdateOne(ctx,
+				bson.M{"_id": org.Id, "slug": bson.M{"$exists": false}},
+				bson.M{"$set": bson.M{"slug": candidate, "slugs": bson.A{candidate}}},
+			)
+			if mongo.IsDuplicateKeyError(err) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			break
+		}
+	}
+	return cursor.Err()
+}
+
+// createIndexes builds indexes; building an index that already exists with the same options is a no-op.
+func createIndexes(ctx context.Context, collection *mongo.Collection, models ...mongo.IndexModel) error {
+	_, err := collection.Indexes().CreateMany(ctx, models)
+	return err
+}
@@ -0,0 +1,137 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestVersionsAreUniqueAndOrdered(t *testing.T) {
+	previous := 0
+	for _, migration := range All() {
+		if migration.Version <= previous {
+			t.Errorf("migration %d follows %d; versions must increase", migration.Version, previous)
+		}
+		if migration.Description == "" || migration.Up == nil {
+			t.Errorf("migration %d needs a description and an Up function", migration.Version)
+		}
+		previous = migration.Version
+	}
+}
+
+// testDatabase returns a throwaway database, skipping unless MONGODB_TEST_URI is set.
+func testDatabase(t *testing.T) *mongo.Database {
+	t.Helper()
+	uri := os.Getenv("MONGODB_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGODB_TEST_URI not set")
+	}
+
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	db := client.Database(fmt.Sprintf("organization_api_migrations_%d", time.Now().UnixNano()))
+	t.Cleanup(func() {
+		db.Drop(context.Background())
+		client.Disconnect(context.Background())
+	})
+	return db
+}
+
+func TestUpAppliesOnceAndEnforcesUniqueEmail(t *testing.T) {
+	ctx := context.Background()
+	db := testDatabase(t)
+	db.Collection("user").InsertOne(ctx, bson.M{"email": "a@example.com", "id": "000000000000000000000000"})
+
+	migrator := New(db)
+	applied, err := migrator.Up(ctx)
+	if err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if len(applied) != len(All()) {
+		t.Fatalf("applied %v, want all %d migrations", applied, len(All()))
+	}
+	if err := migrator.Check(ctx); err != nil {
+		t.Fatalf("Check after Up: %v", err)
+	}
+
+	applied, err = migrator.Up(ctx)
+	if err != nil || len(applied) != 0 {
+		t.Fatalf("second Up applied %v, %v; want nothing", applied, err)
+	}
+
+	if n, _ := db.Collection("user").CountDocuments(ctx, bson.M{"id": bson.M{"$exists": true}}); n != 0 {
+		t.Errorf("stray id fields remain on %d users", n)
+	}
+	_, err = db.Collection("user").InsertOne(ctx, bson.M{"email": "a@example.com"})
+	if !mongo.IsDuplicateKeyError(err) {
+		t.Errorf("expected the unique email index to reject a duplicate, got %v", err)
+	}
+}
+
+func TestUpWaitsForLockHolder(t *testing.T) {
+	ctx := context.Background()
+	db := testDatabase(t)
+
+	holder := New(db)
+	if err := holder.lock(ctx); err != nil {
+		t.Fatalf("lock: %v", err)
+	}
+
+	waiter := New(db)
+	waiter.PollInterval = 10 * time.Millisecond
+	waitCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	if _, err := waiter.Up(waitCtx); err == nil {
+		t.Fatal("Up ran while another owner held the lock")
+	}
+
+	holder.unlock(ctx)
+	if _, err := waiter.Up(ctx); err != nil {
+		t.Fatalf("Up after the lock was released: %v", err)
+	}
+}
+
+func TestUpRenewsLockWhileRunning(t *testing.T) {
+	ctx := context.Background()
+	db := testDatabase(t)
+
+	rival := New(db)
+	rival.PollInterval = 10 * time.Millisecond
+
+	var rivalErr error
+	holder := New(db)
+	holder.LockTTL = 150 * time.Millisecond
+	holder.Migrations = []Migration{{
+		Version:     1,
+		Description: "outlive the lock TTL",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			time.Sleep(3 * holder.LockTTL)
+			lockCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+			defer cancel()
+			rivalErr = rival.lock(lockCtx)
+			return nil
+		},
+	}}
+
+	if _, err := holder.Up(ctx); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if rivalErr == nil {
+		t.Fatal("another replica took the lock while migrations were still running")
+	}
+}
+
+func TestCheckReportsPending(t *testing.T) {
+	db := testDatabase(t)
+	if err := New(db).Check(context.Background()); err == nil {
+		t.Fatal("expected pending migrations on an empty database")
+	}
+}
@@ -0,0 +1,230 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Collections used to track migrations.
+const (
+	historyCollection = "schema_migrations"
+	lockCollection    = "schema_lock"
+	lockID            = "migrations"
+)
+
+// Migration is a single forward step of the database schema.
+// Up must be safe to re-run if it fails part way, since it is retried on the next run.
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(ctx context.Context, db *mongo.Database) error
+}
+
+// Record is a migration applied to the database.
+type Record struct {
+	Version     int       `bson:"_id" json:"version"`
+	Description string    `bson:"description" json:"description"`
+	AppliedAt   time.Time `bson:"applied_at" json:"applied_at"`
+}
+
+// Migrator applies migrations in version order, holding a lock so only one replica runs them at a time.
+type Migrator struct {
+	DB         *mongo.Database
+	Migrations []Migration
+	// Owner identifies this process in the lock document.
+	Owner string
+	// LockTTL is how long a lock is honoured; a replica that dies holding it blocks others at most this long.
+	// The holder renews the lock every third of LockTTL while migrations run.
+	LockTTL time.Duration
+	// PollInterval is how often a replica waiting for the lock retries.
+	PollInterval time.Duration
+}
+
+// New creates a Migrator for db with every registered migration.
+func New(db *mongo.Database) *Migrator {
+	host, _ := os.Hostname()
+	return &Migrator{
+		DB:           db,
+		Migrations:   All(),
+		Owner:        fmt.Sprintf("%s/%d/%d", host, os.Getpid(), time.Now().UnixNano()),
+		LockTTL:      5 * time.Minute,
+		PollInterval: time.Second,
+	}
+}
+
+// errLockLost cancels a run whose lock expired or was taken over by another replica.
+var errLockLost = errors.New("migration lock lost")
+
+// Up applies every pending migration and returns the versions it applied.
+// It waits for another replica's run to finish, bounded by ctx.
+// The run is cancelled if the lock cannot be renewed before it expires.
+func (m *Migrator) Up(ctx context.Context) (applied []int, err error) {
+	if err := m.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer m.unlock(context.WithoutCancel(ctx))
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	go m.renew(ctx, cancel)
+	defer func() {
+		if cause := context.Cause(ctx); err != nil && errors.Is(cause, errLockLost) {
+			err = fmt.Errorf("%w: %w", cause, err)
+		}
+	}()
+
+	// Read the history under the lock, so migrations applied by the previous holder are seen.
+	pending, err := m.Pending(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, migration := range pending {
+		slog.InfoContext(ctx, "migrations: applying", "version", migration.Version, "description", migration.Description)
+		if err := migration.Up(ctx, m.DB); err != nil {
+			return applied, fmt.Errorf("migration %d (%s): %w", migration.Version, migration.Description, err)
+		}
+
+		record := Record{Version: migration.Version, Description: migration.Description, AppliedAt: time.Now().UTC()}
+		if _, err := m.DB.Collection(historyCollection).InsertOne(ctx, record); err != nil {
+			return applied, fmt.Errorf("recording migration %d: %w", migration.Version, err)
+		}
+		applied = append(applied, migration.Version)
+	}
+	return applied, nil
+}
+
+// Applied lists the migrations recorded in the database, oldest first.
+func (m *Migrator) Applied(ctx context.Context) ([]Record, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+	cursor, err := m.DB.Collection(historyCollection).Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	var records []Record
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// Pending lists the migrations not yet applied, in version order.
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	records, err := m.Applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	done := make(map[int]bool, len(records))
+	for _, record := range records {
+		done[record.Version] = true
+	}
+
+	var pending []Migration
+	for _, migration := range sorted(m.Migrations) {
+		if !done[migration.Version] {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+// Check reports an error while migrations are pending; it suits a readiness check.
+func (m *Migrator) Check(ctx context.Context) error {
+	pending, err := m.Pending(ctx)
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%d migrations pending, first is %d", len(pending), pending[0].Version)
+	}
+	return nil
+}
+
+// lock takes the migration lock, waiting while another live owner holds it.
+// The lock document is upserted only when absent or expired; otherwise the
+// insert collides with the holder's _id and reports a duplicate key.
+func (m *Migrator) lock(ctx context.Context) error {
+	locks := m.DB.Collection(lockCollection)
+	for {
+		now := time.Now().UTC()
+		filter := bson.M{
+			"_id": lockID,
+			"$or": bson.A{
+				bson.M{"expires_at": bson.M{"$lt": now}},
+				bson.M{"owner": m.Owner},
+			},
+		}
+		update := bson.M{"$set": bson.M{"owner": m.Owner, "expires_at": now.Add(m.LockTTL)}}
+		_, err := locks.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+		if err == nil {
+			return nil
+		}
+		if !mongo.IsDuplicateKeyError(err) {
+			return fmt.Errorf("acquiring migration lock: %w", err)
+		}
+
+		slog.InfoContext(ctx, "migrations: waiting for another replica to finish")
+		select {
+		case <-time.After(m.PollInterval):
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for migration lock: %w", ctx.Err())
+		}
+	}
+}
+
+// renew extends the lock until ctx is done. A failed renewal is retried on the next tick
+// while the lock is still valid; once it has expired, or another owner holds it, the run is cancelled.
+func (m *Migrator) renew(ctx context.Context, cancel context.CancelCauseFunc) {
+	ticker := time.NewTicker(m.LockTTL / 3)
+	defer ticker.Stop()
+
+	expires := time.Now().Add(m.LockTTL)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		now := time.Now().UTC()
+		result, err := m.DB.Collection(lockCollection).UpdateOne(ctx,
+			bson.M{"_id": lockID, "owner": m.Owner},
+			bson.M{"$set": bson.M{"expires_at": now.Add(m.LockTTL)}})
+		switch {
+		case err == nil && result.MatchedCount == 0:
+			cancel(errLockLost)
+			return
+		case err == nil:
+			expires = now.Add(m.LockTTL)
+		case ctx.Err() != nil:
+			return
+		case now.After(expires):
+			cancel(fmt.Errorf("%w: %w", errLockLost, err))
+			return
+		default:
+			slog.WarnContext(ctx, "migrations: failed to renew lock", "error", err)
+		}
+	}
+}
+
+func (m *Migrator) unlock(ctx context.Context) {
+	_, err := m.DB.Collection(lockCollection).DeleteOne(ctx, bson.M{"_id": lockID, "owner": m.Owner})
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		slog.ErrorContext(ctx, "migrations: failed to release lock", "error", err)
+	}
+}
+
+func sorted(migrations []Migration) []Migration {
+	out := append([]Migration(nil), migrations...)
+	sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+	return out
+}
@@ -3,7 +3,7 @@ package models
 // structs for authentication
 
 type AuthCreds struct {
-	Email    string `json:"email,omitempty" validate:"required"`
+	Email    string `json:"email,omitempty" validate:"required,email"`
 	Password string `json:"password,omitempty" validate:"required"`
 }
 
@@ -14,5 +14,17 @@ type AuthResponse struct {
 }
 
 type RefreshToken struct {
-	Token string `json:"token"`
+	Token string `json:"token" validate:"required"`
+}
+
+// VerifyEmailRequest confirms an email address with the token emailed to it.
+type VerifyEmailRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type VerifyEmailResponse struct {
+	Message string `json:"message"`
+	// DomainOrganization is set when the email's domain is verified by an organization that lets
+	// people with it join.
+	DomainOrganization *DomainMatch `json:"domain_organization,omitempty"`
 }
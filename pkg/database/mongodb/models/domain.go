@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Statuses of a domain claim.
+const (
+	DomainPending  = "pending"
+	DomainVerified = "verified"
+)
+
+// DomainClaim is an organization's claim to an email domain. Once its TXT record is found, the
+// claim is verified, and no other organization can verify the same domain.
+type DomainClaim struct {
+	Id             primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	OrganizationId string             `bson:"organization_id" json:"organization_id"`
+	Domain         string             `bson:"domain" json:"domain"`
+	// Token is published in the TXT record that proves control of the domain.
+	Token      string     `bson:"token" json:"-"`
+	Status     string     `bson:"status" json:"status"`
+	CreatedBy  string     `bson:"created_by" json:"created_by"`
+	CreatedAt  time.Time  `bson:"created_at" json:"created_at"`
+	VerifiedAt *time.Time `bson:"verified_at,omitempty" json:"verified_at,omitempty"`
+}
+
+// DomainClaimRequest claims an email domain for an organization.
+type DomainClaimRequest struct {
+	Domain string `json:"domain" validate:"required,fqdn,max=253"`
+}
+
+// DomainMatch is the organization that verified the domain of a new account's email.
+type DomainMatch struct {
+	OrganizationId string `json:"organization_id"`
+	Name           string `json:"name"`
+	// Joined is true when the account became a member; otherwise it may join the organization itself.
+	Joined bool `json:"joined"`
+}
@@ -0,0 +1,92 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Outcomes of a row of an import.
+const (
+	// ImportRowValid marks a row a dry run found nothing wrong with.
+	ImportRowValid   = "valid"
+	ImportRowCreated = "created"
+	// ImportRowInvalid marks a row that was skipped because it failed validation.
+	ImportRowInvalid = "invalid"
+	// ImportRowFailed marks a valid row that could not be saved.
+	ImportRowFailed = "failed"
+)
+
+// Statuses of an import job.
+const (
+	ImportJobRunning = "running"
+	// ImportJobCompleted means every row was processed, though some may have failed.
+	ImportJobCompleted = "completed"
+	// ImportJobFailed means the job stopped before processing every row.
+	ImportJobFailed = "failed"
+)
+
+// OrganizationRecord is one organization, with its members, in an import or export file.
+type OrganizationRecord struct {
+	Name        string `json:"name" validate:"required,min=2,max=100,orgname"`
+	Description string `json:"description" validate:"required,max=1000"`
+	// Slug names the organization within the file so later rows can use it as their parent.
+	// Imported organizations get slugs of their own.
+	Slug string `json:"slug,omitempty" validate:"max=64"`
+	// Parent is the ID or slug of an existing organization, or the slug of an earlier row.
+	Parent  string   `json:"parent,omitempty" validate:"max=64"`
+	Members []string `json:"members,omitempty" validate:"max=1000,dive,email"`
+}
+
+// ImportRowResult is what happened to one row of an import. Rows are numbered from 1, not
+// counting the CSV header or blank lines.
+type ImportRowResult struct {
+	Row            int                `bson:"row" json:"row"`
+	Status         string             `bson:"status" json:"status"`
+	OrganizationId string             `bson:"organization_id,omitempty" json:"organization_id,omitempty"`
+	Slug           string             `bson:"slug,omitempty" json:"slug,omitempty"`
+	Errors         []ImportFieldError `bson:"errors,omitempty" json:"errors,omitempty"`
+}
+
+// ImportFieldError explains why a row, or one of its fields, was not imported.
+type ImportFieldError struct {
+	Field   string `bson:"field,omitempty" json:"field,omitempty"`
+	Message string `bson:"message" json:"message"`
+}
+
+// ImportReport sums up an import, row by row.
+type ImportReport struct {
+	DryRun    bool              `bson:"dry_run" json:"dry_run"`
+	Total     int               `bson:"total" json:"total"`
+	Processed int               `bson:"processed" json:"processed"`
+	Created   int               `bson:"created" json:"created"`
+	Failed    int               `bson:"failed" json:"failed"`
+	Rows      []ImportRowResult `bson:"rows" json:"rows"`
+}
+
+// ImportJob is an import running in the background, polled for progress.
+type ImportJob struct {
+	Id primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	// Owner is the email of the caller who started the import; only they can see the job.
+	Owner        string `bson:"owner" json:"-"`
+	Status       string `bson:"status" json:"status"`
+	Error        string `bson:"error,omitempty" json:"error,omitempty"`
+	ImportReport `bson:",inline"`
+	CreatedAt    time.Time `bson:"created_at" json:"created_at"`
+	UpdatedAt    time.Time `bson:"updated_at" json:"updated_at"`
+	// ExpiresAt is when the job is forgotten.
+	ExpiresAt time.Time `bson:"expires_at" json:"expires_at"`
+}
+
+// ImportRequest holds the query parameters of an import.
+type ImportRequest struct {
+	// DryRun validates every row without creating anything.
+	DryRun bool `form:"dry_run" json:"dry_run"`
+	// Async runs the import as a background job even if the file is small.
+	Async bool `form:"async" json:"async"`
+}
+
+// ExportRequest holds the query parameters of an export.
+type ExportRequest struct {
+	Format string `form:"format,default=ndjson" json:"format" validate:"oneof=csv ndjson"`
+}
@@ -1,20 +1,140 @@
 package models
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import (
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
 
 // structs for organization
 
 type Organization struct {
 	Id           primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
-	Name         string             `bson:"name,omitempty" json:"name,omitempty" validate:"required"`
-	Description  string             `bson:"description,omitempty" json:"description,omitempty" validate:"required"`
-	InvitedUsers []string           `bson:"invited_users,omitempty" json:"invited_users,omitempty"`
+	Name         string             `bson:"name,omitempty" json:"name,omitempty" validate:"required,min=2,max=100,orgname"`
+	Description  string             `bson:"description,omitempty" json:"description,omitempty" validate:"required,max=1000"`
+	InvitedUsers []string           `bson:"invited_users,omitempty" json:"invited_users,omitempty" validate:"dive,email"`
+	// Slug is the unique, URL-safe name the organization can be addressed by instead of its ID.
+	Slug string `bson:"slug,omitempty" json:"slug,omitempty"`
+	// Slugs holds the current slug and every previous one, which keep redirecting to the organization.
+	Slugs []string `bson:"slugs,omitempty" json:"-"`
+	// Owner is the email of the member who owns the organization; it is set by the API, never bound from a request.
+	Owner string `bson:"owner,omitempty" json:"owner,omitempty"`
+	// Admins are members who may manage the organization besides its owner.
+	Admins []string `bson:"admins,omitempty" json:"admins,omitempty"`
+	// ParentId is the ID of the parent organization; top-level organizations have none.
+	ParentId string `bson:"parent_id,omitempty" json:"parent_id,omitempty"`
+	// OwnershipTransfers records every change of owner, oldest first.
+	OwnershipTransfers []OwnershipTransfer `bson:"ownership_transfers,omitempty" json:"ownership_transfers,omitempty"`
+	// Metadata holds free-form values clients attach to the organization, such as a billing ID or region.
+	Metadata map[string]string `bson:"metadata,omitempty" json:"metadata,omitempty" validate:"max=50,dive,keys,metakey,endkeys,required,max=500"`
+	// Invitations are the pending invitations of invited users who have no account yet.
+	Invitations []Invitation `bson:"invitations,omitempty" json:"invitations,omitempty"`
+	// Tags are short labels the organization can be listed by.
+	Tags []string `bson:"tags,omitempty" json:"tags,omitempty" validate:"max=20,unique,dive,tag"`
+}
+
+// IsAdmin reports whether email is the owner or one of the admins of the organization.
+func (org *Organization) IsAdmin(email string) bool {
+	if email == "" {
+		return false
+	}
+	if org.Owner == email {
+		return true
+	}
+	for _, admin := range org.Admins {
+		if admin == email {
+			return true
+		}
+	}
+	return false
 }
+
+// OwnershipTransfer is one entry in the ownership history of an organization.
+type OwnershipTransfer struct {
+	From string    `bson:"from,omitempty" json:"from,omitempty"`
+	To   string    `bson:"to" json:"to"`
+	At   time.Time `bson:"at" json:"at"`
+}
+
 type OrganizationUpdate struct {
-	Name        string `json:"name,omitempty" validate:"required"`
-	Description string `json:"description,omitempty" validate:"required"`
+	Name        string `json:"name,omitempty" validate:"required,min=2,max=100,orgname"`
+	Description string `json:"description,omitempty" validate:"required,max=1000"`
+}
+
+// MetadataRequest replaces the metadata of an organization; an empty map removes it.
+type MetadataRequest struct {
+	Metadata map[string]string `json:"metadata" validate:"max=50,dive,keys,metakey,endkeys,required,max=500"`
+}
+
+// TagsRequest replaces the tags of an organization; an empty list removes them.
+type TagsRequest struct {
+	Tags []string `json:"tags" validate:"max=20,unique,dive,tag"`
+}
+
+// OrganizationFilter narrows a list of organizations to those carrying every tag and every
+// metadata entry given. The zero value matches every organization.
+type OrganizationFilter struct {
+	// Ids, when not nil, limits the list to these organizations, e.g. the ones the caller can see.
+	Ids      []primitive.ObjectID
+	Tags     []string
+	Metadata map[string]string
+}
+
+// Matches reports whether org is one of the filter's organizations and carries every tag and
+// metadata entry in it.
+func (filter OrganizationFilter) Matches(org *Organization) bool {
+	if filter.Ids != nil && !slices.Contains(filter.Ids, org.Id) {
+		return false
+	}
+	for _, tag := range filter.Tags {
+		if !slices.Contains(org.Tags, tag) {
+			return false
+		}
+	}
+	for key, value := range filter.Metadata {
+		if current, ok := org.Metadata[key]; !ok || current != value {
+			return false
+		}
+	}
+	return true
+}
+
+type ParentRequestBody struct {
+	// ParentId is empty to move the organization to the top level.
+	ParentId string `json:"parent_id"`
 }
 
 type InviterequestBody struct {
-	UserEmail string `json:"user_email" binding:"required,email"`
+	UserEmail string `json:"user_email" validate:"required,email,max=254"`
+}
+
+// BatchInviteRequest invites several users at once. Each email is checked on its own, so one bad
+// address does not fail the others.
+type BatchInviteRequest struct {
+	Emails []string `json:"emails" validate:"required,min=1,max=100"`
+}
+
+// Outcomes of one email in a batch invitation.
+const (
+	InvitationInvited       = "invited"
+	InvitationAlreadyMember = "already_member"
+	InvitationInvalid       = "invalid"
+	InvitationDuplicate     = "duplicate"
+	// InvitationDomainNotAllowed is an email outside the organization's allowed domains.
+	InvitationDomainNotAllowed = "domain_not_allowed"
+)
+
+// InvitationResult reports what a batch invitation did with one email.
+type InvitationResult struct {
+	Email  string `json:"email"`
+	Status string `json:"status"`
+	// ExpiresAt is when the invitation lapses, for invited users who have no account yet.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// TransferOwnershipRequest names the member who takes over an organization; the owner confirms with their password.
+type TransferOwnershipRequest struct {
+	NewOwner string `json:"new_owner" validate:"required,email,max=254"`
+	Password string `json:"password" validate:"required,max=72"`
 }
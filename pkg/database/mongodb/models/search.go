@@ -0,0 +1,38 @@
+package models
+
+// Types of search results.
+const (
+	SearchTypeOrganization = "organization"
+	SearchTypeUser         = "user"
+)
+
+// SearchTypes lists every type of search result, in the order ties are ranked.
+var SearchTypes = []string{SearchTypeOrganization, SearchTypeUser}
+
+// SearchQuery is a full-text search over the documents of one collection.
+type SearchQuery struct {
+	// Text is split into words; a document matches if any of them appears in an indexed field.
+	Text string
+	// Within restricts the matches to these organization IDs or, for users, these emails.
+	// Nothing matches when it is empty.
+	Within []string
+	// Limit caps how many of the best matches are returned.
+	Limit int
+}
+
+// SearchHit is a document matching a search, with its relevance score.
+type SearchHit struct {
+	Type         string        `json:"type"`
+	Score        float64       `json:"score"`
+	Organization *Organization `json:"organization,omitempty"`
+	User         *User         `json:"user,omitempty"`
+}
+
+// SearchRequest holds the query parameters of a search.
+type SearchRequest struct {
+	Query string `form:"q" json:"q" validate:"required,max=200"`
+	// Types restricts the results to some types; repeat the parameter or separate types with commas.
+	Types  []string `form:"type" json:"type"`
+	Limit  int      `form:"limit,default=20" json:"limit" validate:"min=1,max=100"`
+	Offset int      `form:"offset" json:"offset" validate:"min=0,max=1000"`
+}
@@ -0,0 +1,126 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// SettingsSchemaVersion is the version of OrganizationSettings written by this code. Documents of
+// an older version are upgraded when they are read.
+const SettingsSchemaVersion = 2
+
+// Roles a member can hold within an organization, besides owner.
+const (
+	MemberRoleMember = "member"
+	MemberRoleAdmin  = "admin"
+)
+
+// How people who sign up with an email in one of an organization's verified domains may join it.
+const (
+	DomainJoinOff   = "off"
+	DomainJoinOffer = "offer"
+	DomainJoinAuto  = "auto"
+)
+
+// Defaults for settings an organization has not chosen.
+const (
+	DefaultMemberRole           = MemberRoleMember
+	DefaultInvitationExpiryDays = 7
+	DefaultDomainJoin           = DomainJoinOff
+)
+
+// OrganizationSettings are the choices an organization's admins make about how it is run.
+// An organization without a stored document uses the defaults.
+type OrganizationSettings struct {
+	OrganizationId string `bson:"_id" json:"-"`
+	SchemaVersion  int    `bson:"schema_version" json:"schema_version"`
+	// AllowedDomains restricts invitations to emails in these domains; empty allows any.
+	AllowedDomains []string `bson:"allowed_domains,omitempty" json:"allowed_domains"`
+	// DefaultRole is the role newly invited members get, member or admin.
+	DefaultRole string `bson:"default_role,omitempty" json:"default_role"`
+	// InvitationExpiryDays is how long someone without an account has to sign up before their
+	// invitation lapses.
+	InvitationExpiryDays int `bson:"invitation_expiry_days,omitempty" json:"invitation_expiry_days"`
+	// RequireMFA will require members to use multi-factor authentication once it is available.
+	RequireMFA bool `bson:"require_mfa" json:"require_mfa"`
+	// DomainJoin lets people with an email in one of the organization's verified domains join it:
+	// off, offer (they may join themselves) or auto (they become members when they sign up).
+	DomainJoin string    `bson:"domain_join,omitempty" json:"domain_join"`
+	UpdatedAt  time.Time `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
+	UpdatedBy  string    `bson:"updated_by,omitempty" json:"updated_by,omitempty"`
+}
+
+// DefaultSettings returns the settings of an organization that has not changed any.
+func DefaultSettings(organizationID string) *OrganizationSettings {
+	settings := &OrganizationSettings{OrganizationId: organizationID}
+	settings.WithDefaults()
+	return settings
+}
+
+// WithDefaults upgrades settings to the current schema and fills in every setting left unset.
+func (settings *OrganizationSettings) WithDefaults() *OrganizationSettings {
+	// Upgrades run oldest first. Version 2 added DomainJoin; older documents get its default below.
+	settings.SchemaVersion = SettingsSchemaVersion
+
+	if settings.AllowedDomains == nil {
+		settings.AllowedDomains = []string{}
+	}
+	if settings.DefaultRole == "" {
+		settings.DefaultRole = DefaultMemberRole
+	}
+	if settings.InvitationExpiryDays == 0 {
+		settings.InvitationExpiryDays = DefaultInvitationExpiryDays
+	}
+	if settings.DomainJoin == "" {
+		settings.DomainJoin = DefaultDomainJoin
+	}
+	return settings
+}
+
+// AllowsEmail reports whether email may be invited under the allowed domains.
+func (settings *OrganizationSettings) AllowsEmail(email string) bool {
+	if len(settings.AllowedDomains) == 0 {
+		return true
+	}
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	domain := email[at+1:]
+	for _, allowed := range settings.AllowedDomains {
+		if strings.EqualFold(domain, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// InvitationExpiry is how long a pending invitation lasts.
+func (settings *OrganizationSettings) InvitationExpiry() time.Duration {
+	return time.Duration(settings.InvitationExpiryDays) * 24 * time.Hour
+}
+
+// SettingsPatch changes some settings of an organization; fields left out keep their value.
+type SettingsPatch struct {
+	AllowedDomains       *[]string `json:"allowed_domains" validate:"omitempty,max=50,unique,dive,fqdn"`
+	DefaultRole          *string   `json:"default_role" validate:"omitempty,oneof=member admin"`
+	InvitationExpiryDays *int      `json:"invitation_expiry_days" validate:"omitempty,min=1,max=90"`
+	RequireMFA           *bool     `json:"require_mfa"`
+	DomainJoin           *string   `json:"domain_join" validate:"omitempty,oneof=off offer auto"`
+}
+
+// Invitation is a pending invitation for someone without an account. They stay listed among the
+// organization's invited users until ExpiresAt; signing up before then makes them a member.
+type Invitation struct {
+	Email     string    `bson:"email" json:"email"`
+	ExpiresAt time.Time `bson:"expires_at" json:"expires_at"`
+}
+
+// InviteOptions controls how newly invited users join an organization.
+type InviteOptions struct {
+	// Admin makes newly invited users admins as well as members.
+	Admin bool
+	// Pending holds the invitations of emails without an account; they are recorded for the
+	// emails that were not members already.
+	Pending []Invitation
+}
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// structs for teams
+
+// Roles a member can hold within a team. Maintainers manage the team's name and members.
+const (
+	TeamRoleMaintainer = "maintainer"
+	TeamRoleMember     = "member"
+)
+
+// Permissions that can be granted to a team. The owner and admins of an organization hold all of them.
+const (
+	PermissionManageOrganization = "organization:manage"
+	PermissionInviteMembers      = "members:invite"
+	PermissionManageTeams        = "teams:manage"
+	PermissionManageWebhooks     = "webhooks:manage"
+)
+
+// Team groups members of an organization so permissions can be granted to all of them at once.
+type Team struct {
+	Id             primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	OrganizationId string             `bson:"organization_id" json:"organization_id"`
+	Name           string             `bson:"name" json:"name"`
+	Members        []TeamMember       `bson:"members,omitempty" json:"members"`
+	Permissions    []string           `bson:"permissions,omitempty" json:"permissions"`
+	CreatedAt      time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// TeamMember is an organization member's place in a team.
+type TeamMember struct {
+	Email string `bson:"email" json:"email"`
+	Role  string `bson:"role" json:"role"`
+}
+
+// HasPermission reports whether the team was granted permission.
+func (team *Team) HasPermission(permission string) bool {
+	for _, granted := range team.Permissions {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// Member returns the membership of email, if any.
+func (team *Team) Member(email string) (TeamMember, bool) {
+	for _, member := range team.Members {
+		if member.Email == email {
+			return member, true
+		}
+	}
+	return TeamMember{}, false
+}
+
+type TeamRequestBody struct {
+	Name string `json:"name" validate:"required,min=2,max=100"`
+}
+
+type TeamMemberRequestBody struct {
+	Role string `json:"role,omitempty" validate:"omitempty,oneof=maintainer member"`
+}
+
+type TeamPermissionsRequestBody struct {
+	Permissions []string `json:"permissions" validate:"max=20,dive,oneof=organization:manage members:invite teams:manage webhooks:manage"`
+}
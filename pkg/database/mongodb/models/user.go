@@ -3,8 +3,13 @@ package models
 import "go.mongodb.org/mongo-driver/bson/primitive"
 
 type User struct {
-	Id       primitive.ObjectID `json:"id,omitempty"`
-	Name     string             `json:"name,omitempty" validate:"required"`
-	Email    string             `json:"email,omitempty" validate:"required"`
-	Password string             `json:"password,omitempty" validate:"required"`
+	Id       primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Name     string             `bson:"name" json:"name,omitempty" validate:"required,max=100"`
+	Email    string             `bson:"email" json:"email,omitempty" validate:"required,email,max=254"`
+	Password string             `bson:"password" json:"password,omitempty" validate:"required,min=8,max=72"`
+	// Disabled users cannot sign in or refresh tokens.
+	Disabled bool `bson:"disabled,omitempty" json:"-"`
+	// EmailVerified is set once the user follows the confirmation sent to their email address.
+	// Only verified users may join an organization by their email domain.
+	EmailVerified bool `bson:"email_verified,omitempty" json:"-"`
 }
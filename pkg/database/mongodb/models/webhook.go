@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// structs for webhooks
+
+// Delivery states recorded on a WebhookDelivery.
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+	DeliveryDead      = "dead"
+)
+
+type Webhook struct {
+	Id             primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	OrganizationId string             `bson:"organization_id" json:"organization_id"`
+	URL            string             `bson:"url" json:"url"`
+	Secret         string             `bson:"secret" json:"secret,omitempty"`
+	Events         []string           `bson:"events,omitempty" json:"events,omitempty"`
+	Active         bool               `bson:"active" json:"active"`
+	CreatedAt      time.Time          `bson:"created_at" json:"created_at"`
+}
+
+type WebhookRequestBody struct {
+	URL    string   `json:"url" validate:"required,url,max=2048"`
+	Secret string   `json:"secret,omitempty" validate:"omitempty,min=16,max=256"`
+	Events []string `json:"events,omitempty" validate:"max=20,dive,required,max=100"`
+}
+
+type WebhookDelivery struct {
+	Id             primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	WebhookId      primitive.ObjectID `bson:"webhook_id" json:"webhook_id"`
+	OrganizationId string             `bson:"organization_id" json:"organization_id"`
+	Event          string             `bson:"event" json:"event"`
+	Payload        string             `bson:"payload" json:"payload"`
+	Status         string             `bson:"status" json:"status"`
+	Attempts       int                `bson:"attempts" json:"attempts"`
+	ResponseCode   int                `bson:"response_code,omitempty" json:"response_code,omitempty"`
+	LastError      string             `bson:"last_error,omitempty" json:"last_error,omitempty"`
+	NextAttemptAt  time.Time          `bson:"next_attempt_at,omitempty" json:"next_attempt_at,omitempty"`
+	CreatedAt      time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt      time.Time          `bson:"updated_at" json:"updated_at"`
+}
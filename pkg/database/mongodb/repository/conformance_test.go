@@ -0,0 +1,914 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/organization_api/pkg/apperror"
+	"github.com/organization_api/pkg/database/mongodb/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// stores bundles the repositories under test so every implementation runs the same suite.
+type stores struct {
+	organizations OrganizationStore
+	users         UserStore
+	webhooks      WebhookStore
+	teams         TeamStore
+	importJobs    ImportJobStore
+	settings      SettingsStore
+	domains       DomainStore
+}
+
+// runConformance exercises the behaviour every repository implementation must share.
+func runConformance(t *testing.T, newStores func(t *testing.T) stores) {
+	t.Run("OrganizationCRUD", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStores(t)
+
+		id, err := s.organizations.CreateOrganization(ctx, &models.Organization{Name: "Acme", Description: "Widgets"})
+		if err != nil {
+			t.Fatalf("CreateOrganization: %v", err)
+		}
+
+		org, err := s.organizations.GetOrganizationById(ctx, id)
+		if err != nil {
+			t.Fatalf("GetOrganizationById: %v", err)
+		}
+		if org.Id.Hex() != id || org.Name != "Acme" || org.Description != "Widgets" {
+			t.Fatalf("unexpected organization: %+v", org)
+		}
+
+		updated, err := s.organizations.UpdateOrganization(ctx, id, &models.OrganizationUpdate{Name: "Acme Corp", Description: "Gadgets"})
+		if err != nil {
+			t.Fatalf("UpdateOrganization: %v", err)
+		}
+		if updated.Name != "Acme Corp" || updated.Description != "Gadgets" {
+			t.Fatalf("update not applied: %+v", updated)
+		}
+
+		if err := s.organizations.DeleteOrganization(ctx, id); err != nil {
+			t.Fatalf("DeleteOrganization: %v", err)
+		}
+		if _, err := s.organizations.GetOrganizationById(ctx, id); !errors.Is(err, apperror.ErrNotFound) {
+			t.Fatalf("expected not found fetching deleted organization, got %v", err)
+		}
+		if err := s.organizations.DeleteOrganization(ctx, id); !errors.Is(err, apperror.ErrNotFound) {
+			t.Fatalf("expected not found deleting a missing organization, got %v", err)
+		}
+	})
+
+	t.Run("OrganizationList", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStores(t)
+
+		for _, name := range []string{"One", "Two", "Three"} {
+			if _, err := s.organizations.CreateOrganization(ctx, &models.Organization{Name: name, Description: name}); err != nil {
+				t.Fatalf("CreateOrganization: %v", err)
+			}
+		}
+
+		orgs, err := s.organizations.GetAllOrganizations(ctx)
+		if err != nil {
+			t.Fatalf("GetAllOrganizations: %v", err)
+		}
+		if len(orgs) != 3 {
+			t.Fatalf("expected 3 organizations, got %d", len(orgs))
+		}
+	})
+
+	t.Run("OrganizationInvalidAndMissingIDs", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStores(t)
+		missing := primitive.NewObjectID().Hex()
+
+		if _, err := s.organizations.GetOrganizationById(ctx, "not-an-id"); !errors.Is(err, apperror.ErrInvalidID) {
+			t.Fatalf("expected invalid id error, got %v", err)
+		}
+		if err := s.organizations.DeleteOrganization(ctx, "not-an-id"); !errors.Is(err, apperror.ErrInvalidID) {
+			t.Fatalf("expected invalid id error, got %v", err)
+		}
+		if _, err := s.organizations.GetOrganizationById(ctx, missing); !errors.Is(err, apperror.ErrNotFound) {
+			t.Fatalf("expected not found for missing organization, got %v", err)
+		}
+		if _, err := s.organizations.UpdateOrganization(ctx, missing, &models.OrganizationUpdate{Name: "x", Description: "y"}); !errors.Is(err, apperror.ErrNotFound) {
+			t.Fatalf("expected not found updating missing organization, got %v", err)
+		}
+		if err := s.organizations.InviteUserToOrganization(ctx, missing, "a@example.com"); !errors.Is(err, apperror.ErrNotFound) {
+			t.Fatalf("expected not found inviting to missing organization, got %v", err)
+		}
+	})
+
+	t.Run("InviteIsIdempotent", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStores(t)
+
+		id, err := s.organizations.CreateOrganization(ctx, &models.Organization{Name: "Acme", Description: "Widgets"})
+		if err != nil {
+			t.Fatalf("CreateOrganization: %v", err)
+		}
+		for i := 0; i < 2; i++ {
+			if err := s.organizations.InviteUserToOrganization(ctx, id, "a@example.com"); err != nil {
+				t.Fatalf("InviteUserToOrganization: %v", err)
+			}
+		}
+		if err := s.organizations.InviteUserToOrganization(ctx, id, "b@example.com"); err != nil {
+			t.Fatalf("InviteUserToOrganization: %v", err)
+		}
+
+		org, err := s.organizations.GetOrganizationById(ctx, id)
+		if err != nil {
+			t.Fatalf("GetOrganizationById: %v", err)
+		}
+		if len(org.InvitedUsers) != 2 {
+			t.Fatalf("expected 2 invited users, got %v", org.InvitedUsers)
+		}
+	})
+
+	t.Run("BatchInvite", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStores(t)
+
+		id, err := s.organizations.CreateOrganization(ctx, &models.Organization{Name: "Acme", Description: "Widgets", InvitedUsers: []string{"a@example.com"}})
+		if err != nil {
+			t.Fatalf("CreateOrganization: %v", err)
+		}
+
+		invited, err := s.organizations.InviteUsersToOrganization(ctx, id, []string{"c@example.com", "a@example.com", "b@example.com", "c@example.com"}, models.InviteOptions{})
+		if err != nil {
+			t.Fatalf("InviteUsersToOrganization: %v", err)
+		}
+		if len(invited) != 2 || invited[0] != "c@example.com" || invited[1] != "b@example.com" {
+			t.Fatalf("expected c and b to be newly invited, got %v", invited)
+		}
+
+		org, err := s.organizations.GetOrganizationById(ctx, id)
+		if err != nil {
+			t.Fatalf("GetOrganizationById: %v", err)
+		}
+		if len(org.InvitedUsers) != 3 {
+			t.Fatalf("expected 3 invited users, got %v", org.InvitedUsers)
+		}
+
+		invited, err = s.organizations.InviteUsersToOrganization(ctx, id, []string{"b@example.com"}, models.InviteOptions{})
+		if err != nil || len(invited) != 0 {
+			t.Fatalf("expected nobody new, got %v, %v", invited, err)
+		}
+		_, err = s.organizations.InviteUsersToOrganization(ctx, primitive.NewObjectID().Hex(), []string{"a@example.com"}, models.InviteOptions{})
+		if !errors.Is(err, apperror.ErrNotFound) {
+			t.Fatalf("expected not found for a missing organization, got %v", err)
+		}
+	})
+
+	t.Run("PendingInvitations", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStores(t)
+
+		id, err := s.organizations.CreateOrganization(ctx, &models.Organization{Name: "Acme", Description: "Widgets", Owner: "ada@example.com", InvitedUsers: []string{"ada@example.com"}})
+		if err != nil {
+			t.Fatalf("CreateOrganization: %v", err)
+		}
+		open := time.Now().Add(time.Hour).UTC().Truncate(time.Millisecond)
+		lapsed := time.Now().Add(-time.Hour).UTC().Truncate(time.Millisecond)
+
+		// Admin rights and pending invitations go only to the emails that were not members yet.
+		invited, err := s.organizations.InviteUsersToOrganization(ctx, id, []string{"ada@example.com", "bob@example.com", "cy@example.com"}, models.InviteOptions{
+			Admin: true,
+			Pending: []models.Invitation{
+				{Email: "ada@example.com", ExpiresAt: open},
+				{Email: "bob@example.com", ExpiresAt: open},
+				{Email: "cy@example.com", ExpiresAt: lapsed},
+			},
+		})
+		if err != nil || len(invited) != 2 {
+			t.Fatalf("InviteUsersToOrganization: expected bob and cy, got %v, %v", invited, err)
+		}
+		org, err := s.organizations.GetOrganizationById(ctx, id)
+		if err != nil {
+			t.Fatalf("GetOrganizationById: %v", err)
+		}
+		if len(org.Admins) != 2 || !org.IsAdmin("bob@example.com") || len(org.Invitations) != 2 || org.Invitations[0].Email != "bob@example.com" {
+			t.Fatalf("expected bob and cy as pending admins, got admins %v and invitations %v", org.Admins, org.Invitations)
+		}
+
+		// Bob signs up in time and stays; cy is too late and loses the membership.
+		for _, email := range []string{"bob@example.com", "cy@example.com"} {
+			if err := s.organizations.AcceptInvitations(ctx, email); err != nil {
+				t.Fatalf("AcceptInvitations(%s): %v", email, err)
+			}
+		}
+		org, err = s.organizations.GetOrganizationById(ctx, id)
+		if err != nil {
+			t.Fatalf("GetOrganizationById: %v", err)
+		}
+		if len(org.Invitations) != 0 || len(org.InvitedUsers) != 2 || len(org.Admins) != 1 || org.Admins[0] != "bob@example.com" {
+			t.Fatalf("expected only bob to remain, got members %v, admins %v, invitations %v", org.InvitedUsers, org.Admins, org.Invitations)
+		}
+	})
+
+	t.Run("Settings", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStores(t)
+		id := primitive.NewObjectID().Hex()
+
+		settings, err := s.settings.GetSettings(ctx, id)
+		if err != nil {
+			t.Fatalf("GetSettings: %v", err)
+		}
+		if settings.SchemaVersion != models.SettingsSchemaVersion || settings.DefaultRole != models.MemberRoleMember ||
+			settings.InvitationExpiryDays != models.DefaultInvitationExpiryDays || len(settings.AllowedDomains) != 0 {
+			t.Fatalf("expected the defaults, got %+v", settings)
+		}
+
+		domains := []string{"example.com"}
+		settings, err = s.settings.UpdateSettings(ctx, id, models.SettingsPatch{AllowedDomains: &domains}, "ada@example.com")
+		if err != nil {
+			t.Fatalf("UpdateSettings: %v", err)
+		}
+		role := models.MemberRoleAdmin
+		settings, err = s.settings.UpdateSettings(ctx, id, models.SettingsPatch{DefaultRole: &role}, "bob@example.com")
+		if err != nil {
+			t.Fatalf("UpdateSettings: %v", err)
+		}
+		if len(settings.AllowedDomains) != 1 || settings.DefaultRole != models.MemberRoleAdmin ||
+			settings.InvitationExpiryDays != models.DefaultInvitationExpiryDays || settings.UpdatedBy != "bob@example.com" {
+			t.Fatalf("expected both patches with defaults for the rest, got %+v", settings)
+		}
+
+		got, err := s.settings.GetSettings(ctx, id)
+		if err != nil || got.DefaultRole != models.MemberRoleAdmin || len(got.AllowedDomains) != 1 {
+			t.Fatalf("GetSettings: expected the stored settings, got %+v, %v", got, err)
+		}
+
+		if err := s.settings.DeleteSettings(ctx, id); err != nil {
+			t.Fatalf("DeleteSettings: %v", err)
+		}
+		got, err = s.settings.GetSettings(ctx, id)
+		if err != nil || got.DefaultRole != models.MemberRoleMember {
+			t.Fatalf("expected the defaults after deletion, got %+v, %v", got, err)
+		}
+		if _, err := s.settings.GetSettings(ctx, "bad"); !errors.Is(err, apperror.ErrInvalidID) {
+			t.Fatalf("expected an invalid id, got %v", err)
+		}
+	})
+
+	t.Run("DomainClaims", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStores(t)
+		acme, globex := primitive.NewObjectID().Hex(), primitive.NewObjectID().Hex()
+
+		for _, org := range []string{acme, globex} {
+			claim := &models.DomainClaim{OrganizationId: org, Domain: "example.com", Token: "t", Status: models.DomainPending}
+			if _, err := s.domains.CreateDomainClaim(ctx, claim); err != nil {
+				t.Fatalf("CreateDomainClaim: %v", err)
+			}
+		}
+		again := &models.DomainClaim{OrganizationId: acme, Domain: "example.com", Status: models.DomainPending}
+		if _, err := s.domains.CreateDomainClaim(ctx, again); !errors.Is(err, apperror.ErrConflict) {
+			t.Fatalf("expected a conflict claiming a domain twice, got %v", err)
+		}
+
+		if _, err := s.domains.FindVerifiedDomainClaim(ctx, "example.com"); !errors.Is(err, apperror.ErrNotFound) {
+			t.Fatalf("expected no verified claim yet, got %v", err)
+		}
+		verified, err := s.domains.VerifyDomainClaim(ctx, acme, "example.com", time.Now())
+		if err != nil || verified.Status != models.DomainVerified || verified.VerifiedAt == nil {
+			t.Fatalf("VerifyDomainClaim: got %+v, %v", verified, err)
+		}
+		if _, err := s.domains.VerifyDomainClaim(ctx, globex, "example.com", time.Now()); !errors.Is(err, apperror.ErrConflict) {
+			t.Fatalf("expected a conflict verifying a domain verified elsewhere, got %v", err)
+		}
+		found, err := s.domains.FindVerifiedDomainClaim(ctx, "example.com")
+		if err != nil || found.OrganizationId != acme {
+			t.Fatalf("FindVerifiedDomainClaim: got %+v, %v", found, err)
+		}
+
+		claims, err := s.domains.GetDomainClaims(ctx, globex)
+		if err != nil || len(claims) != 1 || claims[0].Status != models.DomainPending {
+			t.Fatalf("GetDomainClaims: got %+v, %v", claims, err)
+		}
+		if err := s.domains.DeleteDomainClaim(ctx, globex, "example.com"); err != nil {
+			t.Fatalf("DeleteDomainClaim: %v", err)
+		}
+		if err := s.domains.DeleteDomainClaim(ctx, globex, "example.com"); !errors.Is(err, apperror.ErrNotFound) {
+			t.Fatalf("expected not found deleting a missing claim, got %v", err)
+		}
+
+		if err := s.domains.DeleteDomainClaims(ctx, acme); err != nil {
+			t.Fatalf("DeleteDomainClaims: %v", err)
+		}
+		if _, err := s.domains.GetDomainClaim(ctx, acme, "example.com"); !errors.Is(err, apperror.ErrNotFound) {
+			t.Fatalf("expected the claims of acme to be gone, got %v", err)
+		}
+	})
+
+	t.Run("MetadataAndTags", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStores(t)
+
+		acme, err := s.organizations.CreateOrganization(ctx, &models.Organization{Name: "Acme", Description: "Widgets",
+			Metadata: map[string]string{"region": "eu"}, Tags: []string{"enterprise"}})
+		if err != nil {
+			t.Fatalf("CreateOrganization: %v", err)
+		}
+		globex, err := s.organizations.CreateOrganization(ctx, &models.Organization{Name: "Globex", Description: "Gadgets"})
+		if err != nil {
+			t.Fatalf("CreateOrganization: %v", err)
+		}
+
+		updated, err := s.organizations.SetMetadata(ctx, globex, map[string]string{"region": "eu", "billing_id": "B-1"})
+		if err != nil || updated.Metadata["billing_id"] != "B-1" || updated.Name != "Globex" {
+			t.Fatalf("SetMetadata: expected the new metadata and the rest untouched, got %+v, %v", updated, err)
+		}
+		updated, err = s.organizations.SetTags(ctx, globex, []string{"enterprise", "beta"})
+		if err != nil || len(updated.Tags) != 2 || updated.Metadata["region"] != "eu" {
+			t.Fatalf("SetTags: expected the new tags and the metadata untouched, got %+v, %v", updated, err)
+		}
+
+		acmeID, _ := primitive.ObjectIDFromHex(acme)
+		cases := []struct {
+			filter models.OrganizationFilter
+			want   int
+		}{
+			{models.OrganizationFilter{}, 2},
+			{models.OrganizationFilter{Tags: []string{"enterprise"}}, 2},
+			{models.OrganizationFilter{Tags: []string{"enterprise", "beta"}}, 1},
+			{models.OrganizationFilter{Metadata: map[string]string{"region": "eu"}}, 2},
+			{models.OrganizationFilter{Tags: []string{"enterprise"}, Metadata: map[string]string{"billing_id": "B-1"}}, 1},
+			{models.OrganizationFilter{Metadata: map[string]string{"region": "us"}}, 0},
+			{models.OrganizationFilter{Ids: []primitive.ObjectID{acmeID}, Tags: []string{"enterprise"}}, 1},
+			{models.OrganizationFilter{Ids: []primitive.ObjectID{}}, 0},
+		}
+		for _, tc := range cases {
+			orgs, err := s.organizations.FindOrganizations(ctx, tc.filter)
+			if err != nil || len(orgs) != tc.want {
+				t.Errorf("FindOrganizations(%+v): expected %d, got %d, %v", tc.filter, tc.want, len(orgs), err)
+			}
+		}
+
+		// Empty values remove the metadata and tags.
+		if _, err := s.organizations.SetMetadata(ctx, acme, map[string]string{}); err != nil {
+			t.Fatalf("SetMetadata: %v", err)
+		}
+		if _, err := s.organizations.SetTags(ctx, acme, nil); err != nil {
+			t.Fatalf("SetTags: %v", err)
+		}
+		org, err := s.organizations.GetOrganizationById(ctx, acme)
+		if err != nil || len(org.Metadata) != 0 || len(org.Tags) != 0 {
+			t.Fatalf("expected metadata and tags to be removed, got %+v, %v", org, err)
+		}
+		if _, err := s.organizations.SetTags(ctx, primitive.NewObjectID().Hex(), []string{"beta"}); !errors.Is(err, apperror.ErrNotFound) {
+			t.Fatalf("expected not found for a missing organization, got %v", err)
+		}
+	})
+
+	t.Run("RegisteredEmails", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStores(t)
+
+		if _, err := s.users.CreateUser(ctx, &models.User{Name: "Ada", Email: "ada@example.com", Password: "hash"}); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		registered, err := s.users.FindRegisteredEmails(ctx, []string{"bob@example.com", "ada@example.com"})
+		if err != nil || len(registered) != 1 || registered[0] != "ada@example.com" {
+			t.Fatalf("FindRegisteredEmails: expected ada, got %v, %v", registered, err)
+		}
+	})
+
+	t.Run("Users", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStores(t)
+
+		created, err := s.users.CreateUser(ctx, &models.User{Name: "Ada", Email: "ada@example.com", Password: "hash"})
+		if err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		if created.Id.IsZero() || created.Email != "ada@example.com" {
+			t.Fatalf("unexpected user: %+v", created)
+		}
+
+		if _, err := s.users.CreateUser(ctx, &models.User{Name: "Ada", Email: "ada@example.com", Password: "hash"}); !errors.Is(err, apperror.ErrConflict) {
+			t.Fatalf("expected duplicate email to conflict, got %v", err)
+		}
+
+		found, err := s.users.FindUserByEmail(ctx, "ada@example.com")
+		if err != nil || found == nil {
+			t.Fatalf("FindUserByEmail: %v, %v", found, err)
+		}
+		if found.Id != created.Id || found.Password != "hash" {
+			t.Fatalf("unexpected user: %+v", found)
+		}
+
+		if _, err := s.users.FindUserByEmail(ctx, "nobody@example.com"); !errors.Is(err, apperror.ErrNotFound) {
+			t.Fatalf("expected not found for unknown email, got %v", err)
+		}
+	})
+
+	t.Run("UserDisableAndPassword", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStores(t)
+		s.users.CreateUser(ctx, &models.User{Name: "Ada", Email: "ada@example.com", Password: "hash"})
+
+		if err := s.users.SetUserDisabled(ctx, "ada@example.com", true); err != nil {
+			t.Fatalf("SetUserDisabled: %v", err)
+		}
+		if err := s.users.UpdatePassword(ctx, "ada@example.com", "new-hash"); err != nil {
+			t.Fatalf("UpdatePassword: %v", err)
+		}
+		found, _ := s.users.FindUserByEmail(ctx, "ada@example.com")
+		if !found.Disabled || found.Password != "new-hash" {
+			t.Fatalf("updates not persisted: %+v", found)
+		}
+
+		if err := s.users.SetUserDisabled(ctx, "ada@example.com", false); err != nil {
+			t.Fatalf("SetUserDisabled(false): %v", err)
+		}
+		if found, _ := s.users.FindUserByEmail(ctx, "ada@example.com"); found.Disabled {
+			t.Fatal("user is still disabled")
+		}
+
+		if err := s.users.SetUserDisabled(ctx, "nobody@example.com", true); !errors.Is(err, apperror.ErrNotFound) {
+			t.Fatalf("expected not found for unknown email, got %v", err)
+		}
+		if err := s.users.UpdatePassword(ctx, "nobody@example.com", "x"); !errors.Is(err, apperror.ErrNotFound) {
+			t.Fatalf("expected not found for unknown email, got %v", err)
+		}
+	})
+
+	t.Run("UserEmailVerified", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStores(t)
+		s.users.CreateUser(ctx, &models.User{Name: "Ada", Email: "ada@example.com", Password: "hash"})
+
+		if found, _ := s.users.FindUserByEmail(ctx, "ada@example.com"); found.EmailVerified {
+			t.Fatal("new users must start unverified")
+		}
+		if err := s.users.SetEmailVerified(ctx, "ada@example.com"); err != nil {
+			t.Fatalf("SetEmailVerified: %v", err)
+		}
+		if found, _ := s.users.FindUserByEmail(ctx, "ada@example.com"); !found.EmailVerified {
+			t.Fatal("verification not persisted")
+		}
+		if err := s.users.SetEmailVerified(ctx, "nobody@example.com"); !errors.Is(err, apperror.ErrNotFound) {
+			t.Fatalf("expected not found for unknown email, got %v", err)
+		}
+	})
+
+	t.Run("OrganizationSlugs", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStores(t)
+
+		first := &models.Organization{Name: "Acme Corp", Description: "Widgets"}
+		firstID, err := s.organizations.CreateOrganization(ctx, first)
+		if err != nil || first.Slug != "acme-corp" {
+			t.Fatalf("CreateOrganization: slug %q, %v", first.Slug, err)
+		}
+		second := &models.Organization{Name: "ACME corp.", Description: "Gadgets"}
+		s.organizations.CreateOrganization(ctx, second)
+		if second.Slug != "acme-corp-2" {
+			t.Fatalf("expected a suffixed slug on collision, got %q", second.Slug)
+		}
+		reserved := &models.Organization{Name: "Admin", Description: "Reserved"}
+		s.organizations.CreateOrganization(ctx, reserved)
+		if reserved.Slug != "admin-1" {
+			t.Fatalf("expected reserved words to be suffixed, got %q", reserved.Slug)
+		}
+
+		// A rename that keeps the same base keeps the slug.
+		updated, err := s.organizations.UpdateOrganization(ctx, firstID, &models.OrganizationUpdate{Name: "Acme  CORP", Description: "Widgets"})
+		if err != nil || updated.Slug != "acme-corp" {
+			t.Fatalf("UpdateOrganization with the same base: %+v, %v", updated, err)
+		}
+		updated, err = s.organizations.UpdateOrganization(ctx, firstID, &models.OrganizationUpdate{Name: "Acme Industries", Description: "Widgets"})
+		if err != nil || updated.Slug != "acme-industries" {
+			t.Fatalf("UpdateOrganization with a new name: %+v, %v", updated, err)
+		}
+
+		// The old slug still finds the organization, and no one else can take it.
+		org, err := s.organizations.GetOrganizationBySlug(ctx, "acme-corp")
+		if err != nil || org.Id.Hex() != firstID || org.Slug != "acme-industries" {
+			t.Fatalf("GetOrganizationBySlug with a previous slug: %+v, %v", org, err)
+		}
+		third := &models.Organization{Name: "Acme Corp", Description: "Again"}
+		s.organizations.CreateOrganization(ctx, third)
+		if third.Slug != "acme-corp-3" {
+			t.Fatalf("expected previous slugs to stay reserved, got %q", third.Slug)
+		}
+
+		// Renaming back reclaims the organization's own previous slug.
+		updated, _ = s.organizations.UpdateOrganization(ctx, firstID, &models.OrganizationUpdate{Name: "Acme Corp", Description: "Widgets"})
+		if updated.Slug != "acme-corp" {
+			t.Fatalf("expected the previous slug to be reclaimed, got %q", updated.Slug)
+		}
+
+		// A slug that only looks derived from the new name is replaced.
+		datedID, _ := s.organizations.CreateOrganization(ctx, &models.Organization{Name: "Rocket 2024", Description: "Dated"})
+		updated, err = s.organizations.UpdateOrganization(ctx, datedID, &models.OrganizationUpdate{Name: "Rocket", Description: "Dated"})
+		if err != nil || updated.Slug != "rocket" {
+			t.Fatalf("UpdateOrganization dropping a number from the name: %+v, %v", updated, err)
+		}
+
+		if _, err := s.organizations.GetOrganizationBySlug(ctx, "nobody"); !errors.Is(err, apperror.ErrNotFound) {
+			t.Fatalf("expected not found for an unknown slug, got %v", err)
+		}
+	})
+
+	t.Run("OrganizationHierarchy", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStores(t)
+		parentID, _ := s.organizations.CreateOrganization(ctx, &models.Organization{Name: "Parent", Description: "Parent"})
+		childID, _ := s.organizations.CreateOrganization(ctx, &models.Organization{Name: "Child", Description: "Child", ParentId: parentID})
+		otherID, _ := s.organizations.CreateOrganization(ctx, &models.Organization{Name: "Other", Description: "Other"})
+
+		if err := s.organizations.SetParent(ctx, otherID, parentID); err != nil {
+			t.Fatalf("SetParent: %v", err)
+		}
+		children, err := s.organizations.GetChildOrganizations(ctx, parentID)
+		if err != nil || len(children) != 2 {
+			t.Fatalf("expected two children, got %+v, %v", children, err)
+		}
+
+		if err := s.organizations.SetParent(ctx, childID, ""); err != nil {
+			t.Fatalf("SetParent to the top level: %v", err)
+		}
+		child, _ := s.organizations.GetOrganizationById(ctx, childID)
+		if child.ParentId != "" {
+			t.Fatalf("expected a top-level organization, got parent %q", child.ParentId)
+		}
+		children, _ = s.organizations.GetChildOrganizations(ctx, parentID)
+		if len(children) != 1 || children[0].Id.Hex() != otherID {
+			t.Fatalf("expected only the other child, got %+v", children)
+		}
+
+		if err := s.organizations.SetParent(ctx, primitive.NewObjectID().Hex(), parentID); !errors.Is(err, apperror.ErrNotFound) {
+			t.Fatalf("expected not found for a missing organization, got %v", err)
+		}
+	})
+
+	t.Run("Search", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStores(t)
+		namedID, _ := s.organizations.CreateOrganization(ctx, &models.Organization{Name: "Acme Rockets", Description: "Launch services", InvitedUsers: []string{"ada@example.com"}})
+		describedID, _ := s.organizations.CreateOrganization(ctx, &models.Organization{Name: "Orbital", Description: "Payloads built for Acme", InvitedUsers: []string{"ada@example.com"}})
+		hiddenID, _ := s.organizations.CreateOrganization(ctx, &models.Organization{Name: "Acme Hidden", Description: "Not visible"})
+		s.organizations.CreateOrganization(ctx, &models.Organization{Name: "Unrelated", Description: "Nothing to see"})
+
+		memberOf, err := s.organizations.FindOrganizationsByMember(ctx, "ada@example.com")
+		if err != nil || len(memberOf) != 2 {
+			t.Fatalf("FindOrganizationsByMember: expected two organizations, got %+v, %v", memberOf, err)
+		}
+
+		// A match in the name outranks one in the description, and organizations outside Within never match.
+		query := models.SearchQuery{Text: "ACME", Within: []string{namedID, describedID}, Limit: 10}
+		hits, total, err := s.organizations.SearchOrganizations(ctx, query)
+		if err != nil || total != 2 || len(hits) != 2 {
+			t.Fatalf("SearchOrganizations: expected two hits, got %+v, %d, %v", hits, total, err)
+		}
+		if hits[0].Organization.Id.Hex() != namedID || hits[1].Organization.Id.Hex() != describedID || hits[0].Score <= hits[1].Score {
+			t.Fatalf("expected the name match first, got %s (%f) then %s (%f)",
+				hits[0].Organization.Name, hits[0].Score, hits[1].Organization.Name, hits[1].Score)
+		}
+		if hits[0].Type != models.SearchTypeOrganization {
+			t.Fatalf("expected an organization hit, got %q", hits[0].Type)
+		}
+
+		query.Limit = 1
+		hits, total, _ = s.organizations.SearchOrganizations(ctx, query)
+		if total != 2 || len(hits) != 1 || hits[0].Organization.Id.Hex() != namedID {
+			t.Fatalf("expected the best of two matches, got %+v, %d", hits, total)
+		}
+		hits, total, _ = s.organizations.SearchOrganizations(ctx, models.SearchQuery{Text: "acme", Within: []string{hiddenID}, Limit: 10})
+		if total != 1 || len(hits) != 1 {
+			t.Fatalf("expected the organization within scope to match, got %+v, %d", hits, total)
+		}
+		hits, total, _ = s.organizations.SearchOrganizations(ctx, models.SearchQuery{Text: "acme", Limit: 10})
+		if total != 0 || len(hits) != 0 {
+			t.Fatalf("expected no matches without a scope, got %+v, %d", hits, total)
+		}
+
+		s.users.CreateUser(ctx, &models.User{Name: "Ada Lovelace", Email: "ada@example.com", Password: "hash"})
+		s.users.CreateUser(ctx, &models.User{Name: "Grace Hopper", Email: "grace@example.com", Password: "hash"})
+		s.users.CreateUser(ctx, &models.User{Name: "Ada Byron", Email: "byron@example.com", Password: "hash"})
+		hits, total, err = s.users.SearchUsers(ctx, models.SearchQuery{Text: "ada", Within: []string{"ada@example.com", "grace@example.com"}, Limit: 10})
+		if err != nil || total != 1 || len(hits) != 1 || hits[0].User.Email != "ada@example.com" || hits[0].Type != models.SearchTypeUser {
+			t.Fatalf("SearchUsers: expected Ada only, got %+v, %d, %v", hits, total, err)
+		}
+		hits, _, _ = s.users.SearchUsers(ctx, models.SearchQuery{Text: "grace", Within: []string{"ada@example.com", "grace@example.com"}, Limit: 10})
+		if len(hits) != 1 || hits[0].User.Name != "Grace Hopper" {
+			t.Fatalf("SearchUsers by email: expected Grace, got %+v", hits)
+		}
+	})
+
+	t.Run("TransferOwnership", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStores(t)
+		id, _ := s.organizations.CreateOrganization(ctx, &models.Organization{Name: "Acme", Description: "Widgets", Owner: "ada@example.com", InvitedUsers: []string{"ada@example.com"}, Admins: []string{"cat@example.com"}})
+
+		if err := s.organizations.TransferOwnership(ctx, id, "ada@example.com", "bob@example.com"); !errors.Is(err, apperror.ErrConflict) {
+			t.Fatalf("expected a conflict for a non-member, got %v", err)
+		}
+
+		s.organizations.InviteUserToOrganization(ctx, id, "bob@example.com")
+		if err := s.organizations.TransferOwnership(ctx, id, "cat@example.com", "bob@example.com"); !errors.Is(err, apperror.ErrConflict) {
+			t.Fatalf("expected a conflict for a stale owner, got %v", err)
+		}
+		if err := s.organizations.TransferOwnership(ctx, id, "ada@example.com", "bob@example.com"); err != nil {
+			t.Fatalf("TransferOwnership: %v", err)
+		}
+		org, _ := s.organizations.GetOrganizationById(ctx, id)
+		if org.Owner != "bob@example.com" || !org.IsAdmin("ada@example.com") || !org.IsAdmin("cat@example.com") {
+			t.Fatalf("owner not transferred or previous owner not demoted to admin: %+v", org)
+		}
+		if n := len(org.OwnershipTransfers); n != 1 || org.OwnershipTransfers[0].From != "ada@example.com" || org.OwnershipTransfers[0].To != "bob@example.com" {
+			t.Fatalf("expected the transfer to be recorded, got %+v", org.OwnershipTransfers)
+		}
+
+		// Handing it back removes the new owner from the admins again.
+		if err := s.organizations.TransferOwnership(ctx, id, "bob@example.com", "ada@example.com"); err != nil {
+			t.Fatalf("TransferOwnership back: %v", err)
+		}
+		org, _ = s.organizations.GetOrganizationById(ctx, id)
+		if org.Owner != "ada@example.com" || len(org.Admins) != 2 || len(org.OwnershipTransfers) != 2 {
+			t.Fatalf("unexpected organization after transferring back: %+v", org)
+		}
+
+		if err := s.organizations.TransferOwnership(ctx, id, "ada@example.com", "ada@example.com"); !errors.Is(err, apperror.ErrConflict) {
+			t.Fatalf("expected a conflict when transferring to the owner, got %v", err)
+		}
+		if err := s.organizations.TransferOwnership(ctx, primitive.NewObjectID().Hex(), "ada@example.com", "bob@example.com"); !errors.Is(err, apperror.ErrNotFound) {
+			t.Fatalf("expected not found for a missing organization, got %v", err)
+		}
+	})
+
+	t.Run("RemoveMember", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStores(t)
+
+		id, _ := s.organizations.CreateOrganization(ctx, &models.Organization{
+			Name: "Acme", Description: "Widgets", Owner: "ada@example.com", InvitedUsers: []string{"ada@example.com"},
+		})
+		expiresAt := time.Now().Add(time.Hour).UTC()
+		opts := models.InviteOptions{Admin: true, Pending: []models.Invitation{{Email: "bob@example.com", ExpiresAt: expiresAt}}}
+		if _, err := s.organizations.InviteUsersToOrganization(ctx, id, []string{"bob@example.com", "cy@example.com"}, opts); err != nil {
+			t.Fatalf("InviteUsersToOrganization: %v", err)
+		}
+
+		if err := s.organizations.RemoveMember(ctx, id, "bob@example.com"); err != nil {
+			t.Fatalf("RemoveMember: %v", err)
+		}
+		org, _ := s.organizations.GetOrganizationById(ctx, id)
+		if len(org.InvitedUsers) != 2 || org.IsAdmin("bob@example.com") || len(org.Invitations) != 0 {
+			t.Fatalf("expected bob to be gone everywhere, got members %v, admins %v, invitations %v", org.InvitedUsers, org.Admins, org.Invitations)
+		}
+
+		if err := s.organizations.RemoveMember(ctx, id, "bob@example.com"); !errors.Is(err, apperror.ErrNotFound) {
+			t.Fatalf("expected not found removing a non-member, got %v", err)
+		}
+		if err := s.organizations.RemoveMember(ctx, id, "ada@example.com"); !errors.Is(err, apperror.ErrConflict) {
+			t.Fatalf("expected a conflict removing the owner, got %v", err)
+		}
+		if err := s.organizations.RemoveMember(ctx, primitive.NewObjectID().Hex(), "cy@example.com"); !errors.Is(err, apperror.ErrNotFound) {
+			t.Fatalf("expected not found for a missing organization, got %v", err)
+		}
+
+		// Team memberships go too, in that organization only.
+		orgID, otherOrgID := primitive.NewObjectID().Hex(), primitive.NewObjectID().Hex()
+		for _, org := range []string{orgID, orgID, otherOrgID} {
+			teamID, _ := s.teams.CreateTeam(ctx, &models.Team{OrganizationId: org, Name: primitive.NewObjectID().Hex()})
+			s.teams.SetTeamMember(ctx, org, teamID, models.TeamMember{Email: "cy@example.com", Role: models.TeamRoleMember})
+		}
+		if err := s.teams.RemoveMemberFromTeams(ctx, orgID, "cy@example.com"); err != nil {
+			t.Fatalf("RemoveMemberFromTeams: %v", err)
+		}
+		if teams, _ := s.teams.FindTeamsByMember(ctx, orgID, "cy@example.com"); len(teams) != 0 {
+			t.Fatalf("expected cy to be on no team of the organization, got %d", len(teams))
+		}
+		if teams, _ := s.teams.FindTeamsByMember(ctx, otherOrgID, "cy@example.com"); len(teams) != 1 {
+			t.Fatalf("expected cy to stay on the other organization's team, got %d", len(teams))
+		}
+	})
+
+	t.Run("Teams", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStores(t)
+		orgID, otherOrgID := primitive.NewObjectID().Hex(), primitive.NewObjectID().Hex()
+
+		id, err := s.teams.CreateTeam(ctx, &models.Team{OrganizationId: orgID, Name: "Platform", CreatedAt: time.Now().UTC()})
+		if err != nil {
+			t.Fatalf("CreateTeam: %v", err)
+		}
+		if _, err := s.teams.CreateTeam(ctx, &models.Team{OrganizationId: orgID, Name: "Platform"}); !errors.Is(err, apperror.ErrConflict) {
+			t.Fatalf("expected a conflict for a duplicate name, got %v", err)
+		}
+		if _, err := s.teams.CreateTeam(ctx, &models.Team{OrganizationId: otherOrgID, Name: "Platform"}); err != nil {
+			t.Fatalf("the same name in another organization: %v", err)
+		}
+		if _, err := s.teams.GetTeamById(ctx, otherOrgID, id); !errors.Is(err, apperror.ErrNotFound) {
+			t.Fatalf("expected teams to be scoped to their organization, got %v", err)
+		}
+
+		s.teams.CreateTeam(ctx, &models.Team{OrganizationId: orgID, Name: "Billing"})
+		if _, err := s.teams.RenameTeam(ctx, orgID, id, "Billing"); !errors.Is(err, apperror.ErrConflict) {
+			t.Fatalf("expected a conflict renaming onto an existing name, got %v", err)
+		}
+		team, err := s.teams.RenameTeam(ctx, orgID, id, "Infrastructure")
+		if err != nil || team.Name != "Infrastructure" {
+			t.Fatalf("RenameTeam: %+v, %v", team, err)
+		}
+
+		team, err = s.teams.SetTeamPermissions(ctx, orgID, id, []string{models.PermissionManageWebhooks})
+		if err != nil || !team.HasPermission(models.PermissionManageWebhooks) {
+			t.Fatalf("SetTeamPermissions: %+v, %v", team, err)
+		}
+
+		s.teams.SetTeamMember(ctx, orgID, id, models.TeamMember{Email: "ada@example.com", Role: models.TeamRoleMember})
+		team, err = s.teams.SetTeamMember(ctx, orgID, id, models.TeamMember{Email: "ada@example.com", Role: models.TeamRoleMaintainer})
+		if err != nil || len(team.Members) != 1 || team.Members[0].Role != models.TeamRoleMaintainer {
+			t.Fatalf("expected the role to be replaced, got %+v, %v", team, err)
+		}
+
+		teams, err := s.teams.FindTeamsByMember(ctx, orgID, "ada@example.com")
+		if err != nil || len(teams) != 1 || teams[0].Id.Hex() != id {
+			t.Fatalf("FindTeamsByMember: %+v, %v", teams, err)
+		}
+		teams, _ = s.teams.GetTeamsByOrganization(ctx, orgID)
+		if len(teams) != 2 || teams[0].Name != "Billing" {
+			t.Fatalf("expected both teams ordered by name, got %+v", teams)
+		}
+
+		if _, err := s.teams.RemoveTeamMember(ctx, orgID, id, "bob@example.com"); !errors.Is(err, apperror.ErrNotFound) {
+			t.Fatalf("expected not found removing a non-member, got %v", err)
+		}
+		team, err = s.teams.RemoveTeamMember(ctx, orgID, id, "ada@example.com")
+		if err != nil || len(team.Members) != 0 {
+			t.Fatalf("RemoveTeamMember: %+v, %v", team, err)
+		}
+
+		if err := s.teams.DeleteTeam(ctx, orgID, id); err != nil {
+			t.Fatalf("DeleteTeam: %v", err)
+		}
+		if err := s.teams.DeleteTeam(ctx, orgID, id); !errors.Is(err, apperror.ErrNotFound) {
+			t.Fatalf("expected not found deleting a missing team, got %v", err)
+		}
+
+		if err := s.teams.DeleteTeams(ctx, orgID); err != nil {
+			t.Fatalf("DeleteTeams: %v", err)
+		}
+		if teams, _ := s.teams.GetTeamsByOrganization(ctx, orgID); len(teams) != 0 {
+			t.Fatalf("expected no teams left, got %+v", teams)
+		}
+		if teams, _ := s.teams.GetTeamsByOrganization(ctx, otherOrgID); len(teams) != 1 {
+			t.Fatalf("expected the other organization's team to stay, got %+v", teams)
+		}
+	})
+
+	t.Run("ImportJobs", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStores(t)
+		now := time.Now().UTC().Truncate(time.Millisecond)
+		job := &models.ImportJob{
+			Owner:        "ada@example.com",
+			Status:       models.ImportJobRunning,
+			ImportReport: models.ImportReport{Total: 2},
+			CreatedAt:    now,
+			UpdatedAt:    now,
+			ExpiresAt:    now.Add(time.Hour),
+		}
+		id, err := s.importJobs.CreateImportJob(ctx, job)
+		if err != nil || id != job.Id.Hex() {
+			t.Fatalf("CreateImportJob: %q, %v", id, err)
+		}
+
+		job.Status = models.ImportJobCompleted
+		job.Processed, job.Created, job.Failed = 2, 1, 1
+		job.Rows = []models.ImportRowResult{
+			{Row: 1, Status: models.ImportRowCreated, OrganizationId: primitive.NewObjectID().Hex(), Slug: "acme"},
+			{Row: 2, Status: models.ImportRowInvalid, Errors: []models.ImportFieldError{{Field: "name", Message: "is required"}}},
+		}
+		if err := s.importJobs.UpdateImportJob(ctx, job); err != nil {
+			t.Fatalf("UpdateImportJob: %v", err)
+		}
+
+		got, err := s.importJobs.GetImportJob(ctx, "ada@example.com", id)
+		if err != nil {
+			t.Fatalf("GetImportJob: %v", err)
+		}
+		if got.Status != models.ImportJobCompleted || got.Created != 1 || len(got.Rows) != 2 || got.Rows[1].Errors[0].Field != "name" || !got.CreatedAt.Equal(now) {
+			t.Fatalf("unexpected job: %+v", got)
+		}
+
+		if _, err := s.importJobs.GetImportJob(ctx, "bob@example.com", id); !errors.Is(err, apperror.ErrNotFound) {
+			t.Fatalf("expected another caller's job to be not found, got %v", err)
+		}
+		missing := &models.ImportJob{Id: primitive.NewObjectID(), Owner: "ada@example.com"}
+		if err := s.importJobs.UpdateImportJob(ctx, missing); !errors.Is(err, apperror.ErrNotFound) {
+			t.Fatalf("expected not found when updating a missing job, got %v", err)
+		}
+		if _, err := s.importJobs.GetImportJob(ctx, "ada@example.com", "bad"); !errors.Is(err, apperror.ErrInvalidID) {
+			t.Fatalf("expected invalid id, got %v", err)
+		}
+	})
+
+	t.Run("Webhooks", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStores(t)
+		orgID := primitive.NewObjectID().Hex()
+
+		all := &models.Webhook{OrganizationId: orgID, URL: "http://a", Active: true, CreatedAt: time.Now().UTC()}
+		filtered := &models.Webhook{OrganizationId: orgID, URL: "http://b", Events: []string{"member.invited"}, Active: true}
+		inactive := &models.Webhook{OrganizationId: orgID, URL: "http://c", Active: false}
+		for _, hook := range []*models.Webhook{all, filtered, inactive} {
+			if _, err := s.webhooks.CreateWebhook(ctx, hook); err != nil {
+				t.Fatalf("CreateWebhook: %v", err)
+			}
+		}
+
+		hooks, err := s.webhooks.GetWebhooksByOrganization(ctx, orgID)
+		if err != nil || len(hooks) != 3 {
+			t.Fatalf("GetWebhooksByOrganization: %d hooks, %v", len(hooks), err)
+		}
+
+		matched, err := s.webhooks.FindWebhooksForEvent(ctx, orgID, "organization.updated")
+		if err != nil || len(matched) != 1 || matched[0].Id != all.Id {
+			t.Fatalf("FindWebhooksForEvent(organization.updated): %v, %v", matched, err)
+		}
+		matched, err = s.webhooks.FindWebhooksForEvent(ctx, orgID, "member.invited")
+		if err != nil || len(matched) != 2 {
+			t.Fatalf("FindWebhooksForEvent(member.invited): %v, %v", matched, err)
+		}
+
+		if _, err := s.webhooks.GetWebhookById(ctx, primitive.NewObjectID().Hex(), all.Id.Hex()); !errors.Is(err, apperror.ErrNotFound) {
+			t.Fatalf("expected webhook lookup scoped to another organization to be not found, got %v", err)
+		}
+
+		older := &models.WebhookDelivery{WebhookId: all.Id, OrganizationId: orgID, Status: models.DeliveryDead, CreatedAt: time.Now().UTC().Add(-time.Minute)}
+		newer := &models.WebhookDelivery{WebhookId: all.Id, OrganizationId: orgID, Status: models.DeliveryPending, CreatedAt: time.Now().UTC()}
+		for _, delivery := range []*models.WebhookDelivery{older, newer} {
+			if err := s.webhooks.SaveDelivery(ctx, delivery); err != nil {
+				t.Fatalf("SaveDelivery: %v", err)
+			}
+		}
+		newer.Status = models.DeliverySucceeded
+		if err := s.webhooks.SaveDelivery(ctx, newer); err != nil {
+			t.Fatalf("SaveDelivery: %v", err)
+		}
+
+		deliveries, err := s.webhooks.GetDeliveriesByWebhook(ctx, all.Id.Hex())
+		if err != nil || len(deliveries) != 2 {
+			t.Fatalf("GetDeliveriesByWebhook: %d deliveries, %v", len(deliveries), err)
+		}
+		if deliveries[0].Id != newer.Id || deliveries[0].Status != models.DeliverySucceeded {
+			t.Fatalf("expected newest delivery first with updated status, got %+v", deliveries[0])
+		}
+
+		if err := s.webhooks.DeleteWebhook(ctx, orgID, all.Id.Hex()); err != nil {
+			t.Fatalf("DeleteWebhook: %v", err)
+		}
+		if err := s.webhooks.DeleteWebhook(ctx, orgID, all.Id.Hex()); !errors.Is(err, apperror.ErrNotFound) {
+			t.Fatalf("expected not found deleting missing webhook, got %v", err)
+		}
+
+		// Deleting the organization's webhooks takes their deliveries along; later ones are dropped.
+		other := &models.Webhook{OrganizationId: primitive.NewObjectID().Hex(), URL: "http://d", Active: true}
+		s.webhooks.CreateWebhook(ctx, other)
+		s.webhooks.SaveDelivery(ctx, &models.WebhookDelivery{WebhookId: filtered.Id, OrganizationId: orgID, CreatedAt: time.Now().UTC()})
+		if err := s.webhooks.DeleteWebhooks(ctx, orgID); err != nil {
+			t.Fatalf("DeleteWebhooks: %v", err)
+		}
+		if hooks, err := s.webhooks.GetWebhooksByOrganization(ctx, orgID); err != nil || len(hooks) != 0 {
+			t.Fatalf("expected no webhooks left, got %d, %v", len(hooks), err)
+		}
+		if deliveries, err := s.webhooks.GetDeliveriesByWebhook(ctx, filtered.Id.Hex()); err != nil || len(deliveries) != 0 {
+			t.Fatalf("expected no deliveries left, got %d, %v", len(deliveries), err)
+		}
+		late := &models.WebhookDelivery{WebhookId: filtered.Id, OrganizationId: orgID, CreatedAt: time.Now().UTC()}
+		if err := s.webhooks.SaveDelivery(ctx, late); err != nil {
+			t.Fatalf("SaveDelivery after deletion: %v", err)
+		}
+		if deliveries, _ := s.webhooks.GetDeliveriesByWebhook(ctx, filtered.Id.Hex()); len(deliveries) != 0 {
+			t.Fatalf("expected the late delivery to be dropped, got %d", len(deliveries))
+		}
+		if hooks, _ := s.webhooks.GetWebhooksByOrganization(ctx, other.OrganizationId); len(hooks) != 1 {
+			t.Fatalf("expected the other organization's webhook to stay, got %d", len(hooks))
+		}
+	})
+
+	t.Run("CancelledContext", func(t *testing.T) {
+		s := newStores(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		if _, err := s.organizations.CreateOrganization(ctx, &models.Organization{Name: "Acme", Description: "Widgets"}); err == nil {
+			t.Fatal("expected CreateOrganization to fail with a cancelled context")
+		}
+		if _, err := s.organizations.GetAllOrganizations(ctx); err == nil {
+			t.Fatal("expected GetAllOrganizations to fail with a cancelled context")
+		}
+		if _, err := s.users.FindUserByEmail(ctx, "ada@example.com"); err == nil {
+			t.Fatal("expected FindUserByEmail to fail with a cancelled context")
+		}
+	})
+}
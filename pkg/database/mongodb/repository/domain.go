@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/organization_api/pkg/apperror"
+	"github.com/organization_api/pkg/database"
+	"github.com/organization_api/pkg/database/mongodb/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DomainRepo stores the email domains organizations claim. Unique indexes allow one claim per
+// organization and domain, and one verified claim per domain.
+type DomainRepo struct {
+	collection *mongo.Collection
+	timeouts   operationTimeouts
+}
+
+// NewDomainRepo initializes a new DomainRepo instance.
+func NewDomainRepo() *DomainRepo {
+	// Get the MongoDB collection for domain claims.
+	return &DomainRepo{
+		collection: database.GetDatabase().Collection("domain_claim"),
+		timeouts:   newOperationTimeouts(),
+	}
+}
+
+// CreateDomainClaim inserts a new claim and returns its ID.
+func (repo *DomainRepo) CreateDomainClaim(ctx context.Context, claim *models.DomainClaim) (string, error) {
+	ctx, done := startOperation(ctx, "domain_claim", "CreateDomainClaim")
+	defer done()
+
+	ctx, cancel := repo.timeouts.forWrite(ctx)
+	defer cancel()
+
+	result, err := repo.collection.InsertOne(ctx, claim)
+	if err != nil {
+		return "", translateError(err, "Domain claim")
+	}
+
+	claim.Id = result.InsertedID.(primitive.ObjectID)
+	return claim.Id.Hex(), nil
+}
+
+// GetDomainClaims lists the claims of an organization, oldest first.
+func (repo *DomainRepo) GetDomainClaims(ctx context.Context, organizationID string) ([]*models.DomainClaim, error) {
+	ctx, done := startOperation(ctx, "domain_claim", "GetDomainClaims")
+	defer done()
+
+	ctx, cancel := repo.timeouts.forRead(ctx)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+	cursor, err := repo.collection.Find(ctx, bson.M{"organization_id": organizationID}, opts)
+	if err != nil {
+		return nil, translateError(err, "Domain claim")
+	}
+
+	claims := []*models.DomainClaim{}
+	if err := cursor.All(ctx, &claims); err != nil {
+		return nil, translateError(err, "Domain claim")
+	}
+	return claims, nil
+}
+
+// GetDomainClaim retrieves the organization's claim to a domain.
+func (repo *DomainRepo) GetDomainClaim(ctx context.Context, organizationID, domain string) (*models.DomainClaim, error) {
+	ctx, done := startOperation(ctx, "domain_claim", "GetDomainClaim")
+	defer done()
+
+	return repo.findOne(ctx, bson.M{"organization_id": organizationID, "domain": domain})
+}
+
+// VerifyDomainClaim marks the organization's claim to a domain verified.
+func (repo *DomainRepo) VerifyDomainClaim(ctx context.Context, organizationID, domain string, at time.Time) (*models.DomainClaim, error) {
+	ctx, done := startOperation(ctx, "domain_claim", "VerifyDomainClaim")
+	defer done()
+
+	ctx, cancel := repo.timeouts.forWrite(ctx)
+	defer cancel()
+
+	filter := bson.M{"organization_id": organizationID, "domain": domain}
+	update := bson.M{"$set": bson.M{"status": models.DomainVerified, "verified_at": at}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var claim models.DomainClaim
+	err := repo.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&claim)
+	if mongo.IsDuplicateKeyError(err) {
+		// The partial unique index on verified domains rejected the update.
+		return nil, apperror.Conflict("Domain %s is already verified by another organization", domain).Wrap(err)
+	}
+	if err != nil {
+		return nil, translateError(err, "Domain claim")
+	}
+	return &claim, nil
+}
+
+// DeleteDomainClaim removes the organization's claim to a domain.
+func (repo *DomainRepo) DeleteDomainClaim(ctx context.Context, organizationID, domain string) error {
+	ctx, done := startOperation(ctx, "domain_claim", "DeleteDomainClaim")
+	defer done()
+
+	ctx, cancel := repo.timeouts.forWrite(ctx)
+	defer cancel()
+
+	result, err := repo.collection.DeleteOne(ctx, bson.M{"organization_id": organizationID, "domain": domain})
+	if err != nil {
+		return translateError(err, "Domain claim")
+	}
+	if result.DeletedCount == 0 {
+		return translateError(mongo.ErrNoDocuments, "Domain claim")
+	}
+	return nil
+}
+
+// DeleteDomainClaims removes every claim of a deleted organization.
+func (repo *DomainRepo) DeleteDomainClaims(ctx context.Context, organizationID string) error {
+	ctx, done := startOperation(ctx, "domain_claim", "DeleteDomainClaims")
+	defer done()
+
+	ctx, cancel := repo.timeouts.forWrite(ctx)
+	defer cancel()
+
+	_, err := repo.collection.DeleteMany(ctx, bson.M{"organization_id": organizationID})
+	return translateError(err, "Domain claim")
+}
+
+// FindVerifiedDomainClaim retrieves the verified claim to a domain, whichever organization holds it.
+func (repo *DomainRepo) FindVerifiedDomainClaim(ctx context.Context, domain string) (*models.DomainClaim, error) {
+	ctx, done := startOperation(ctx, "domain_claim", "FindVerifiedDomainClaim")
+	defer done()
+
+	return repo.findOne(ctx, bson.M{"domain": domain, "status": models.DomainVerified})
+}
+
+func (repo *DomainRepo) findOne(ctx context.Context, filter bson.M) (*models.DomainClaim, error) {
+	ctx, cancel := repo.timeouts.forRead(ctx)
+	defer cancel()
+
+	var claim models.DomainClaim
+	if err := repo.collection.FindOne(ctx, filter).Decode(&claim); err != nil {
+		return nil, translateError(err, "Domain claim")
+	}
+	return &claim, nil
+}
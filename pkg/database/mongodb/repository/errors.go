@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/organization_api/pkg/apperror"
+	"github.com/organization_api/pkg/database/mongodb/migrations"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// translateError maps MongoDB driver errors onto typed domain errors for the named resource.
+func translateError(err error, resource string) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, mongo.ErrNoDocuments):
+		return apperror.NotFound("%s not found", resource)
+	case mongo.IsDuplicateKeyError(err):
+		return apperror.Conflict("%s already exists", resource).Wrap(err)
+	case IsTimeout(err):
+		return apperror.Timeout("Database operation timed out").Wrap(err)
+	default:
+		return err
+	}
+}
+
+// parseID converts a hex identifier into an ObjectID, reporting malformed input as an invalid ID.
+func parseID(id, resource string) (primitive.ObjectID, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return primitive.NilObjectID, apperror.InvalidID("Invalid %s id %q", strings.ToLower(resource), id)
+	}
+	return objectID, nil
+}
+
+// isSlugConflict reports whether err is a write rejected because another organization uses the slug.
+func isSlugConflict(err error) bool {
+	return mongo.IsDuplicateKeyError(err) && strings.Contains(err.Error(), migrations.SlugIndex)
+}
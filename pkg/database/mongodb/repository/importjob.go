@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/organization_api/pkg/database"
+	"github.com/organization_api/pkg/database/mongodb/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ImportJobRepo stores the progress of imports running in the background.
+type ImportJobRepo struct {
+	collection *mongo.Collection
+	timeouts   operationTimeouts
+}
+
+// NewImportJobRepo initializes a new ImportJobRepo instance.
+func NewImportJobRepo() *ImportJobRepo {
+	// Get the MongoDB collection for import jobs.
+	return &ImportJobRepo{
+		collection: database.GetDatabase().Collection("import_job"),
+		timeouts:   newOperationTimeouts(),
+	}
+}
+
+// CreateImportJob inserts a new job and returns its ID.
+func (repo *ImportJobRepo) CreateImportJob(ctx context.Context, job *models.ImportJob) (string, error) {
+	ctx, done := startOperation(ctx, "import_job", "CreateImportJob")
+	defer done()
+
+	ctx, cancel := repo.timeouts.forWrite(ctx)
+	defer cancel()
+
+	result, err := repo.collection.InsertOne(ctx, job)
+	if err != nil {
+		return "", translateError(err, "Import job")
+	}
+
+	job.Id = result.InsertedID.(primitive.ObjectID)
+	return job.Id.Hex(), nil
+}
+
+// GetImportJob retrieves a job started by owner.
+func (repo *ImportJobRepo) GetImportJob(ctx context.Context, owner, jobID string) (*models.ImportJob, error) {
+	ctx, done := startOperation(ctx, "import_job", "GetImportJob")
+	defer done()
+
+	objectID, err := parseID(jobID, "Import job")
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := repo.timeouts.forRead(ctx)
+	defer cancel()
+
+	var job models.ImportJob
+	filter := bson.M{"_id": objectID, "owner": owner}
+	if err := repo.collection.FindOne(ctx, filter).Decode(&job); err != nil {
+		return nil, translateError(err, "Import job")
+	}
+	return &job, nil
+}
+
+// UpdateImportJob saves the status and report of a job.
+func (repo *ImportJobRepo) UpdateImportJob(ctx context.Context, job *models.ImportJob) error {
+	ctx, done := startOperation(ctx, "import_job", "UpdateImportJob")
+	defer done()
+
+	ctx, cancel := repo.timeouts.forWrite(ctx)
+	defer cancel()
+
+	result, err := repo.collection.ReplaceOne(ctx, bson.M{"_id": job.Id}, job)
+	if err != nil {
+		return translateError(err, "Import job")
+	}
+	if result.MatchedCount == 0 {
+		return translateError(mongo.ErrNoDocuments, "Import job")
+	}
+	return nil
+}
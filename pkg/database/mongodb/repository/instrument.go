@@ -0,0 +1,29 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/organization_api/pkg/metrics"
+	"github.com/organization_api/pkg/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// startOperation opens a span for a repository method and starts timing it.
+// Call the returned function when the method returns. The driver's command
+// spans nest under this one, so a slow method shows which commands it ran.
+func startOperation(ctx context.Context, repository, operation string) (context.Context, func()) {
+	observe := metrics.ObserveMongo(repository, operation)
+	ctx, span := tracing.Start(ctx, "repository."+repository+"."+operation,
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(
+			attribute.String("repository", repository),
+			attribute.String("operation", operation),
+		),
+	)
+	return ctx, func() {
+		span.End()
+		observe()
+	}
+}
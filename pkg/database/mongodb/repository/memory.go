@@ -0,0 +1,1530 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/organization_api/pkg/apperror"
+	"github.com/organization_api/pkg/database/mongodb/models"
+	"github.com/organization_api/pkg/slug"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MemoryOrganizationRepo is a thread-safe in-memory OrganizationStore, intended for tests.
+type MemoryOrganizationRepo struct {
+	mu    sync.RWMutex
+	order []primitive.ObjectID
+	orgs  map[primitive.ObjectID]*models.Organization
+	slugs map[string]primitive.ObjectID
+}
+
+// NewMemoryOrganizationRepo initializes an empty MemoryOrganizationRepo.
+func NewMemoryOrganizationRepo() *MemoryOrganizationRepo {
+	return &MemoryOrganizationRepo{
+		orgs:  make(map[primitive.ObjectID]*models.Organization),
+		slugs: make(map[string]primitive.ObjectID),
+	}
+}
+
+func (repo *MemoryOrganizationRepo) GetOrganizationById(ctx context.Context, organizationID string) (*models.Organization, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	objectID, err := parseID(organizationID, "Organization")
+	if err != nil {
+		return nil, err
+	}
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	org, ok := repo.orgs[objectID]
+	if !ok {
+		return nil, apperror.NotFound("Organization not found")
+	}
+
+	return cloneOrganization(org), nil
+}
+
+func (repo *MemoryOrganizationRepo) CreateOrganization(ctx context.Context, org *models.Organization) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	stored := cloneOrganization(org)
+	if stored.Id.IsZero() {
+		stored.Id = primitive.NewObjectID()
+	}
+	if _, exists := repo.orgs[stored.Id]; exists {
+		return "", apperror.Conflict("Organization already exists")
+	}
+
+	candidate, ok := repo.freeSlug(slug.Make(stored.Name), stored.Id)
+	if !ok {
+		return "", apperror.Conflict("No free slug for %q; choose a more distinctive name", stored.Name)
+	}
+	stored.Slug, stored.Slugs = candidate, []string{candidate}
+	org.Slug, org.Slugs = stored.Slug, stored.Slugs
+
+	repo.slugs[candidate] = stored.Id
+	repo.orgs[stored.Id] = stored
+	repo.order = append(repo.order, stored.Id)
+	return stored.Id.Hex(), nil
+}
+
+func (repo *MemoryOrganizationRepo) GetOrganizationBySlug(ctx context.Context, slug string) (*models.Organization, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	id, ok := repo.slugs[slug]
+	if !ok {
+		return nil, apperror.NotFound("Organization not found")
+	}
+
+	return cloneOrganization(repo.orgs[id]), nil
+}
+
+// freeSlug returns the first candidate for base that no other organization uses or used.
+func (repo *MemoryOrganizationRepo) freeSlug(base string, owner primitive.ObjectID) (string, bool) {
+	for n := 1; n <= slug.MaxAttempts; n++ {
+		candidate := slug.Candidate(base, n)
+		if id, taken := repo.slugs[candidate]; !taken || id == owner {
+			return candidate, true
+		}
+	}
+	return "", false
+}
+
+func (repo *MemoryOrganizationRepo) GetAllOrganizations(ctx context.Context) ([]*models.Organization, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	var organizations []*models.Organization
+	for _, id := range repo.order {
+		organizations = append(organizations, cloneOrganization(repo.orgs[id]))
+	}
+
+	return organizations, nil
+}
+
+func (repo *MemoryOrganizationRepo) FindOrganizations(ctx context.Context, filter models.OrganizationFilter) ([]*models.Organization, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	var organizations []*models.Organization
+	for _, id := range repo.order {
+		if org := repo.orgs[id]; filter.Matches(org) {
+			organizations = append(organizations, cloneOrganization(org))
+		}
+	}
+
+	return organizations, nil
+}
+
+func (repo *MemoryOrganizationRepo) GetChildOrganizations(ctx context.Context, parentID string) ([]*models.Organization, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	var organizations []*models.Organization
+	for _, id := range repo.order {
+		if org := repo.orgs[id]; org.ParentId == parentID {
+			organizations = append(organizations, cloneOrganization(org))
+		}
+	}
+
+	return organizations, nil
+}
+
+func (repo *MemoryOrganizationRepo) FindOrganizationsByMember(ctx context.Context, email string) ([]*models.Organization, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	var organizations []*models.Organization
+	for _, id := range repo.order {
+		if org := repo.orgs[id]; containsString(org.InvitedUsers, email) {
+			organizations = append(organizations, cloneOrganization(org))
+		}
+	}
+
+	return organizations, nil
+}
+
+func (repo *MemoryOrganizationRepo) SearchOrganizations(ctx context.Context, query models.SearchQuery) ([]*models.SearchHit, int64, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	var hits []*models.SearchHit
+	for _, id := range query.Within {
+		objectID, err := parseID(id, "Organization")
+		if err != nil {
+			return nil, 0, err
+		}
+		org, ok := repo.orgs[objectID]
+		if !ok {
+			continue
+		}
+		// The weights mirror those of the organization_text index.
+		score := textScore(query.Text, weightedField{org.Name, 10}, weightedField{org.Description, 1})
+		if score > 0 {
+			hits = append(hits, &models.SearchHit{Type: models.SearchTypeOrganization, Score: score, Organization: cloneOrganization(org)})
+		}
+	}
+
+	return rankHits(hits, query.Limit)
+}
+
+func (repo *MemoryOrganizationRepo) SetParent(ctx context.Context, organizationID, parentID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	objectID, err := parseID(organizationID, "Organization")
+	if err != nil {
+		return err
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	org, ok := repo.orgs[objectID]
+	if !ok {
+		return apperror.NotFound("Organization not found")
+	}
+	org.ParentId = parentID
+
+	return nil
+}
+
+func (repo *MemoryOrganizationRepo) UpdateOrganization(ctx context.Context, organizationID string, updateData *models.OrganizationUpdate) (*models.Organization, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	objectID, err := parseID(organizationID, "Organization")
+	if err != nil {
+		return nil, err
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	org, ok := repo.orgs[objectID]
+	if !ok {
+		return nil, apperror.NotFound("Organization not found")
+	}
+	// Keep the slug while the new name has the same base as the old one; otherwise derive a new one and keep the old as an alias.
+	if base := slug.Make(updateData.Name); !keepsSlug(org, base) {
+		candidate, ok := repo.freeSlug(base, org.Id)
+		if !ok {
+			return nil, apperror.Conflict("No free slug for %q; choose a more distinctive name", updateData.Name)
+		}
+		org.Slug = candidate
+		if !containsString(org.Slugs, candidate) {
+			org.Slugs = append(org.Slugs, candidate)
+		}
+		repo.slugs[candidate] = org.Id
+	}
+	org.Name = updateData.Name
+	org.Description = updateData.Description
+
+	return cloneOrganization(org), nil
+}
+
+func (repo *MemoryOrganizationRepo) SetMetadata(ctx context.Context, organizationID string, metadata map[string]string) (*models.Organization, error) {
+	return repo.update(ctx, organizationID, func(org *models.Organization) {
+		org.Metadata = cloneMetadata(metadata)
+	})
+}
+
+func (repo *MemoryOrganizationRepo) SetTags(ctx context.Context, organizationID string, tags []string) (*models.Organization, error) {
+	return repo.update(ctx, organizationID, func(org *models.Organization) {
+		org.Tags = append([]string(nil), tags...)
+	})
+}
+
+// update applies change to the stored organization and returns a copy of the result.
+func (repo *MemoryOrganizationRepo) update(ctx context.Context, organizationID string, change func(org *models.Organization)) (*models.Organization, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	objectID, err := parseID(organizationID, "Organization")
+	if err != nil {
+		return nil, err
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	org, ok := repo.orgs[objectID]
+	if !ok {
+		return nil, apperror.NotFound("Organization not found")
+	}
+	change(org)
+
+	return cloneOrganization(org), nil
+}
+
+func (repo *MemoryOrganizationRepo) DeleteOrganization(ctx context.Context, organizationID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	objectID, err := parseID(organizationID, "Organization")
+	if err != nil {
+		return err
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	org, ok := repo.orgs[objectID]
+	if !ok {
+		return apperror.NotFound("Organization not found")
+	}
+	for _, alias := range org.Slugs {
+		delete(repo.slugs, alias)
+	}
+	delete(repo.orgs, objectID)
+	for i, id := range repo.order {
+		if id == objectID {
+			repo.order = append(repo.order[:i], repo.order[i+1:]...)
+			break
+		}
+	}
+
+	return nil
+}
+
+func (repo *MemoryOrganizationRepo) InviteUserToOrganization(ctx context.Context, organizationID, userEmail string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	objectID, err := parseID(organizationID, "Organization")
+	if err != nil {
+		return err
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	org, ok := repo.orgs[objectID]
+	if !ok {
+		return apperror.NotFound("Organization not found")
+	}
+	for _, invited := range org.InvitedUsers {
+		if invited == userEmail {
+			return nil
+		}
+	}
+	org.InvitedUsers = append(org.InvitedUsers, userEmail)
+
+	return nil
+}
+
+func (repo *MemoryOrganizationRepo) InviteUsersToOrganization(ctx context.Context, organizationID string, emails []string, opts models.InviteOptions) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	objectID, err := parseID(organizationID, "Organization")
+	if err != nil {
+		return nil, err
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	org, ok := repo.orgs[objectID]
+	if !ok {
+		return nil, apperror.NotFound("Organization not found")
+	}
+	invited := newMembers(org.InvitedUsers, emails)
+	for _, invitation := range opts.Pending {
+		if containsString(invited, invitation.Email) {
+			org.Invitations = append(org.Invitations, invitation)
+		}
+	}
+	if opts.Admin {
+		org.Admins = append(org.Admins, invited...)
+	}
+	org.InvitedUsers = append(org.InvitedUsers, invited...)
+
+	return invited, nil
+}
+
+func (repo *MemoryOrganizationRepo) AcceptInvitations(ctx context.Context, email string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	now := time.Now()
+	for _, org := range repo.orgs {
+		var kept []models.Invitation
+		lapsed := false
+		for _, invitation := range org.Invitations {
+			switch {
+			case invitation.Email != email:
+				kept = append(kept, invitation)
+			case !invitation.ExpiresAt.After(now):
+				lapsed = true
+			}
+		}
+		org.Invitations = kept
+		if lapsed && org.Owner != email {
+			org.InvitedUsers = removeString(org.InvitedUsers, email)
+			org.Admins = removeString(org.Admins, email)
+		}
+	}
+
+	return nil
+}
+
+func (repo *MemoryOrganizationRepo) RemoveMember(ctx context.Context, organizationID, email string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	objectID, err := parseID(organizationID, "Organization")
+	if err != nil {
+		return err
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	org, ok := repo.orgs[objectID]
+	if !ok {
+		return apperror.NotFound("Organization not found")
+	}
+	if org.Owner == email {
+		return apperror.Conflict("%s owns the organization; transfer ownership first", email)
+	}
+	if !containsString(org.InvitedUsers, email) {
+		return apperror.NotFound("Member not found")
+	}
+
+	org.InvitedUsers = removeString(org.InvitedUsers, email)
+	org.Admins = removeString(org.Admins, email)
+	var kept []models.Invitation
+	for _, invitation := range org.Invitations {
+		if invitation.Email != email {
+			kept = append(kept, invitation)
+		}
+	}
+	org.Invitations = kept
+
+	return nil
+}
+
+func (repo *MemoryOrganizationRepo) TransferOwnership(ctx context.Context, organizationID, currentOwner, newOwner string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	objectID, err := parseID(organizationID, "Organization")
+	if err != nil {
+		return err
+	}
+	if currentOwner == newOwner {
+		return apperror.Conflict("%s already owns the organization", newOwner)
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	org, ok := repo.orgs[objectID]
+	if !ok {
+		return apperror.NotFound("Organization not found")
+	}
+	if org.Owner != currentOwner {
+		return apperror.Conflict("Ownership of the organization has changed")
+	}
+	if !containsString(org.InvitedUsers, newOwner) {
+		return apperror.Conflict("%s is not a member of the organization", newOwner)
+	}
+
+	admins := removeString(org.Admins, newOwner)
+	if currentOwner != "" && !containsString(admins, currentOwner) {
+		admins = append(admins, currentOwner)
+	}
+	org.Owner = newOwner
+	org.Admins = admins
+	org.OwnershipTransfers = append(org.OwnershipTransfers, models.OwnershipTransfer{From: currentOwner, To: newOwner, At: time.Now().UTC()})
+
+	return nil
+}
+
+func cloneOrganization(org *models.Organization) *models.Organization {
+	clone := *org
+	clone.InvitedUsers = append([]string(nil), org.InvitedUsers...)
+	clone.Admins = append([]string(nil), org.Admins...)
+	clone.Slugs = append([]string(nil), org.Slugs...)
+	clone.OwnershipTransfers = append([]models.OwnershipTransfer(nil), org.OwnershipTransfers...)
+	clone.Metadata = cloneMetadata(org.Metadata)
+	clone.Tags = append([]string(nil), org.Tags...)
+	clone.Invitations = append([]models.Invitation(nil), org.Invitations...)
+	return &clone
+}
+
+// cloneMetadata copies metadata; an empty map becomes nil, as MongoDB drops it.
+func cloneMetadata(metadata map[string]string) map[string]string {
+	if len(metadata) == 0 {
+		return nil
+	}
+	clone := make(map[string]string, len(metadata))
+	for key, value := range metadata {
+		clone[key] = value
+	}
+	return clone
+}
+
+func containsString(values []string, value string) bool {
+	for _, existing := range values {
+		if existing == value {
+			return true
+		}
+	}
+	return false
+}
+
+// removeString returns a copy of values without value.
+func removeString(values []string, value string) []string {
+	var kept []string
+	for _, existing := range values {
+		if existing != value {
+			kept = append(kept, existing)
+		}
+	}
+	return kept
+}
+
+// weightedField is a text-indexed field and the weight of a match in it.
+type weightedField struct {
+	value  string
+	weight float64
+}
+
+// textScore approximates the score of a language-neutral MongoDB text index: each word of text
+// found in a field adds the field's weight, and more so the larger the share of the field it makes up.
+func textScore(text string, fields ...weightedField) float64 {
+	terms := textTerms(text)
+	var score float64
+	for _, field := range fields {
+		words := textTerms(field.value)
+		counts := make(map[string]int, len(words))
+		for _, word := range words {
+			counts[word]++
+		}
+		matched := make(map[string]bool, len(terms))
+		for _, term := range terms {
+			if count := counts[term]; count > 0 && !matched[term] {
+				matched[term] = true
+				score += field.weight * (0.5*float64(count)/float64(len(words)) + 0.5)
+			}
+		}
+	}
+	return score
+}
+
+// textTerms splits text into lower-case words of letters and digits, as a text index does.
+func textTerms(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// rankHits orders hits best first, breaking ties by ID, and keeps the first limit of them.
+func rankHits(hits []*models.SearchHit, limit int) ([]*models.SearchHit, int64, error) {
+	sort.SliceStable(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hitID(hits[i]) < hitID(hits[j])
+	})
+
+	total := int64(len(hits))
+	if limit <= 0 {
+		return nil, total, nil
+	}
+	if limit < len(hits) {
+		hits = hits[:limit]
+	}
+	return hits, total, nil
+}
+
+func hitID(hit *models.SearchHit) string {
+	if hit.Organization != nil {
+		return hit.Organization.Id.Hex()
+	}
+	return hit.User.Id.Hex()
+}
+
+// MemoryUserRepository is a thread-safe in-memory UserStore, intended for tests.
+type MemoryUserRepository struct {
+	mu      sync.RWMutex
+	byEmail map[string]*models.User
+}
+
+// NewMemoryUserRepository initializes an empty MemoryUserRepository.
+func NewMemoryUserRepository() *MemoryUserRepository {
+	return &MemoryUserRepository{byEmail: make(map[string]*models.User)}
+}
+
+func (repo *MemoryUserRepository) FindUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	user, ok := repo.byEmail[email]
+	if !ok {
+		return nil, apperror.NotFound("User not found")
+	}
+
+	clone := *user
+	return &clone, nil
+}
+
+func (repo *MemoryUserRepository) FindRegisteredEmails(ctx context.Context, emails []string) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	var registered []string
+	for _, email := range emails {
+		if _, ok := repo.byEmail[email]; ok {
+			registered = append(registered, email)
+		}
+	}
+
+	return registered, nil
+}
+
+func (repo *MemoryUserRepository) CreateUser(ctx context.Context, user *models.User) (*models.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if _, exists := repo.byEmail[user.Email]; exists {
+		return nil, apperror.Conflict("A user with this email already exists")
+	}
+
+	stored := *user
+	stored.Id = primitive.NewObjectID()
+	repo.byEmail[stored.Email] = &stored
+
+	clone := stored
+	return &clone, nil
+}
+
+func (repo *MemoryUserRepository) SetUserDisabled(ctx context.Context, email string, disabled bool) error {
+	return repo.update(ctx, email, func(user *models.User) { user.Disabled = disabled })
+}
+
+func (repo *MemoryUserRepository) SetEmailVerified(ctx context.Context, email string) error {
+	return repo.update(ctx, email, func(user *models.User) { user.EmailVerified = true })
+}
+
+func (repo *MemoryUserRepository) UpdatePassword(ctx context.Context, email, passwordHash string) error {
+	return repo.update(ctx, email, func(user *models.User) { user.Password = passwordHash })
+}
+
+func (repo *MemoryUserRepository) SearchUsers(ctx context.Context, query models.SearchQuery) ([]*models.SearchHit, int64, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	var hits []*models.SearchHit
+	for _, email := range query.Within {
+		user, ok := repo.byEmail[email]
+		if !ok {
+			continue
+		}
+		// The weights mirror those of the user_text index.
+		score := textScore(query.Text, weightedField{user.Name, 10}, weightedField{user.Email, 5})
+		if score > 0 {
+			clone := *user
+			hits = append(hits, &models.SearchHit{Type: models.SearchTypeUser, Score: score, User: &clone})
+		}
+	}
+
+	return rankHits(hits, query.Limit)
+}
+
+func (repo *MemoryUserRepository) update(ctx context.Context, email string, apply func(*models.User)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	user, ok := repo.byEmail[email]
+	if !ok {
+		return apperror.NotFound("User not found")
+	}
+	apply(user)
+
+	return nil
+}
+
+// MemoryTokenRevocations is a thread-safe in-memory TokenRevocationStore, intended for tests.
+// Records never expire.
+type MemoryTokenRevocations struct {
+	mu      sync.RWMutex
+	revoked map[string]time.Time
+}
+
+// NewMemoryTokenRevocations initializes an empty MemoryTokenRevocations.
+func NewMemoryTokenRevocations() *MemoryTokenRevocations {
+	return &MemoryTokenRevocations{revoked: make(map[string]time.Time)}
+}
+
+func (repo *MemoryTokenRevocations) RevokeTokens(ctx context.Context, email string, at time.Time, ttl time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	repo.revoked[email] = at
+	return nil
+}
+
+func (repo *MemoryTokenRevocations) RevokedAt(ctx context.Context, email string) (time.Time, error) {
+	if err := ctx.Err(); err != nil {
+		return time.Time{}, err
+	}
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	return repo.revoked[email], nil
+}
+
+// MemoryRateLimits is a thread-safe in-memory RateLimitStore, intended for tests.
+type MemoryRateLimits struct {
+	mu      sync.Mutex
+	windows map[string]rateWindow
+}
+
+// rateWindow counts the requests made under a key since start.
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+// NewMemoryRateLimits initializes an empty MemoryRateLimits.
+func NewMemoryRateLimits() *MemoryRateLimits {
+	return &MemoryRateLimits{windows: make(map[string]rateWindow)}
+}
+
+func (repo *MemoryRateLimits) Allow(ctx context.Context, key string, limit int, window time.Duration) (bool, time.Duration, error) {
+	if err := ctx.Err(); err != nil {
+		return false, 0, err
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	now := time.Now()
+	current, ok := repo.windows[key]
+	if !ok || now.Sub(current.start) >= window {
+		current = rateWindow{start: now}
+	}
+	current.count++
+	repo.windows[key] = current
+
+	if current.count > limit {
+		return false, current.start.Add(window).Sub(now), nil
+	}
+	return true, 0, nil
+}
+
+// MemoryWebhookRepo is a thread-safe in-memory WebhookStore, intended for tests.
+type MemoryWebhookRepo struct {
+	mu         sync.RWMutex
+	hooks      []*models.Webhook
+	deliveries map[primitive.ObjectID]*models.WebhookDelivery
+}
+
+// NewMemoryWebhookRepo initializes an empty MemoryWebhookRepo.
+func NewMemoryWebhookRepo() *MemoryWebhookRepo {
+	return &MemoryWebhookRepo{deliveries: make(map[primitive.ObjectID]*models.WebhookDelivery)}
+}
+
+func (repo *MemoryWebhookRepo) CreateWebhook(ctx context.Context, hook *models.Webhook) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	hook.Id = primitive.NewObjectID()
+	repo.hooks = append(repo.hooks, cloneWebhook(hook))
+	return hook.Id.Hex(), nil
+}
+
+func (repo *MemoryWebhookRepo) GetWebhooksByOrganization(ctx context.Context, organizationID string) ([]*models.Webhook, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return repo.filter(func(hook *models.Webhook) bool {
+		return hook.OrganizationId == organizationID
+	}), nil
+}
+
+func (repo *MemoryWebhookRepo) GetWebhookById(ctx context.Context, organizationID, webhookID string) (*models.Webhook, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	objectID, err := parseID(webhookID, "Webhook")
+	if err != nil {
+		return nil, err
+	}
+
+	hooks := repo.filter(func(hook *models.Webhook) bool {
+		return hook.Id == objectID && hook.OrganizationId == organizationID
+	})
+	if len(hooks) == 0 {
+		return nil, apperror.NotFound("Webhook not found")
+	}
+
+	return hooks[0], nil
+}
+
+func (repo *MemoryWebhookRepo) DeleteWebhook(ctx context.Context, organizationID, webhookID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	objectID, err := parseID(webhookID, "Webhook")
+	if err != nil {
+		return err
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for i, hook := range repo.hooks {
+		if hook.Id == objectID && hook.OrganizationId == organizationID {
+			repo.hooks = append(repo.hooks[:i], repo.hooks[i+1:]...)
+			return nil
+		}
+	}
+
+	return apperror.NotFound("Webhook not found")
+}
+
+func (repo *MemoryWebhookRepo) DeleteWebhooks(ctx context.Context, organizationID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	deleted := make(map[primitive.ObjectID]bool)
+	kept := repo.hooks[:0]
+	for _, hook := range repo.hooks {
+		if hook.OrganizationId == organizationID {
+			deleted[hook.Id] = true
+		} else {
+			kept = append(kept, hook)
+		}
+	}
+	repo.hooks = kept
+	for id, delivery := range repo.deliveries {
+		if deleted[delivery.WebhookId] {
+			delete(repo.deliveries, id)
+		}
+	}
+	return nil
+}
+
+func (repo *MemoryWebhookRepo) FindWebhooksForEvent(ctx context.Context, organizationID, event string) ([]*models.Webhook, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return repo.filter(func(hook *models.Webhook) bool {
+		if hook.OrganizationId != organizationID || !hook.Active {
+			return false
+		}
+		if len(hook.Events) == 0 {
+			return true
+		}
+		for _, subscribed := range hook.Events {
+			if subscribed == event || subscribed == "*" {
+				return true
+			}
+		}
+		return false
+	}), nil
+}
+
+func (repo *MemoryWebhookRepo) SaveDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	// A delivery still in flight when its webhook is deleted would be left without one.
+	if !slices.ContainsFunc(repo.hooks, func(hook *models.Webhook) bool { return hook.Id == delivery.WebhookId }) {
+		return nil
+	}
+	if delivery.Id.IsZero() {
+		delivery.Id = primitive.NewObjectID()
+	}
+	clone := *delivery
+	repo.deliveries[clone.Id] = &clone
+
+	return nil
+}
+
+func (repo *MemoryWebhookRepo) GetDeliveriesByWebhook(ctx context.Context, webhookID string) ([]*models.WebhookDelivery, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	objectID, err := parseID(webhookID, "Webhook")
+	if err != nil {
+		return nil, err
+	}
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	var deliveries []*models.WebhookDelivery
+	for _, delivery := range repo.deliveries {
+		if delivery.WebhookId == objectID {
+			clone := *delivery
+			deliveries = append(deliveries, &clone)
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool {
+		return deliveries[i].CreatedAt.After(deliveries[j].CreatedAt)
+	})
+
+	return deliveries, nil
+}
+
+func (repo *MemoryWebhookRepo) filter(match func(hook *models.Webhook) bool) []*models.Webhook {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	var hooks []*models.Webhook
+	for _, hook := range repo.hooks {
+		if match(hook) {
+			hooks = append(hooks, cloneWebhook(hook))
+		}
+	}
+
+	return hooks
+}
+
+func cloneWebhook(hook *models.Webhook) *models.Webhook {
+	clone := *hook
+	clone.Events = append([]string(nil), hook.Events...)
+	return &clone
+}
+
+// Compile-time checks that the in-memory repositories satisfy the contracts.
+var (
+	_ OrganizationStore = (*MemoryOrganizationRepo)(nil)
+	_ UserStore         = (*MemoryUserRepository)(nil)
+	_ WebhookStore      = (*MemoryWebhookRepo)(nil)
+	_ ImportJobStore    = (*MemoryImportJobRepo)(nil)
+	_ SettingsStore     = (*MemorySettingsRepo)(nil)
+	_ DomainStore       = (*MemoryDomainRepo)(nil)
+
+	_ TokenRevocationStore = (*MemoryTokenRevocations)(nil)
+	_ RateLimitStore       = (*MemoryRateLimits)(nil)
+)
+
+// MemoryTeamRepo is a thread-safe in-memory TeamStore, intended for tests.
+type MemoryTeamRepo struct {
+	mu    sync.RWMutex
+	teams []*models.Team
+}
+
+// NewMemoryTeamRepo initializes an empty MemoryTeamRepo.
+func NewMemoryTeamRepo() *MemoryTeamRepo {
+	return &MemoryTeamRepo{}
+}
+
+func (repo *MemoryTeamRepo) CreateTeam(ctx context.Context, team *models.Team) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if repo.nameTaken(team.OrganizationId, team.Name, primitive.NilObjectID) {
+		return "", apperror.Conflict("Team already exists")
+	}
+	team.Id = primitive.NewObjectID()
+	repo.teams = append(repo.teams, cloneTeam(team))
+	return team.Id.Hex(), nil
+}
+
+func (repo *MemoryTeamRepo) GetTeamsByOrganization(ctx context.Context, organizationID string) ([]*models.Team, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return repo.filter(func(team *models.Team) bool {
+		return team.OrganizationId == organizationID
+	}), nil
+}
+
+func (repo *MemoryTeamRepo) FindTeamsByMember(ctx context.Context, organizationID, email string) ([]*models.Team, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return repo.filter(func(team *models.Team) bool {
+		_, ok := team.Member(email)
+		return team.OrganizationId == organizationID && ok
+	}), nil
+}
+
+func (repo *MemoryTeamRepo) GetTeamById(ctx context.Context, organizationID, teamID string) (*models.Team, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	objectID, err := parseID(teamID, "Team")
+	if err != nil {
+		return nil, err
+	}
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	team, err := repo.lookup(organizationID, objectID)
+	if err != nil {
+		return nil, err
+	}
+	return cloneTeam(team), nil
+}
+
+func (repo *MemoryTeamRepo) RenameTeam(ctx context.Context, organizationID, teamID, name string) (*models.Team, error) {
+	return repo.update(ctx, organizationID, teamID, func(team *models.Team) error {
+		if repo.nameTaken(organizationID, name, team.Id) {
+			return apperror.Conflict("Team already exists")
+		}
+		team.Name = name
+		return nil
+	})
+}
+
+func (repo *MemoryTeamRepo) SetTeamPermissions(ctx context.Context, organizationID, teamID string, permissions []string) (*models.Team, error) {
+	return repo.update(ctx, organizationID, teamID, func(team *models.Team) error {
+		team.Permissions = append([]string(nil), permissions...)
+		return nil
+	})
+}
+
+func (repo *MemoryTeamRepo) SetTeamMember(ctx context.Context, organizationID, teamID string, member models.TeamMember) (*models.Team, error) {
+	return repo.update(ctx, organizationID, teamID, func(team *models.Team) error {
+		team.Members = append(removeTeamMember(team.Members, member.Email), member)
+		return nil
+	})
+}
+
+func (repo *MemoryTeamRepo) RemoveTeamMember(ctx context.Context, organizationID, teamID, email string) (*models.Team, error) {
+	return repo.update(ctx, organizationID, teamID, func(team *models.Team) error {
+		if _, ok := team.Member(email); !ok {
+			return apperror.NotFound("Team member not found")
+		}
+		team.Members = removeTeamMember(team.Members, email)
+		return nil
+	})
+}
+
+func (repo *MemoryTeamRepo) RemoveMemberFromTeams(ctx context.Context, organizationID, email string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for _, team := range repo.teams {
+		if team.OrganizationId == organizationID {
+			team.Members = removeTeamMember(team.Members, email)
+		}
+	}
+	return nil
+}
+
+func (repo *MemoryTeamRepo) DeleteTeam(ctx context.Context, organizationID, teamID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	objectID, err := parseID(teamID, "Team")
+	if err != nil {
+		return err
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for i, team := range repo.teams {
+		if team.Id == objectID && team.OrganizationId == organizationID {
+			repo.teams = append(repo.teams[:i], repo.teams[i+1:]...)
+			return nil
+		}
+	}
+
+	return apperror.NotFound("Team not found")
+}
+
+func (repo *MemoryTeamRepo) DeleteTeams(ctx context.Context, organizationID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	kept := repo.teams[:0]
+	for _, team := range repo.teams {
+		if team.OrganizationId != organizationID {
+			kept = append(kept, team)
+		}
+	}
+	repo.teams = kept
+	return nil
+}
+
+// update applies change to a stored team under the write lock and returns a copy of the result.
+func (repo *MemoryTeamRepo) update(ctx context.Context, organizationID, teamID string, change func(team *models.Team) error) (*models.Team, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	objectID, err := parseID(teamID, "Team")
+	if err != nil {
+		return nil, err
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	team, err := repo.lookup(organizationID, objectID)
+	if err != nil {
+		return nil, err
+	}
+	if err := change(team); err != nil {
+		return nil, err
+	}
+	return cloneTeam(team), nil
+}
+
+func (repo *MemoryTeamRepo) lookup(organizationID string, teamID primitive.ObjectID) (*models.Team, error) {
+	for _, team := range repo.teams {
+		if team.Id == teamID && team.OrganizationId == organizationID {
+			return team, nil
+		}
+	}
+	return nil, apperror.NotFound("Team not found")
+}
+
+// nameTaken reports whether another team of the organization already uses name.
+func (repo *MemoryTeamRepo) nameTaken(organizationID, name string, except primitive.ObjectID) bool {
+	for _, team := range repo.teams {
+		if team.OrganizationId == organizationID && team.Name == name && team.Id != except {
+			return true
+		}
+	}
+	return false
+}
+
+// filter returns copies of the matching teams ordered by name.
+func (repo *MemoryTeamRepo) filter(keep func(team *models.Team) bool) []*models.Team {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	var teams []*models.Team
+	for _, team := range repo.teams {
+		if keep(team) {
+			teams = append(teams, cloneTeam(team))
+		}
+	}
+	sort.Slice(teams, func(i, j int) bool { return teams[i].Name < teams[j].Name })
+	return teams
+}
+
+func cloneTeam(team *models.Team) *models.Team {
+	clone := *team
+	clone.Members = append([]models.TeamMember(nil), team.Members...)
+	clone.Permissions = append([]string(nil), team.Permissions...)
+	return &clone
+}
+
+func removeTeamMember(members []models.TeamMember, email string) []models.TeamMember {
+	var kept []models.TeamMember
+	for _, member := range members {
+		if member.Email != email {
+			kept = append(kept, member)
+		}
+	}
+	return kept
+}
+
+// MemoryImportJobRepo is a thread-safe in-memory ImportJobStore, intended for tests. Jobs never expire.
+type MemoryImportJobRepo struct {
+	mu   sync.RWMutex
+	jobs map[primitive.ObjectID]*models.ImportJob
+}
+
+// NewMemoryImportJobRepo initializes an empty MemoryImportJobRepo.
+func NewMemoryImportJobRepo() *MemoryImportJobRepo {
+	return &MemoryImportJobRepo{jobs: make(map[primitive.ObjectID]*models.ImportJob)}
+}
+
+func (repo *MemoryImportJobRepo) CreateImportJob(ctx context.Context, job *models.ImportJob) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	job.Id = primitive.NewObjectID()
+	repo.jobs[job.Id] = cloneImportJob(job)
+	return job.Id.Hex(), nil
+}
+
+func (repo *MemoryImportJobRepo) GetImportJob(ctx context.Context, owner, jobID string) (*models.ImportJob, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	objectID, err := parseID(jobID, "Import job")
+	if err != nil {
+		return nil, err
+	}
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	job, ok := repo.jobs[objectID]
+	if !ok || job.Owner != owner {
+		return nil, apperror.NotFound("Import job not found")
+	}
+	return cloneImportJob(job), nil
+}
+
+func (repo *MemoryImportJobRepo) UpdateImportJob(ctx context.Context, job *models.ImportJob) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if _, ok := repo.jobs[job.Id]; !ok {
+		return apperror.NotFound("Import job not found")
+	}
+	repo.jobs[job.Id] = cloneImportJob(job)
+	return nil
+}
+
+func cloneImportJob(job *models.ImportJob) *models.ImportJob {
+	clone := *job
+	clone.Rows = append([]models.ImportRowResult(nil), job.Rows...)
+	return &clone
+}
+
+// MemorySettingsRepo is a thread-safe in-memory SettingsStore, intended for tests.
+type MemorySettingsRepo struct {
+	mu       sync.RWMutex
+	settings map[string]*models.OrganizationSettings
+}
+
+// NewMemorySettingsRepo initializes an empty MemorySettingsRepo.
+func NewMemorySettingsRepo() *MemorySettingsRepo {
+	return &MemorySettingsRepo{settings: make(map[string]*models.OrganizationSettings)}
+}
+
+func (repo *MemorySettingsRepo) GetSettings(ctx context.Context, organizationID string) (*models.OrganizationSettings, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if _, err := parseID(organizationID, "Organization"); err != nil {
+		return nil, err
+	}
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	settings, ok := repo.settings[organizationID]
+	if !ok {
+		return models.DefaultSettings(organizationID), nil
+	}
+	return cloneSettings(settings).WithDefaults(), nil
+}
+
+func (repo *MemorySettingsRepo) UpdateSettings(ctx context.Context, organizationID string, patch models.SettingsPatch, updatedBy string) (*models.OrganizationSettings, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if _, err := parseID(organizationID, "Organization"); err != nil {
+		return nil, err
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	settings, ok := repo.settings[organizationID]
+	if !ok {
+		settings = &models.OrganizationSettings{OrganizationId: organizationID}
+		repo.settings[organizationID] = settings
+	}
+	if patch.AllowedDomains != nil {
+		settings.AllowedDomains = append([]string{}, *patch.AllowedDomains...)
+	}
+	if patch.DefaultRole != nil {
+		settings.DefaultRole = *patch.DefaultRole
+	}
+	if patch.InvitationExpiryDays != nil {
+		settings.InvitationExpiryDays = *patch.InvitationExpiryDays
+	}
+	if patch.RequireMFA != nil {
+		settings.RequireMFA = *patch.RequireMFA
+	}
+	if patch.DomainJoin != nil {
+		settings.DomainJoin = *patch.DomainJoin
+	}
+	settings.SchemaVersion = models.SettingsSchemaVersion
+	settings.UpdatedAt = time.Now().UTC()
+	settings.UpdatedBy = updatedBy
+
+	return cloneSettings(settings).WithDefaults(), nil
+}
+
+func (repo *MemorySettingsRepo) DeleteSettings(ctx context.Context, organizationID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	delete(repo.settings, organizationID)
+	return nil
+}
+
+func cloneSettings(settings *models.OrganizationSettings) *models.OrganizationSettings {
+	clone := *settings
+	clone.AllowedDomains = append([]string(nil), settings.AllowedDomains...)
+	return &clone
+}
+
+// MemoryDomainRepo is a thread-safe in-memory DomainStore, intended for tests.
+type MemoryDomainRepo struct {
+	mu     sync.RWMutex
+	claims []*models.DomainClaim
+}
+
+// NewMemoryDomainRepo initializes an empty MemoryDomainRepo.
+func NewMemoryDomainRepo() *MemoryDomainRepo {
+	return &MemoryDomainRepo{}
+}
+
+func (repo *MemoryDomainRepo) CreateDomainClaim(ctx context.Context, claim *models.DomainClaim) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if repo.find(claim.OrganizationId, claim.Domain) != nil {
+		return "", apperror.Conflict("Domain claim already exists")
+	}
+	claim.Id = primitive.NewObjectID()
+	clone := *claim
+	repo.claims = append(repo.claims, &clone)
+	return claim.Id.Hex(), nil
+}
+
+func (repo *MemoryDomainRepo) GetDomainClaims(ctx context.Context, organizationID string) ([]*models.DomainClaim, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	claims := []*models.DomainClaim{}
+	for _, claim := range repo.claims {
+		if claim.OrganizationId == organizationID {
+			clone := *claim
+			claims = append(claims, &clone)
+		}
+	}
+	return claims, nil
+}
+
+func (repo *MemoryDomainRepo) GetDomainClaim(ctx context.Context, organizationID, domain string) (*models.DomainClaim, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	claim := repo.find(organizationID, domain)
+	if claim == nil {
+		return nil, apperror.NotFound("Domain claim not found")
+	}
+	clone := *claim
+	return &clone, nil
+}
+
+func (repo *MemoryDomainRepo) VerifyDomainClaim(ctx context.Context, organizationID, domain string, at time.Time) (*models.DomainClaim, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	claim := repo.find(organizationID, domain)
+	if claim == nil {
+		return nil, apperror.NotFound("Domain claim not found")
+	}
+	for _, other := range repo.claims {
+		if other != claim && other.Domain == domain && other.Status == models.DomainVerified {
+			return nil, apperror.Conflict("Domain %s is already verified by another organization", domain)
+		}
+	}
+	claim.Status = models.DomainVerified
+	claim.VerifiedAt = &at
+	clone := *claim
+	return &clone, nil
+}
+
+func (repo *MemoryDomainRepo) DeleteDomainClaim(ctx context.Context, organizationID, domain string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for i, claim := range repo.claims {
+		if claim.OrganizationId == organizationID && claim.Domain == domain {
+			repo.claims = append(repo.claims[:i], repo.claims[i+1:]...)
+			return nil
+		}
+	}
+	return apperror.NotFound("Domain claim not found")
+}
+
+func (repo *MemoryDomainRepo) DeleteDomainClaims(ctx context.Context, organizationID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	kept := repo.claims[:0]
+	for _, claim := range repo.claims {
+		if claim.OrganizationId != organizationID {
+			kept = append(kept, claim)
+		}
+	}
+	repo.claims = kept
+	return nil
+}
+
+func (repo *MemoryDomainRepo) FindVerifiedDomainClaim(ctx context.Context, domain string) (*models.DomainClaim, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	for _, claim := range repo.claims {
+		if claim.Domain == domain && claim.Status == models.DomainVerified {
+			clone := *claim
+			return &clone, nil
+		}
+	}
+	return nil, apperror.NotFound("Domain claim not found")
+}
+
+// find returns the organization's claim to a domain; the caller holds the lock.
+func (repo *MemoryDomainRepo) find(organizationID, domain string) *models.DomainClaim {
+	for _, claim := range repo.claims {
+		if claim.OrganizationId == organizationID && claim.Domain == domain {
+			return claim
+		}
+	}
+	return nil
+}
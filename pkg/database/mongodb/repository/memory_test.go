@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/organization_api/pkg/database/mongodb/models"
+)
+
+func TestMemoryConformance(t *testing.T) {
+	runConformance(t, func(t *testing.T) stores {
+		return stores{
+			organizations: NewMemoryOrganizationRepo(),
+			users:         NewMemoryUserRepository(),
+			teams:         NewMemoryTeamRepo(),
+			webhooks:      NewMemoryWebhookRepo(),
+			importJobs:    NewMemoryImportJobRepo(),
+			settings:      NewMemorySettingsRepo(),
+			domains:       NewMemoryDomainRepo(),
+		}
+	})
+}
+
+func TestMemoryOrganizationRepoConcurrentInvites(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryOrganizationRepo()
+	id, err := repo.CreateOrganization(ctx, &models.Organization{Name: "Acme", Description: "Widgets"})
+	if err != nil {
+		t.Fatalf("CreateOrganization: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			repo.InviteUserToOrganization(ctx, id, "a@example.com")
+			repo.GetAllOrganizations(ctx)
+		}()
+	}
+	wg.Wait()
+
+	org, _ := repo.GetOrganizationById(ctx, id)
+	if len(org.InvitedUsers) != 1 {
+		t.Fatalf("expected a single invited user, got %v", org.InvitedUsers)
+	}
+}
+
+func TestMemoryOrganizationRepoReturnsCopies(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryOrganizationRepo()
+	id, _ := repo.CreateOrganization(ctx, &models.Organization{Name: "Acme", Description: "Widgets"})
+
+	org, _ := repo.GetOrganizationById(ctx, id)
+	org.Name = "Mutated"
+	org.InvitedUsers = append(org.InvitedUsers, "x@example.com")
+
+	stored, _ := repo.GetOrganizationById(ctx, id)
+	if stored.Name != "Acme" || len(stored.InvitedUsers) != 0 {
+		t.Fatalf("stored organization was mutated through a returned copy: %+v", stored)
+	}
+}
+
+func TestMemoryRateLimitsWindow(t *testing.T) {
+	ctx := context.Background()
+	limits := NewMemoryRateLimits()
+
+	for i := 0; i < 2; i++ {
+		if allowed, _, err := limits.Allow(ctx, "k", 2, time.Minute); err != nil || !allowed {
+			t.Fatalf("request %d: expected it to be allowed, got %v, %v", i+1, allowed, err)
+		}
+	}
+	allowed, retryAfter, err := limits.Allow(ctx, "k", 2, time.Minute)
+	if err != nil || allowed {
+		t.Fatalf("expected the third request to be limited, got %v, %v", allowed, err)
+	}
+	if retryAfter <= 0 || retryAfter > time.Minute {
+		t.Fatalf("expected a retry within the window, got %v", retryAfter)
+	}
+	if allowed, _, _ := limits.Allow(ctx, "other", 2, time.Minute); !allowed {
+		t.Fatal("expected keys to be limited separately")
+	}
+
+	// A new window starts once the previous one has passed.
+	if allowed, _, _ := limits.Allow(ctx, "short", 1, time.Millisecond); !allowed {
+		t.Fatal("expected the first request to be allowed")
+	}
+	time.Sleep(5 * time.Millisecond)
+	if allowed, _, _ := limits.Allow(ctx, "short", 1, time.Millisecond); !allowed {
+		t.Fatal("expected a new window to allow the request")
+	}
+}
@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/organization_api/pkg/database/mongodb/migrations"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// TestMongoConformance runs the conformance suite against a real MongoDB server.
+// It is skipped unless MONGODB_TEST_URI points at a server the tests may write to.
+func TestMongoConformance(t *testing.T) {
+	uri := os.Getenv("MONGODB_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGODB_TEST_URI not set")
+	}
+
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(func() { client.Disconnect(context.Background()) })
+
+	runConformance(t, func(t *testing.T) stores {
+		// Each subtest gets its own throwaway database.
+		db := client.Database(fmt.Sprintf("organization_api_test_%d", time.Now().UnixNano()))
+		t.Cleanup(func() { db.Drop(context.Background()) })
+		if _, err := migrations.New(db).Up(context.Background()); err != nil {
+			t.Fatalf("migrate: %v", err)
+		}
+
+		return stores{
+			organizations: &OrganizationRepo{collection: db.Collection("organization")},
+			users:         &UserRepository{collection: db.Collection("user")},
+			teams:         &TeamRepo{collection: db.Collection("team")},
+			importJobs:    &ImportJobRepo{collection: db.Collection("import_job")},
+			settings:      &SettingsRepo{collection: db.Collection("organization_settings")},
+			domains:       &DomainRepo{collection: db.Collection("domain_claim")},
+			webhooks: &WebhookRepo{
+				collection: db.Collection("webhook"),
+				deliveries: db.Collection("webhook_delivery"),
+			},
+		}
+	})
+}
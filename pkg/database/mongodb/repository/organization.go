@@ -2,10 +2,12 @@ package repository
 
 import (
 	"context"
-	"fmt"
+	"time"
 
+	"github.com/organization_api/pkg/apperror"
 	"github.com/organization_api/pkg/database"
 	"github.com/organization_api/pkg/database/mongodb/models"
+	"github.com/organization_api/pkg/slug"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -15,120 +17,564 @@ import (
 
 type OrganizationRepo struct {
 	collection *mongo.Collection
+	timeouts   operationTimeouts
 }
 
 func NewOrganizationRepo() *OrganizationRepo {
 	// Get the MongoDB collection for organizations
 	db := database.GetDatabase()
-	return &OrganizationRepo{collection: db.Collection("organization")}
+	return &OrganizationRepo{collection: db.Collection("organization"), timeouts: newOperationTimeouts()}
 }
 
-func (repo *OrganizationRepo) GetOrganizationById(organizationID string) (*models.Organization, error) {
+func (repo *OrganizationRepo) GetOrganizationById(ctx context.Context, organizationID string) (*models.Organization, error) {
+	ctx, done := startOperation(ctx, "organization", "GetOrganizationById")
+	defer done()
+
 	// Retrieve organization by ID from MongoDB
 	var org models.Organization
 
-	objectID, err := primitive.ObjectIDFromHex(organizationID)
+	objectID, err := parseID(organizationID, "Organization")
 	if err != nil {
-		return nil, fmt.Errorf("invalid id: %v", err)
+		return nil, err
 	}
 
+	ctx, cancel := repo.timeouts.forRead(ctx)
+	defer cancel()
+
 	filter := bson.M{"_id": objectID}
-	err = repo.collection.FindOne(context.Background(), filter).Decode(&org)
+	err = repo.collection.FindOne(ctx, filter).Decode(&org)
 	if err != nil {
-		return nil, err
+		return nil, translateError(err, "Organization")
 	}
 
 	return &org, nil
 }
 
-func (repo *OrganizationRepo) CreateOrganization(org *models.Organization) (string, error) {
-	// Insert organization data into MongoDB and retrieve the organization ID
-	result, err := repo.collection.InsertOne(context.Background(), org)
+func (repo *OrganizationRepo) CreateOrganization(ctx context.Context, org *models.Organization) (string, error) {
+	ctx, done := startOperation(ctx, "organization", "CreateOrganization")
+	defer done()
+
+	ctx, cancel := repo.timeouts.forWrite(ctx)
+	defer cancel()
+
+	// Insert organization data into MongoDB, moving on to the next slug while the unique index rejects it
+	base := slug.Make(org.Name)
+	for n := 1; n <= slug.MaxAttempts; n++ {
+		org.Slug = slug.Candidate(base, n)
+		org.Slugs = []string{org.Slug}
+
+		result, err := repo.collection.InsertOne(ctx, org)
+		if isSlugConflict(err) {
+			continue
+		}
+		if err != nil {
+			return "", translateError(err, "Organization")
+		}
+
+		orgID := result.InsertedID.(primitive.ObjectID).Hex()
+		return orgID, nil
+	}
+
+	return "", apperror.Conflict("No free slug for %q; choose a more distinctive name", org.Name)
+}
+
+// GetOrganizationBySlug finds the organization that uses or used slug.
+func (repo *OrganizationRepo) GetOrganizationBySlug(ctx context.Context, slug string) (*models.Organization, error) {
+	ctx, done := startOperation(ctx, "organization", "GetOrganizationBySlug")
+	defer done()
+
+	ctx, cancel := repo.timeouts.forRead(ctx)
+	defer cancel()
+
+	var org models.Organization
+	err := repo.collection.FindOne(ctx, bson.M{"slugs": slug}).Decode(&org)
 	if err != nil {
-		return "", err
+		return nil, translateError(err, "Organization")
 	}
 
-	orgID := result.InsertedID.(primitive.ObjectID).Hex()
-	return orgID, nil
+	return &org, nil
 }
 
-func (repo *OrganizationRepo) GetAllOrganizations() ([]*models.Organization, error) {
+func (repo *OrganizationRepo) GetAllOrganizations(ctx context.Context) ([]*models.Organization, error) {
+	ctx, done := startOperation(ctx, "organization", "GetAllOrganizations")
+	defer done()
+
 	// Retrieve all organizations from MongoDB
-	var organizations []*models.Organization
+	return repo.find(ctx, bson.M{})
+}
+
+// FindOrganizations lists the organizations in filter carrying every tag and metadata entry in it.
+// Migration 10 indexes tags and metadata for these queries.
+func (repo *OrganizationRepo) FindOrganizations(ctx context.Context, filter models.OrganizationFilter) ([]*models.Organization, error) {
+	ctx, done := startOperation(ctx, "organization", "FindOrganizations")
+	defer done()
 
-	cursor, err := repo.collection.Find(context.Background(), bson.M{})
+	query := bson.M{}
+	if filter.Ids != nil {
+		query["_id"] = bson.M{"$in": filter.Ids}
+	}
+	if len(filter.Tags) > 0 {
+		query["tags"] = bson.M{"$all": filter.Tags}
+	}
+	for key, value := range filter.Metadata {
+		query["metadata."+key] = value
+	}
+	return repo.find(ctx, query)
+}
+
+// GetChildOrganizations lists the organizations whose parent is parentID.
+func (repo *OrganizationRepo) GetChildOrganizations(ctx context.Context, parentID string) ([]*models.Organization, error) {
+	ctx, done := startOperation(ctx, "organization", "GetChildOrganizations")
+	defer done()
+
+	return repo.find(ctx, bson.M{"parent_id": parentID})
+}
+
+// FindOrganizationsByMember lists the organizations email was invited to. Owners and admins are
+// always invited members, so this covers them too.
+func (repo *OrganizationRepo) FindOrganizationsByMember(ctx context.Context, email string) ([]*models.Organization, error) {
+	ctx, done := startOperation(ctx, "organization", "FindOrganizationsByMember")
+	defer done()
+
+	return repo.find(ctx, bson.M{"invited_users": email})
+}
+
+// SearchOrganizations matches query against the name and description of the organizations in query.Within.
+func (repo *OrganizationRepo) SearchOrganizations(ctx context.Context, query models.SearchQuery) ([]*models.SearchHit, int64, error) {
+	ctx, done := startOperation(ctx, "organization", "SearchOrganizations")
+	defer done()
+
+	if len(query.Within) == 0 {
+		return nil, 0, nil
+	}
+	ids := make([]primitive.ObjectID, 0, len(query.Within))
+	for _, id := range query.Within {
+		objectID, err := parseID(id, "Organization")
+		if err != nil {
+			return nil, 0, err
+		}
+		ids = append(ids, objectID)
+	}
+
+	ctx, cancel := repo.timeouts.forRead(ctx)
+	defer cancel()
+
+	return textSearch(ctx, repo.collection, query.Text, bson.M{"_id": bson.M{"$in": ids}}, query.Limit, "Organization",
+		func(cursor *mongo.Cursor) (*models.SearchHit, error) {
+			var org models.Organization
+			if err := cursor.Decode(&org); err != nil {
+				return nil, err
+			}
+			return &models.SearchHit{Type: models.SearchTypeOrganization, Organization: &org}, nil
+		})
+}
+
+func (repo *OrganizationRepo) UpdateOrganization(ctx context.Context, organizationID string, updateData *models.OrganizationUpdate) (*models.Organization, error) {
+	ctx, done := startOperation(ctx, "organization", "UpdateOrganization")
+	defer done()
+
+	objectID, err := parseID(organizationID, "Organization")
 	if err != nil {
 		return nil, err
 	}
-	defer cursor.Close(context.Background())
 
-	for cursor.Next(context.Background()) {
-		var org models.Organization
-		err := cursor.Decode(&org)
-		if err != nil {
-			return nil, err
+	ctx, cancel := repo.timeouts.forWrite(ctx)
+	defer cancel()
+
+	// Keep the slug while the new name has the same base as the old one; otherwise derive a new one.
+	// Comparing bases, not just the slug, re-slugs "Acme 2024" renamed to "Acme" instead of keeping acme-2024.
+	var current models.Organization
+	if err := repo.collection.FindOne(ctx, bson.M{"_id": objectID}).Decode(&current); err != nil {
+		return nil, translateError(err, "Organization")
+	}
+	base := slug.Make(updateData.Name)
+	if keepsSlug(&current, base) {
+		return repo.applyUpdate(ctx, objectID, bson.M{"$set": bson.M{
+			"name":        updateData.Name,
+			"description": updateData.Description,
+		}})
+	}
+
+	// The previous slug stays in slugs, so links to it keep working.
+	for n := 1; n <= slug.MaxAttempts; n++ {
+		candidate := slug.Candidate(base, n)
+		updated, err := repo.applyUpdate(ctx, objectID, bson.M{
+			"$set": bson.M{
+				"name":        updateData.Name,
+				"description": updateData.Description,
+				"slug":        candidate,
+			},
+			"$addToSet": bson.M{"slugs": candidate},
+		})
+		if isSlugConflict(err) {
+			continue
 		}
-		organizations = append(organizations, &org)
+		return updated, err
 	}
 
-	return organizations, nil
+	return nil, apperror.Conflict("No free slug for %q; choose a more distinctive name", updateData.Name)
 }
 
-func (repo *OrganizationRepo) UpdateOrganization(organizationID string, updateData *models.OrganizationUpdate) (*models.Organization, error) {
-	// Update organization details in MongoDB
-	var updatedOrganization models.Organization
+// keepsSlug reports whether a rename to a name with the given base leaves the organization's slug current.
+func keepsSlug(org *models.Organization, base string) bool {
+	return org.Slug != "" && slug.Make(org.Name) == base && slug.DerivedFrom(org.Slug, base)
+}
+
+// SetMetadata replaces the metadata, leaving the rest of the organization as it is.
+func (repo *OrganizationRepo) SetMetadata(ctx context.Context, organizationID string, metadata map[string]string) (*models.Organization, error) {
+	ctx, done := startOperation(ctx, "organization", "SetMetadata")
+	defer done()
 
-	objectID, err := primitive.ObjectIDFromHex(organizationID)
+	objectID, err := parseID(organizationID, "Organization")
 	if err != nil {
-		return nil, fmt.Errorf("invalid id: %v", err)
+		return nil, err
 	}
 
-	filter := bson.M{"_id": objectID}
-	update := bson.M{"$set": bson.M{
-		"name":        updateData.Name,
-		"description": updateData.Description,
-	}}
+	ctx, cancel := repo.timeouts.forWrite(ctx)
+	defer cancel()
+
+	update := bson.M{"$set": bson.M{"metadata": metadata}}
+	if len(metadata) == 0 {
+		update = bson.M{"$unset": bson.M{"metadata": ""}}
+	}
+	return repo.applyUpdate(ctx, objectID, update)
+}
+
+// SetTags replaces the tags, leaving the rest of the organization as it is.
+func (repo *OrganizationRepo) SetTags(ctx context.Context, organizationID string, tags []string) (*models.Organization, error) {
+	ctx, done := startOperation(ctx, "organization", "SetTags")
+	defer done()
+
+	objectID, err := parseID(organizationID, "Organization")
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := repo.timeouts.forWrite(ctx)
+	defer cancel()
+
+	update := bson.M{"$set": bson.M{"tags": tags}}
+	if len(tags) == 0 {
+		update = bson.M{"$unset": bson.M{"tags": ""}}
+	}
+	return repo.applyUpdate(ctx, objectID, update)
+}
+
+func (repo *OrganizationRepo) applyUpdate(ctx context.Context, objectID primitive.ObjectID, update bson.M) (*models.Organization, error) {
+	// Update organization details in MongoDB
+	var updatedOrganization models.Organization
 
 	// Set the ReturnDocument option to After to get the updated document
 	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
 
-	err = repo.collection.FindOneAndUpdate(context.Background(), filter, update, opts).Decode(&updatedOrganization)
-	if err != nil {
+	err := repo.collection.FindOneAndUpdate(ctx, bson.M{"_id": objectID}, update, opts).Decode(&updatedOrganization)
+	if isSlugConflict(err) {
+		// Leave slug conflicts untranslated so the caller can try the next candidate.
 		return nil, err
 	}
+	if err != nil {
+		return nil, translateError(err, "Organization")
+	}
 
 	return &updatedOrganization, nil
 }
 
-func (repo *OrganizationRepo) DeleteOrganization(organizationID string) error {
+func (repo *OrganizationRepo) DeleteOrganization(ctx context.Context, organizationID string) error {
+	ctx, done := startOperation(ctx, "organization", "DeleteOrganization")
+	defer done()
+
 	// Delete organization from MongoDB
-	objectID, err := primitive.ObjectIDFromHex(organizationID)
+	objectID, err := parseID(organizationID, "Organization")
 	if err != nil {
-		return fmt.Errorf("invalid id: %v", err)
+		return err
 	}
+
+	ctx, cancel := repo.timeouts.forWrite(ctx)
+	defer cancel()
+
 	filter := bson.M{"_id": objectID}
-	_, err = repo.collection.DeleteOne(context.Background(), filter)
+	result, err := repo.collection.DeleteOne(ctx, filter)
 	if err != nil {
-		return err
+		return translateError(err, "Organization")
+	}
+	if result.DeletedCount == 0 {
+		return translateError(mongo.ErrNoDocuments, "Organization")
 	}
 
 	return nil
 }
 
-func (repo *OrganizationRepo) InviteUserToOrganization(organizationID, userEmail string) error {
+func (repo *OrganizationRepo) InviteUserToOrganization(ctx context.Context, organizationID, userEmail string) error {
+	ctx, done := startOperation(ctx, "organization", "InviteUserToOrganization")
+	defer done()
+
 	// Invite a user to an organization in MongoDB
-	objectID, err := primitive.ObjectIDFromHex(organizationID)
+	objectID, err := parseID(organizationID, "Organization")
 	if err != nil {
-		return fmt.Errorf("invalid id: %v", err)
+		return err
 	}
 
+	ctx, cancel := repo.timeouts.forWrite(ctx)
+	defer cancel()
+
 	filter := bson.M{"_id": objectID}
 	update := bson.M{"$addToSet": bson.M{"invited_users": userEmail}}
 
-	_, err = repo.collection.UpdateOne(context.Background(), filter, update)
+	result, err := repo.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return translateError(err, "Organization")
+	}
+	if result.MatchedCount == 0 {
+		return translateError(mongo.ErrNoDocuments, "Organization")
+	}
+
+	return nil
+}
+
+// InviteUsersToOrganization adds every email in one pipeline update and compares the result with
+// the members the update found, so concurrent invitations are never reported twice. Admin rights and
+// pending invitations are given only to the emails the update added.
+func (repo *OrganizationRepo) InviteUsersToOrganization(ctx context.Context, organizationID string, emails []string, opts models.InviteOptions) ([]string, error) {
+	ctx, done := startOperation(ctx, "organization", "InviteUsersToOrganization")
+	defer done()
+
+	objectID, err := parseID(organizationID, "Organization")
+	if err != nil {
+		return nil, err
+	}
+	emails = newMembers(nil, emails)
+
+	ctx, cancel := repo.timeouts.forWrite(ctx)
+	defer cancel()
+
+	// Every expression in a $set stage sees the document as it was, so added is computed once
+	// against the members before the update.
+	members := bson.M{"$ifNull": bson.A{"$invited_users", bson.A{}}}
+	notMember := func(value string) bson.M {
+		return bson.M{"$not": bson.A{bson.M{"$in": bson.A{value, members}}}}
+	}
+	added := bson.M{"$filter": bson.M{"input": bson.M{"$literal": emails}, "cond": notMember("$$this")}}
+	set := bson.D{{Key: "invited_users", Value: bson.M{"$concatArrays": bson.A{members, added}}}}
+	if opts.Admin {
+		set = append(set, bson.E{Key: "admins", Value: bson.M{"$concatArrays": bson.A{
+			bson.M{"$ifNull": bson.A{"$admins", bson.A{}}}, added,
+		}}})
+	}
+	if len(opts.Pending) > 0 {
+		pending := bson.M{"$filter": bson.M{"input": bson.M{"$literal": opts.Pending}, "cond": notMember("$$this.email")}}
+		set = append(set, bson.E{Key: "invitations", Value: bson.M{"$concatArrays": bson.A{
+			bson.M{"$ifNull": bson.A{"$invitations", bson.A{}}}, pending,
+		}}})
+	}
+
+	filter := bson.M{"_id": objectID}
+	update := mongo.Pipeline{{{Key: "$set", Value: set}}}
+	findOpts := options.FindOneAndUpdate().
+		SetReturnDocument(options.Before).
+		SetProjection(bson.M{"invited_users": 1})
+
+	var before models.Organization
+	err = repo.collection.FindOneAndUpdate(ctx, filter, update, findOpts).Decode(&before)
+	if err != nil {
+		return nil, translateError(err, "Organization")
+	}
+
+	return newMembers(before.InvitedUsers, emails), nil
+}
+
+// AcceptInvitations settles the pending invitations of someone who just got an account. Those still
+// open make them a member for good; lapsed ones take the membership and any admin rights with them.
+func (repo *OrganizationRepo) AcceptInvitations(ctx context.Context, email string) error {
+	ctx, done := startOperation(ctx, "organization", "AcceptInvitations")
+	defer done()
+
+	ctx, cancel := repo.timeouts.forWrite(ctx)
+	defer cancel()
+
+	lapsed := bson.M{
+		"invitations": bson.M{"$elemMatch": bson.M{"email": email, "expires_at": bson.M{"$lte": time.Now().UTC()}}},
+		"owner":       bson.M{"$ne": email},
+	}
+	_, err := repo.collection.UpdateMany(ctx, lapsed, bson.M{"$pull": bson.M{
+		"invitations":   bson.M{"email": email},
+		"invited_users": email,
+		"admins":        email,
+	}})
+	if err != nil {
+		return translateError(err, "Organization")
+	}
+
+	_, err = repo.collection.UpdateMany(ctx, bson.M{"invitations.email": email},
+		bson.M{"$pull": bson.M{"invitations": bson.M{"email": email}}})
+	return translateError(err, "Organization")
+}
+
+// RemoveMember takes email off the organization's members, admins and pending invitations.
+func (repo *OrganizationRepo) RemoveMember(ctx context.Context, organizationID, email string) error {
+	ctx, done := startOperation(ctx, "organization", "RemoveMember")
+	defer done()
+
+	objectID, err := parseID(organizationID, "Organization")
 	if err != nil {
 		return err
 	}
 
+	ctx, cancel := repo.timeouts.forWrite(ctx)
+	defer cancel()
+
+	// Match on membership and on someone else owning the organization in the same update, so a
+	// concurrent transfer cannot leave it without its owner.
+	filter := bson.M{"_id": objectID, "invited_users": email, "owner": bson.M{"$ne": email}}
+	result, err := repo.collection.UpdateOne(ctx, filter, bson.M{"$pull": bson.M{
+		"invited_users": email,
+		"admins":        email,
+		"invitations":   bson.M{"email": email},
+	}})
+	if err != nil {
+		return translateError(err, "Organization")
+	}
+	if result.MatchedCount > 0 {
+		return nil
+	}
+
+	// Tell a missing organization apart from its owner and from someone who is not a member.
+	var org models.Organization
+	if err := repo.collection.FindOne(ctx, bson.M{"_id": objectID}).Decode(&org); err != nil {
+		return translateError(err, "Organization")
+	}
+	if org.Owner == email {
+		return apperror.Conflict("%s owns the organization; transfer ownership first", email)
+	}
+	return apperror.NotFound("Member not found")
+}
+
+// newMembers returns the emails, each once, that are not among members.
+func newMembers(members, emails []string) []string {
+	seen := make(map[string]bool, len(members)+len(emails))
+	for _, member := range members {
+		seen[member] = true
+	}
+
+	var added []string
+	for _, email := range emails {
+		if !seen[email] {
+			seen[email] = true
+			added = append(added, email)
+		}
+	}
+	return added
+}
+
+// TransferOwnership makes an existing member the owner of an organization, demotes the previous
+// owner to admin and appends the change to the ownership history, all in a single update.
+func (repo *OrganizationRepo) TransferOwnership(ctx context.Context, organizationID, currentOwner, newOwner string) error {
+	ctx, done := startOperation(ctx, "organization", "TransferOwnership")
+	defer done()
+
+	objectID, err := parseID(organizationID, "Organization")
+	if err != nil {
+		return err
+	}
+	if currentOwner == newOwner {
+		return apperror.Conflict("%s already owns the organization", newOwner)
+	}
+
+	ctx, cancel := repo.timeouts.forWrite(ctx)
+	defer cancel()
+
+	// Match on the current owner and on membership in the same update so a concurrent transfer
+	// or removal cannot slip in between. Organizations created before ownership have no owner.
+	filter := bson.M{"_id": objectID, "invited_users": newOwner}
+	if currentOwner == "" {
+		filter["owner"] = bson.M{"$exists": false}
+	} else {
+		filter["owner"] = currentOwner
+	}
+
+	// The new owner no longer needs to be listed as an admin; the previous one becomes one.
+	admins := bson.M{"$setDifference": bson.A{bson.M{"$ifNull": bson.A{"$admins", bson.A{}}}, bson.A{bson.M{"$literal": newOwner}}}}
+	if currentOwner != "" {
+		admins = bson.M{"$setUnion": bson.A{admins, bson.A{bson.M{"$literal": currentOwner}}}}
+	}
+	transfer := models.OwnershipTransfer{From: currentOwner, To: newOwner, At: time.Now().UTC()}
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.D{
+		{Key: "owner", Value: bson.M{"$literal": newOwner}},
+		{Key: "admins", Value: admins},
+		{Key: "ownership_transfers", Value: bson.M{"$concatArrays": bson.A{
+			bson.M{"$ifNull": bson.A{"$ownership_transfers", bson.A{}}},
+			bson.A{bson.M{"$literal": transfer}},
+		}}},
+	}}}}
+
+	result, err := repo.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return translateError(err, "Organization")
+	}
+	if result.MatchedCount > 0 {
+		return nil
+	}
+
+	// Tell a missing organization apart from a stale owner or a new owner who is not a member.
+	var org models.Organization
+	if err := repo.collection.FindOne(ctx, bson.M{"_id": objectID}).Decode(&org); err != nil {
+		return translateError(err, "Organization")
+	}
+	if org.Owner != currentOwner {
+		return apperror.Conflict("Ownership of the organization has changed")
+	}
+	return apperror.Conflict("%s is not a member of the organization", newOwner)
+}
+
+// SetParent moves an organization under parentID, or to the top level when parentID is empty.
+// Callers are responsible for rejecting cycles.
+func (repo *OrganizationRepo) SetParent(ctx context.Context, organizationID, parentID string) error {
+	ctx, done := startOperation(ctx, "organization", "SetParent")
+	defer done()
+
+	objectID, err := parseID(organizationID, "Organization")
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := repo.timeouts.forWrite(ctx)
+	defer cancel()
+
+	update := bson.M{"$set": bson.M{"parent_id": parentID}}
+	if parentID == "" {
+		update = bson.M{"$unset": bson.M{"parent_id": ""}}
+	}
+
+	result, err := repo.collection.UpdateOne(ctx, bson.M{"_id": objectID}, update)
+	if err != nil {
+		return translateError(err, "Organization")
+	}
+	if result.MatchedCount == 0 {
+		return translateError(mongo.ErrNoDocuments, "Organization")
+	}
+
 	return nil
 }
+
+func (repo *OrganizationRepo) find(ctx context.Context, filter bson.M) ([]*models.Organization, error) {
+	var organizations []*models.Organization
+
+	ctx, cancel := repo.timeouts.forRead(ctx)
+	defer cancel()
+
+	cursor, err := repo.collection.Find(ctx, filter)
+	if err != nil {
+		return nil, translateError(err, "Organization")
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var org models.Organization
+		err := cursor.Decode(&org)
+		if err != nil {
+			return nil, err
+		}
+		organizations = append(organizations, &org)
+	}
+
+	return organizations, translateError(cursor.Err(), "Organization")
+}
@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// rateLimitKeyPrefix namespaces rate limit counters in Redis.
+const rateLimitKeyPrefix = "rate_limit:"
+
+// RedisRateLimits counts requests in Redis, shared by every replica.
+type RedisRateLimits struct {
+	client *redis.Client
+}
+
+// NewRedisRateLimits initializes a RedisRateLimits on top of client.
+func NewRedisRateLimits(client *redis.Client) *RedisRateLimits {
+	return &RedisRateLimits{client: client}
+}
+
+// Allow increments the counter for key, which expires when its window ends.
+func (repo *RedisRateLimits) Allow(ctx context.Context, key string, limit int, window time.Duration) (bool, time.Duration, error) {
+	key = rateLimitKeyPrefix + key
+
+	pipe := repo.client.WithContext(ctx).TxPipeline()
+	count := pipe.Incr(key)
+	ttl := pipe.PTTL(key)
+	if _, err := pipe.Exec(); err != nil {
+		return false, 0, err
+	}
+
+	// The first request opens the window. A counter left without an expiry, because the process
+	// died between the two commands, is given one by the next request.
+	remaining := ttl.Val()
+	if remaining < 0 {
+		if err := repo.client.WithContext(ctx).PExpire(key, window).Err(); err != nil {
+			return false, 0, err
+		}
+		remaining = window
+	}
+
+	if count.Val() > int64(limit) {
+		return false, remaining, nil
+	}
+	return true, 0, nil
+}
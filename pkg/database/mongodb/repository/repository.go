@@ -0,0 +1,161 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/organization_api/pkg/database/mongodb/models"
+)
+
+// OrganizationStore is the persistence contract for organizations.
+type OrganizationStore interface {
+	GetOrganizationById(ctx context.Context, organizationID string) (*models.Organization, error)
+	// GetOrganizationBySlug finds the organization that uses or used slug; compare it with the
+	// organization's current Slug to tell the two apart.
+	GetOrganizationBySlug(ctx context.Context, slug string) (*models.Organization, error)
+	// CreateOrganization assigns the organization a unique slug derived from its name.
+	CreateOrganization(ctx context.Context, org *models.Organization) (string, error)
+	GetAllOrganizations(ctx context.Context) ([]*models.Organization, error)
+	// FindOrganizations lists the organizations matching filter, whose metadata keys must already be valid.
+	FindOrganizations(ctx context.Context, filter models.OrganizationFilter) ([]*models.Organization, error)
+	GetChildOrganizations(ctx context.Context, parentID string) ([]*models.Organization, error)
+	// FindOrganizationsByMember lists the organizations email was invited to, owners and admins included.
+	FindOrganizationsByMember(ctx context.Context, email string) ([]*models.Organization, error)
+	// SearchOrganizations ranks the organizations in query.Within by how well their name and
+	// description match, and returns the best query.Limit of them with the number of matches.
+	SearchOrganizations(ctx context.Context, query models.SearchQuery) ([]*models.SearchHit, int64, error)
+	// UpdateOrganization gives a renamed organization a new slug and keeps the old one as an alias.
+	UpdateOrganization(ctx context.Context, organizationID string, updateData *models.OrganizationUpdate) (*models.Organization, error)
+	// SetMetadata replaces the metadata of the organization; an empty map removes it.
+	SetMetadata(ctx context.Context, organizationID string, metadata map[string]string) (*models.Organization, error)
+	// SetTags replaces the tags of the organization; an empty list removes them.
+	SetTags(ctx context.Context, organizationID string, tags []string) (*models.Organization, error)
+	DeleteOrganization(ctx context.Context, organizationID string) error
+	InviteUserToOrganization(ctx context.Context, organizationID, userEmail string) error
+	// InviteUsersToOrganization invites every email in a single update and returns the ones that
+	// were not already members, in the order given. opts apply only to those.
+	InviteUsersToOrganization(ctx context.Context, organizationID string, emails []string, opts models.InviteOptions) ([]string, error)
+	// AcceptInvitations settles the pending invitations of email once they have an account: open ones
+	// become memberships and lapsed ones are withdrawn.
+	AcceptInvitations(ctx context.Context, email string) error
+	// RemoveMember takes email off the organization's members, admins and pending invitations in one
+	// update. The owner cannot be removed; that fails with a conflict.
+	RemoveMember(ctx context.Context, organizationID, email string) error
+	// TransferOwnership hands the organization from currentOwner to newOwner, an existing member, and
+	// demotes currentOwner to admin in one update. It fails with a conflict if the owner changed meanwhile.
+	TransferOwnership(ctx context.Context, organizationID, currentOwner, newOwner string) error
+	// SetParent moves the organization under parentID, or to the top level when parentID is empty.
+	SetParent(ctx context.Context, organizationID, parentID string) error
+}
+
+// UserStore is the persistence contract for users.
+type UserStore interface {
+	FindUserByEmail(ctx context.Context, email string) (*models.User, error)
+	// FindRegisteredEmails returns the emails, of those given, that belong to a user.
+	FindRegisteredEmails(ctx context.Context, emails []string) ([]string, error)
+	CreateUser(ctx context.Context, user *models.User) (*models.User, error)
+	SetUserDisabled(ctx context.Context, email string, disabled bool) error
+	// SetEmailVerified records that the user confirmed they receive mail at their email address.
+	SetEmailVerified(ctx context.Context, email string) error
+	UpdatePassword(ctx context.Context, email, passwordHash string) error
+	// SearchUsers ranks the users whose emails are in query.Within by how well their name and email
+	// match, and returns the best query.Limit of them with the number of matches.
+	SearchUsers(ctx context.Context, query models.SearchQuery) ([]*models.SearchHit, int64, error)
+}
+
+// TokenRevocationStore records when a user's tokens were last revoked.
+// Tokens issued at or before that moment are no longer accepted.
+type TokenRevocationStore interface {
+	// RevokeTokens revokes every token issued to email up to at; the record may be dropped after ttl,
+	// once every token it covers has expired anyway.
+	RevokeTokens(ctx context.Context, email string, at time.Time, ttl time.Duration) error
+	// RevokedAt returns when the user's tokens were last revoked, or the zero time if they never were.
+	RevokedAt(ctx context.Context, email string) (time.Time, error)
+}
+
+// RateLimitStore counts requests in fixed windows shared by every replica.
+type RateLimitStore interface {
+	// Allow counts a request under key and reports whether it is within limit for the current
+	// window. When it is not, retryAfter is how long until the window ends.
+	Allow(ctx context.Context, key string, limit int, window time.Duration) (allowed bool, retryAfter time.Duration, err error)
+}
+
+// TeamStore is the persistence contract for the teams of organizations.
+// Every method is scoped to an organization; a team of another organization is not found.
+type TeamStore interface {
+	CreateTeam(ctx context.Context, team *models.Team) (string, error)
+	GetTeamsByOrganization(ctx context.Context, organizationID string) ([]*models.Team, error)
+	FindTeamsByMember(ctx context.Context, organizationID, email string) ([]*models.Team, error)
+	GetTeamById(ctx context.Context, organizationID, teamID string) (*models.Team, error)
+	RenameTeam(ctx context.Context, organizationID, teamID, name string) (*models.Team, error)
+	SetTeamPermissions(ctx context.Context, organizationID, teamID string, permissions []string) (*models.Team, error)
+	SetTeamMember(ctx context.Context, organizationID, teamID string, member models.TeamMember) (*models.Team, error)
+	RemoveTeamMember(ctx context.Context, organizationID, teamID, email string) (*models.Team, error)
+	// RemoveMemberFromTeams takes email off every team of the organization.
+	RemoveMemberFromTeams(ctx context.Context, organizationID, email string) error
+	DeleteTeam(ctx context.Context, organizationID, teamID string) error
+	// DeleteTeams deletes every team of the organization.
+	DeleteTeams(ctx context.Context, organizationID string) error
+}
+
+// ImportJobStore is the persistence contract for imports running in the background.
+type ImportJobStore interface {
+	CreateImportJob(ctx context.Context, job *models.ImportJob) (string, error)
+	// GetImportJob returns a job started by owner; jobs of other callers are not found.
+	GetImportJob(ctx context.Context, owner, jobID string) (*models.ImportJob, error)
+	// UpdateImportJob saves the status and report of a job.
+	UpdateImportJob(ctx context.Context, job *models.ImportJob) error
+}
+
+// SettingsStore is the persistence contract for the settings of organizations. Settings are always
+// returned upgraded to the current schema, with defaults for anything the organization has not set.
+type SettingsStore interface {
+	// GetSettings returns the defaults for an organization that never changed its settings.
+	GetSettings(ctx context.Context, organizationID string) (*models.OrganizationSettings, error)
+	// UpdateSettings applies patch to the organization's settings and returns the result.
+	UpdateSettings(ctx context.Context, organizationID string, patch models.SettingsPatch, updatedBy string) (*models.OrganizationSettings, error)
+	DeleteSettings(ctx context.Context, organizationID string) error
+}
+
+// DomainStore is the persistence contract for the email domains organizations claim. Domains are
+// stored normalized; an organization claims a domain once, and only one organization can verify it.
+type DomainStore interface {
+	// CreateDomainClaim fails with a conflict when the organization already claimed the domain.
+	CreateDomainClaim(ctx context.Context, claim *models.DomainClaim) (string, error)
+	GetDomainClaims(ctx context.Context, organizationID string) ([]*models.DomainClaim, error)
+	GetDomainClaim(ctx context.Context, organizationID, domain string) (*models.DomainClaim, error)
+	// VerifyDomainClaim fails with a conflict when another organization verified the domain first.
+	VerifyDomainClaim(ctx context.Context, organizationID, domain string, at time.Time) (*models.DomainClaim, error)
+	DeleteDomainClaim(ctx context.Context, organizationID, domain string) error
+	DeleteDomainClaims(ctx context.Context, organizationID string) error
+	// FindVerifiedDomainClaim returns the verified claim to a domain, or a not found error.
+	FindVerifiedDomainClaim(ctx context.Context, domain string) (*models.DomainClaim, error)
+}
+
+// WebhookStore is the persistence contract for webhook subscriptions and deliveries.
+type WebhookStore interface {
+	CreateWebhook(ctx context.Context, hook *models.Webhook) (string, error)
+	GetWebhooksByOrganization(ctx context.Context, organizationID string) ([]*models.Webhook, error)
+	GetWebhookById(ctx context.Context, organizationID, webhookID string) (*models.Webhook, error)
+	DeleteWebhook(ctx context.Context, organizationID, webhookID string) error
+	// DeleteWebhooks deletes every webhook of the organization and their delivery logs.
+	DeleteWebhooks(ctx context.Context, organizationID string) error
+	FindWebhooksForEvent(ctx context.Context, organizationID, event string) ([]*models.Webhook, error)
+	// SaveDelivery records a delivery; one whose webhook was deleted meanwhile is dropped.
+	SaveDelivery(ctx context.Context, delivery *models.WebhookDelivery) error
+	GetDeliveriesByWebhook(ctx context.Context, webhookID string) ([]*models.WebhookDelivery, error)
+}
+
+// Compile-time checks that the MongoDB repositories satisfy the contracts.
+var (
+	_ OrganizationStore = (*OrganizationRepo)(nil)
+	_ UserStore         = (*UserRepository)(nil)
+	_ TeamStore         = (*TeamRepo)(nil)
+	_ ImportJobStore    = (*ImportJobRepo)(nil)
+	_ SettingsStore     = (*SettingsRepo)(nil)
+	_ DomainStore       = (*DomainRepo)(nil)
+	_ WebhookStore      = (*WebhookRepo)(nil)
+
+	_ TokenRevocationStore = (*RedisTokenRevocations)(nil)
+	_ RateLimitStore       = (*RedisRateLimits)(nil)
+)
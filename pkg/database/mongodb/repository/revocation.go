@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// revocationKeyPrefix namespaces revocation records in Redis.
+const revocationKeyPrefix = "token_revoked:"
+
+// legacySecondsLimit separates records stored as Unix seconds, before millisecond precision,
+// from millisecond records: no millisecond timestamp since 1973 is below it.
+const legacySecondsLimit = 100_000_000_000
+
+// RedisTokenRevocations keeps token revocations in Redis, shared by every replica.
+type RedisTokenRevocations struct {
+	client *redis.Client
+}
+
+// NewRedisTokenRevocations initializes a RedisTokenRevocations on top of client.
+func NewRedisTokenRevocations(client *redis.Client) *RedisTokenRevocations {
+	return &RedisTokenRevocations{client: client}
+}
+
+// RevokeTokens stores the revocation time as Unix milliseconds, expiring it after ttl.
+func (repo *RedisTokenRevocations) RevokeTokens(ctx context.Context, email string, at time.Time, ttl time.Duration) error {
+	value := strconv.FormatInt(at.UnixMilli(), 10)
+	return repo.client.WithContext(ctx).Set(revocationKeyPrefix+email, value, ttl).Err()
+}
+
+// RevokedAt returns the stored revocation time, or the zero time when there is none.
+func (repo *RedisTokenRevocations) RevokedAt(ctx context.Context, email string) (time.Time, error) {
+	value, err := repo.client.WithContext(ctx).Get(revocationKeyPrefix + email).Result()
+	if errors.Is(err, redis.Nil) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	stamp, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if stamp < legacySecondsLimit {
+		return time.Unix(stamp, 0), nil
+	}
+	return time.UnixMilli(stamp), nil
+}
@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/organization_api/pkg/database/mongodb/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// textSearch matches text against the text index of collection among the documents selected by
+// filter. It returns the best limit matches, best first, each turned into a hit by decode, and the
+// number of matches.
+func textSearch(ctx context.Context, collection *mongo.Collection, text string, filter bson.M, limit int, resource string,
+	decode func(cursor *mongo.Cursor) (*models.SearchHit, error)) ([]*models.SearchHit, int64, error) {
+	filter["$text"] = bson.M{"$search": text}
+
+	total, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, translateError(err, resource)
+	}
+	if total == 0 || limit <= 0 {
+		return nil, total, nil
+	}
+
+	score := bson.M{"$meta": "textScore"}
+	cursor, err := collection.Find(ctx, filter, options.Find().
+		SetProjection(bson.M{"score": score}).
+		SetSort(bson.D{{Key: "score", Value: score}, {Key: "_id", Value: 1}}).
+		SetLimit(int64(limit)))
+	if err != nil {
+		return nil, 0, translateError(err, resource)
+	}
+	defer cursor.Close(ctx)
+
+	var hits []*models.SearchHit
+	for cursor.Next(ctx) {
+		hit, err := decode(cursor)
+		if err != nil {
+			return nil, 0, err
+		}
+		hit.Score = cursor.Current.Lookup("score").Double()
+		hits = append(hits, hit)
+	}
+
+	return hits, total, translateError(cursor.Err(), resource)
+}
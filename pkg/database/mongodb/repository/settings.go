@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/organization_api/pkg/database"
+	"github.com/organization_api/pkg/database/mongodb/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// SettingsRepo stores one settings document per organization, keyed by the organization's ID.
+type SettingsRepo struct {
+	collection *mongo.Collection
+	timeouts   operationTimeouts
+}
+
+// NewSettingsRepo initializes a new SettingsRepo instance.
+func NewSettingsRepo() *SettingsRepo {
+	// Get the MongoDB collection for organization settings.
+	return &SettingsRepo{
+		collection: database.GetDatabase().Collection("organization_settings"),
+		timeouts:   newOperationTimeouts(),
+	}
+}
+
+// GetSettings reads the stored settings, or the defaults when there are none.
+func (repo *SettingsRepo) GetSettings(ctx context.Context, organizationID string) (*models.OrganizationSettings, error) {
+	ctx, done := startOperation(ctx, "organization_settings", "GetSettings")
+	defer done()
+
+	if _, err := parseID(organizationID, "Organization"); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := repo.timeouts.forRead(ctx)
+	defer cancel()
+
+	var settings models.OrganizationSettings
+	err := repo.collection.FindOne(ctx, bson.M{"_id": organizationID}).Decode(&settings)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return models.DefaultSettings(organizationID), nil
+	}
+	if err != nil {
+		return nil, translateError(err, "Settings")
+	}
+
+	return settings.WithDefaults(), nil
+}
+
+// UpdateSettings sets only the fields in patch, creating the document on the first change, so
+// admins changing different settings at once do not undo each other.
+func (repo *SettingsRepo) UpdateSettings(ctx context.Context, organizationID string, patch models.SettingsPatch, updatedBy string) (*models.OrganizationSettings, error) {
+	ctx, done := startOperation(ctx, "organization_settings", "UpdateSettings")
+	defer done()
+
+	if _, err := parseID(organizationID, "Organization"); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := repo.timeouts.forWrite(ctx)
+	defer cancel()
+
+	set := bson.M{
+		"schema_version": models.SettingsSchemaVersion,
+		"updated_at":     time.Now().UTC(),
+		"updated_by":     updatedBy,
+	}
+	if patch.AllowedDomains != nil {
+		set["allowed_domains"] = *patch.AllowedDomains
+	}
+	if patch.DefaultRole != nil {
+		set["default_role"] = *patch.DefaultRole
+	}
+	if patch.InvitationExpiryDays != nil {
+		set["invitation_expiry_days"] = *patch.InvitationExpiryDays
+	}
+	if patch.RequireMFA != nil {
+		set["require_mfa"] = *patch.RequireMFA
+	}
+	if patch.DomainJoin != nil {
+		set["domain_join"] = *patch.DomainJoin
+	}
+
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	var settings models.OrganizationSettings
+	err := repo.collection.FindOneAndUpdate(ctx, bson.M{"_id": organizationID}, bson.M{"$set": set}, opts).Decode(&settings)
+	if err != nil {
+		return nil, translateError(err, "Settings")
+	}
+
+	return settings.WithDefaults(), nil
+}
+
+// DeleteSettings removes the settings of a deleted organization. Missing settings are not an error.
+func (repo *SettingsRepo) DeleteSettings(ctx context.Context, organizationID string) error {
+	ctx, done := startOperation(ctx, "organization_settings", "DeleteSettings")
+	defer done()
+
+	ctx, cancel := repo.timeouts.forWrite(ctx)
+	defer cancel()
+
+	_, err := repo.collection.DeleteOne(ctx, bson.M{"_id": organizationID})
+	return translateError(err, "Settings")
+}
@@ -0,0 +1,247 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/organization_api/pkg/apperror"
+	"github.com/organization_api/pkg/database"
+	"github.com/organization_api/pkg/database/mongodb/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// TeamRepo stores the teams of organizations.
+type TeamRepo struct {
+	collection *mongo.Collection
+	timeouts   operationTimeouts
+}
+
+// NewTeamRepo initializes a new TeamRepo instance.
+func NewTeamRepo() *TeamRepo {
+	// Get the MongoDB collection for teams.
+	return &TeamRepo{
+		collection: database.GetDatabase().Collection("team"),
+		timeouts:   newOperationTimeouts(),
+	}
+}
+
+// CreateTeam inserts a new team and returns its ID. Team names are unique within an organization.
+func (repo *TeamRepo) CreateTeam(ctx context.Context, team *models.Team) (string, error) {
+	ctx, done := startOperation(ctx, "team", "CreateTeam")
+	defer done()
+
+	ctx, cancel := repo.timeouts.forWrite(ctx)
+	defer cancel()
+
+	result, err := repo.collection.InsertOne(ctx, team)
+	if err != nil {
+		return "", translateError(err, "Team")
+	}
+
+	team.Id = result.InsertedID.(primitive.ObjectID)
+	return team.Id.Hex(), nil
+}
+
+// GetTeamsByOrganization lists the teams of an organization.
+func (repo *TeamRepo) GetTeamsByOrganization(ctx context.Context, organizationID string) ([]*models.Team, error) {
+	ctx, done := startOperation(ctx, "team", "GetTeamsByOrganization")
+	defer done()
+
+	return repo.find(ctx, bson.M{"organization_id": organizationID})
+}
+
+// FindTeamsByMember lists the teams of an organization that email belongs to.
+func (repo *TeamRepo) FindTeamsByMember(ctx context.Context, organizationID, email string) ([]*models.Team, error) {
+	ctx, done := startOperation(ctx, "team", "FindTeamsByMember")
+	defer done()
+
+	return repo.find(ctx, bson.M{"organization_id": organizationID, "members.email": email})
+}
+
+// GetTeamById retrieves a single team belonging to an organization.
+func (repo *TeamRepo) GetTeamById(ctx context.Context, organizationID, teamID string) (*models.Team, error) {
+	ctx, done := startOperation(ctx, "team", "GetTeamById")
+	defer done()
+
+	objectID, err := parseID(teamID, "Team")
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := repo.timeouts.forRead(ctx)
+	defer cancel()
+
+	var team models.Team
+	filter := bson.M{"_id": objectID, "organization_id": organizationID}
+	if err := repo.collection.FindOne(ctx, filter).Decode(&team); err != nil {
+		return nil, translateError(err, "Team")
+	}
+
+	return &team, nil
+}
+
+// RenameTeam changes the name of a team and returns the updated team.
+func (repo *TeamRepo) RenameTeam(ctx context.Context, organizationID, teamID, name string) (*models.Team, error) {
+	ctx, done := startOperation(ctx, "team", "RenameTeam")
+	defer done()
+
+	return repo.update(ctx, organizationID, teamID, bson.M{"$set": bson.M{"name": name}})
+}
+
+// SetTeamPermissions replaces the permissions granted to a team and returns the updated team.
+func (repo *TeamRepo) SetTeamPermissions(ctx context.Context, organizationID, teamID string, permissions []string) (*models.Team, error) {
+	ctx, done := startOperation(ctx, "team", "SetTeamPermissions")
+	defer done()
+
+	return repo.update(ctx, organizationID, teamID, bson.M{"$set": bson.M{"permissions": permissions}})
+}
+
+// SetTeamMember adds a member to a team, or changes their role if they already belong to it.
+func (repo *TeamRepo) SetTeamMember(ctx context.Context, organizationID, teamID string, member models.TeamMember) (*models.Team, error) {
+	ctx, done := startOperation(ctx, "team", "SetTeamMember")
+	defer done()
+
+	// Replace any existing entry for the member in a single update so the email stays unique.
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.M{
+		"members": bson.M{"$concatArrays": bson.A{
+			bson.M{"$filter": bson.M{
+				"input": bson.M{"$ifNull": bson.A{"$members", bson.A{}}},
+				"cond":  bson.M{"$ne": bson.A{"$$this.email", bson.M{"$literal": member.Email}}},
+			}},
+			bson.A{bson.M{"$literal": member}},
+		}},
+	}}}}
+	return repo.update(ctx, organizationID, teamID, update)
+}
+
+// RemoveTeamMember removes a member from a team and returns the updated team.
+func (repo *TeamRepo) RemoveTeamMember(ctx context.Context, organizationID, teamID, email string) (*models.Team, error) {
+	ctx, done := startOperation(ctx, "team", "RemoveTeamMember")
+	defer done()
+
+	objectID, err := parseID(teamID, "Team")
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := repo.timeouts.forWrite(ctx)
+	defer cancel()
+
+	var team models.Team
+	filter := bson.M{"_id": objectID, "organization_id": organizationID, "members.email": email}
+	update := bson.M{"$pull": bson.M{"members": bson.M{"email": email}}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err = repo.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&team)
+	if err == nil {
+		return &team, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return nil, translateError(err, "Team")
+	}
+
+	// Tell a missing team apart from a user who is not on it.
+	count, err := repo.collection.CountDocuments(ctx, bson.M{"_id": objectID, "organization_id": organizationID})
+	if err != nil {
+		return nil, translateError(err, "Team")
+	}
+	if count == 0 {
+		return nil, translateError(mongo.ErrNoDocuments, "Team")
+	}
+	return nil, apperror.NotFound("Team member not found")
+}
+
+// RemoveMemberFromTeams removes a member from every team of an organization.
+func (repo *TeamRepo) RemoveMemberFromTeams(ctx context.Context, organizationID, email string) error {
+	ctx, done := startOperation(ctx, "team", "RemoveMemberFromTeams")
+	defer done()
+
+	ctx, cancel := repo.timeouts.forWrite(ctx)
+	defer cancel()
+
+	filter := bson.M{"organization_id": organizationID, "members.email": email}
+	_, err := repo.collection.UpdateMany(ctx, filter, bson.M{"$pull": bson.M{"members": bson.M{"email": email}}})
+	return translateError(err, "Team")
+}
+
+// DeleteTeam removes a team from an organization.
+func (repo *TeamRepo) DeleteTeam(ctx context.Context, organizationID, teamID string) error {
+	ctx, done := startOperation(ctx, "team", "DeleteTeam")
+	defer done()
+
+	objectID, err := parseID(teamID, "Team")
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := repo.timeouts.forWrite(ctx)
+	defer cancel()
+
+	filter := bson.M{"_id": objectID, "organization_id": organizationID}
+	result, err := repo.collection.DeleteOne(ctx, filter)
+	if err != nil {
+		return translateError(err, "Team")
+	}
+	if result.DeletedCount == 0 {
+		return translateError(mongo.ErrNoDocuments, "Team")
+	}
+
+	return nil
+}
+
+// DeleteTeams deletes every team of an organization.
+func (repo *TeamRepo) DeleteTeams(ctx context.Context, organizationID string) error {
+	ctx, done := startOperation(ctx, "team", "DeleteTeams")
+	defer done()
+
+	ctx, cancel := repo.timeouts.forWrite(ctx)
+	defer cancel()
+
+	_, err := repo.collection.DeleteMany(ctx, bson.M{"organization_id": organizationID})
+	return translateError(err, "Team")
+}
+
+func (repo *TeamRepo) update(ctx context.Context, organizationID, teamID string, update interface{}) (*models.Team, error) {
+	objectID, err := parseID(teamID, "Team")
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := repo.timeouts.forWrite(ctx)
+	defer cancel()
+
+	var team models.Team
+	filter := bson.M{"_id": objectID, "organization_id": organizationID}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	if err := repo.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&team); err != nil {
+		return nil, translateError(err, "Team")
+	}
+
+	return &team, nil
+}
+
+func (repo *TeamRepo) find(ctx context.Context, filter bson.M) ([]*models.Team, error) {
+	var teams []*models.Team
+
+	ctx, cancel := repo.timeouts.forRead(ctx)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "name", Value: 1}})
+	cursor, err := repo.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, translateError(err, "Team")
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var team models.Team
+		if err := cursor.Decode(&team); err != nil {
+			return nil, err
+		}
+		teams = append(teams, &team)
+	}
+
+	return teams, translateError(cursor.Err(), "Team")
+}
@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/organization_api/config"
+	"github.com/organization_api/pkg/database"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// operationTimeouts bounds every MongoDB call a repository makes.
+type operationTimeouts struct {
+	read  time.Duration
+	write time.Duration
+}
+
+func newOperationTimeouts() operationTimeouts {
+	return timeoutsFromConfig(database.GetTimeouts())
+}
+
+func timeoutsFromConfig(cfg config.DbTimeouts) operationTimeouts {
+	return operationTimeouts{read: cfg.Read, write: cfg.Write}
+}
+
+// forRead derives a context bounded by the read timeout from the caller's context.
+func (t operationTimeouts) forRead(ctx context.Context) (context.Context, context.CancelFunc) {
+	return withTimeout(ctx, t.read)
+}
+
+// forWrite derives a context bounded by the write timeout from the caller's context.
+func (t operationTimeouts) forWrite(ctx context.Context) (context.Context, context.CancelFunc) {
+	return withTimeout(ctx, t.write)
+}
+
+func withTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, d)
+}
+
+// IsTimeout reports whether err was caused by an operation running past its deadline.
+func IsTimeout(err error) bool {
+	return errors.Is(err, context.DeadlineExceeded) || mongo.IsTimeout(err)
+}
@@ -2,65 +2,158 @@ package repository
 
 import (
 	"context"
-	"errors"
 
+	"github.com/organization_api/pkg/apperror"
 	"github.com/organization_api/pkg/database"
 	"github.com/organization_api/pkg/database/mongodb/models"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // UserRepository represents the MongoDB collection for user data.
 type UserRepository struct {
 	collection *mongo.Collection
+	timeouts   operationTimeouts
 }
 
 // NewUserRepository initializes a new UserRepository instance.
 func NewUserRepository() *UserRepository {
 	// Get the MongoDB collection for user data.
 	db := database.GetDatabase()
-	return &UserRepository{collection: db.Collection("user")}
+	return &UserRepository{collection: db.Collection("user"), timeouts: newOperationTimeouts()}
 }
 
 // FindUserByEmail retrieves a user from the database by their email address.
-func (repo *UserRepository) FindUserByEmail(email string) (*models.User, error) {
+// A missing user is reported as a not found error.
+func (repo *UserRepository) FindUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	ctx, done := startOperation(ctx, "user", "FindUserByEmail")
+	defer done()
+
+	ctx, cancel := repo.timeouts.forRead(ctx)
+	defer cancel()
+
 	// Search for the user by email.
 	filter := bson.M{"email": email}
 	var user models.User
-	err := repo.collection.FindOne(context.Background(), filter).Decode(&user)
+	err := repo.collection.FindOne(ctx, filter).Decode(&user)
 	if err != nil {
-		// Return nil if no user is found, otherwise, return an error.
-		if err == mongo.ErrNoDocuments {
-			return nil, nil
-		}
-		return nil, err
+		return nil, translateError(err, "User")
 	}
 
 	return &user, nil
 }
 
-// CreateUser inserts a new user into the database.
-func (repo *UserRepository) CreateUser(user *models.User) (*models.User, error) {
-	// Check if the user already exists by email.
-	existingUser := &models.User{}
-	err := repo.collection.FindOne(context.TODO(), bson.M{"email": user.Email}).Decode(existingUser)
-	if err == nil {
-		// Return an error if the email already exists.
-		return nil, errors.New("email already exists")
+// FindRegisteredEmails looks up which of emails belong to a user, in one query.
+func (repo *UserRepository) FindRegisteredEmails(ctx context.Context, emails []string) ([]string, error) {
+	ctx, done := startOperation(ctx, "user", "FindRegisteredEmails")
+	defer done()
+
+	ctx, cancel := repo.timeouts.forRead(ctx)
+	defer cancel()
+
+	filter := bson.M{"email": bson.M{"$in": emails}}
+	cursor, err := repo.collection.Find(ctx, filter, options.Find().SetProjection(bson.M{"email": 1}))
+	if err != nil {
+		return nil, translateError(err, "User")
+	}
+	defer cursor.Close(ctx)
+
+	var registered []string
+	for cursor.Next(ctx) {
+		var user models.User
+		if err := cursor.Decode(&user); err != nil {
+			return nil, err
+		}
+		registered = append(registered, user.Email)
 	}
 
-	// Insert the new user into the database.
-	createdUser, err := repo.collection.InsertOne(context.Background(), user)
+	return registered, translateError(cursor.Err(), "User")
+}
+
+// CreateUser inserts a new user into the database.
+func (repo *UserRepository) CreateUser(ctx context.Context, user *models.User) (*models.User, error) {
+	ctx, done := startOperation(ctx, "user", "CreateUser")
+	defer done()
+
+	ctx, cancel := repo.timeouts.forWrite(ctx)
+	defer cancel()
+
+	// Insert the new user; the unique email index rejects an existing address.
+	createdUser, err := repo.collection.InsertOne(ctx, user)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, apperror.Conflict("A user with this email already exists").Wrap(err)
+	}
 	if err != nil {
-		return nil, err
+		return nil, translateError(err, "User")
 	}
 	filter := bson.M{"_id": createdUser.InsertedID}
 	var insertedUser models.User
-	err = repo.collection.FindOne(context.TODO(), filter).Decode(&insertedUser)
+	err = repo.collection.FindOne(ctx, filter).Decode(&insertedUser)
 	if err != nil {
-		return nil, err
+		return nil, translateError(err, "User")
 	}
 
 	return &insertedUser, nil
 }
+
+// SetUserDisabled disables or re-enables a user's account.
+func (repo *UserRepository) SetUserDisabled(ctx context.Context, email string, disabled bool) error {
+	ctx, done := startOperation(ctx, "user", "SetUserDisabled")
+	defer done()
+
+	return repo.updateByEmail(ctx, email, bson.M{"$set": bson.M{"disabled": disabled}})
+}
+
+// SetEmailVerified marks a user's email address as confirmed.
+func (repo *UserRepository) SetEmailVerified(ctx context.Context, email string) error {
+	ctx, done := startOperation(ctx, "user", "SetEmailVerified")
+	defer done()
+
+	return repo.updateByEmail(ctx, email, bson.M{"$set": bson.M{"email_verified": true}})
+}
+
+// UpdatePassword replaces a user's password hash.
+func (repo *UserRepository) UpdatePassword(ctx context.Context, email, passwordHash string) error {
+	ctx, done := startOperation(ctx, "user", "UpdatePassword")
+	defer done()
+
+	return repo.updateByEmail(ctx, email, bson.M{"$set": bson.M{"password": passwordHash}})
+}
+
+// SearchUsers matches query against the name and email of the users whose emails are in query.Within.
+func (repo *UserRepository) SearchUsers(ctx context.Context, query models.SearchQuery) ([]*models.SearchHit, int64, error) {
+	ctx, done := startOperation(ctx, "user", "SearchUsers")
+	defer done()
+
+	if len(query.Within) == 0 {
+		return nil, 0, nil
+	}
+
+	ctx, cancel := repo.timeouts.forRead(ctx)
+	defer cancel()
+
+	return textSearch(ctx, repo.collection, query.Text, bson.M{"email": bson.M{"$in": query.Within}}, query.Limit, "User",
+		func(cursor *mongo.Cursor) (*models.SearchHit, error) {
+			var user models.User
+			if err := cursor.Decode(&user); err != nil {
+				return nil, err
+			}
+			return &models.SearchHit{Type: models.SearchTypeUser, User: &user}, nil
+		})
+}
+
+func (repo *UserRepository) updateByEmail(ctx context.Context, email string, update bson.M) error {
+	ctx, cancel := repo.timeouts.forWrite(ctx)
+	defer cancel()
+
+	result, err := repo.collection.UpdateOne(ctx, bson.M{"email": email}, update)
+	if err != nil {
+		return translateError(err, "User")
+	}
+	if result.MatchedCount == 0 {
+		return translateError(mongo.ErrNoDocuments, "User")
+	}
+	return nil
+}
@@ -0,0 +1,231 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/organization_api/pkg/database"
+	"github.com/organization_api/pkg/database/mongodb/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// WebhookRepo stores webhook subscriptions and their delivery log.
+type WebhookRepo struct {
+	collection *mongo.Collection
+	deliveries *mongo.Collection
+	timeouts   operationTimeouts
+}
+
+// NewWebhookRepo initializes a new WebhookRepo instance.
+func NewWebhookRepo() *WebhookRepo {
+	// Get the MongoDB collections for webhooks and deliveries.
+	db := database.GetDatabase()
+	return &WebhookRepo{
+		collection: db.Collection("webhook"),
+		deliveries: db.Collection("webhook_delivery"),
+		timeouts:   newOperationTimeouts(),
+	}
+}
+
+// CreateWebhook inserts a new webhook subscription and returns its ID.
+func (repo *WebhookRepo) CreateWebhook(ctx context.Context, hook *models.Webhook) (string, error) {
+	ctx, done := startOperation(ctx, "webhook", "CreateWebhook")
+	defer done()
+
+	ctx, cancel := repo.timeouts.forWrite(ctx)
+	defer cancel()
+
+	result, err := repo.collection.InsertOne(ctx, hook)
+	if err != nil {
+		return "", translateError(err, "Webhook")
+	}
+
+	hook.Id = result.InsertedID.(primitive.ObjectID)
+	return hook.Id.Hex(), nil
+}
+
+// GetWebhooksByOrganization lists the webhook subscriptions of an organization.
+func (repo *WebhookRepo) GetWebhooksByOrganization(ctx context.Context, organizationID string) ([]*models.Webhook, error) {
+	ctx, done := startOperation(ctx, "webhook", "GetWebhooksByOrganization")
+	defer done()
+
+	return repo.find(ctx, bson.M{"organization_id": organizationID})
+}
+
+// GetWebhookById retrieves a single webhook belonging to an organization.
+func (repo *WebhookRepo) GetWebhookById(ctx context.Context, organizationID, webhookID string) (*models.Webhook, error) {
+	ctx, done := startOperation(ctx, "webhook", "GetWebhookById")
+	defer done()
+
+	objectID, err := parseID(webhookID, "Webhook")
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := repo.timeouts.forRead(ctx)
+	defer cancel()
+
+	var hook models.Webhook
+	filter := bson.M{"_id": objectID, "organization_id": organizationID}
+	err = repo.collection.FindOne(ctx, filter).Decode(&hook)
+	if err != nil {
+		return nil, translateError(err, "Webhook")
+	}
+
+	return &hook, nil
+}
+
+// DeleteWebhook removes a webhook subscription from an organization.
+func (repo *WebhookRepo) DeleteWebhook(ctx context.Context, organizationID, webhookID string) error {
+	ctx, done := startOperation(ctx, "webhook", "DeleteWebhook")
+	defer done()
+
+	objectID, err := parseID(webhookID, "Webhook")
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := repo.timeouts.forWrite(ctx)
+	defer cancel()
+
+	filter := bson.M{"_id": objectID, "organization_id": organizationID}
+	result, err := repo.collection.DeleteOne(ctx, filter)
+	if err != nil {
+		return translateError(err, "Webhook")
+	}
+	if result.DeletedCount == 0 {
+		return translateError(mongo.ErrNoDocuments, "Webhook")
+	}
+
+	return nil
+}
+
+// DeleteWebhooks deletes every webhook of an organization, then their deliveries. Deliveries
+// saved after the webhooks are gone are dropped by SaveDelivery.
+func (repo *WebhookRepo) DeleteWebhooks(ctx context.Context, organizationID string) error {
+	ctx, done := startOperation(ctx, "webhook", "DeleteWebhooks")
+	defer done()
+
+	hooks, err := repo.find(ctx, bson.M{"organization_id": organizationID})
+	if err != nil {
+		return err
+	}
+	ids := make([]primitive.ObjectID, len(hooks))
+	for i, hook := range hooks {
+		ids[i] = hook.Id
+	}
+
+	ctx, cancel := repo.timeouts.forWrite(ctx)
+	defer cancel()
+
+	if _, err := repo.collection.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}}); err != nil {
+		return translateError(err, "Webhook")
+	}
+	_, err = repo.deliveries.DeleteMany(ctx, bson.M{"webhook_id": bson.M{"$in": ids}})
+	return translateError(err, "Webhook delivery")
+}
+
+// FindWebhooksForEvent returns the active webhooks of an organization subscribed to an event.
+// A webhook with no event filter receives every event.
+func (repo *WebhookRepo) FindWebhooksForEvent(ctx context.Context, organizationID, event string) ([]*models.Webhook, error) {
+	ctx, done := startOperation(ctx, "webhook", "FindWebhooksForEvent")
+	defer done()
+
+	filter := bson.M{
+		"organization_id": organizationID,
+		"active":          true,
+		"$or": bson.A{
+			bson.M{"events": bson.M{"$exists": false}},
+			bson.M{"events": bson.M{"$size": 0}},
+			bson.M{"events": event},
+			bson.M{"events": "*"},
+		},
+	}
+	return repo.find(ctx, filter)
+}
+
+// SaveDelivery inserts or replaces a delivery log entry.
+func (repo *WebhookRepo) SaveDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	ctx, done := startOperation(ctx, "webhook", "SaveDelivery")
+	defer done()
+
+	if delivery.Id.IsZero() {
+		delivery.Id = primitive.NewObjectID()
+	}
+
+	ctx, cancel := repo.timeouts.forWrite(ctx)
+	defer cancel()
+
+	// A delivery still in flight when its webhook is deleted would be left without one.
+	err := repo.collection.FindOne(ctx, bson.M{"_id": delivery.WebhookId}, options.FindOne().SetProjection(bson.M{"_id": 1})).Err()
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil
+	}
+	if err != nil {
+		return translateError(err, "Webhook")
+	}
+
+	filter := bson.M{"_id": delivery.Id}
+	opts := options.Replace().SetUpsert(true)
+	_, err = repo.deliveries.ReplaceOne(ctx, filter, delivery, opts)
+	return translateError(err, "Webhook delivery")
+}
+
+// GetDeliveriesByWebhook lists the delivery log of a webhook, newest first.
+func (repo *WebhookRepo) GetDeliveriesByWebhook(ctx context.Context, webhookID string) ([]*models.WebhookDelivery, error) {
+	ctx, done := startOperation(ctx, "webhook", "GetDeliveriesByWebhook")
+	defer done()
+
+	objectID, err := parseID(webhookID, "Webhook")
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := repo.timeouts.forRead(ctx)
+	defer cancel()
+
+	var deliveries []*models.WebhookDelivery
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := repo.deliveries.Find(ctx, bson.M{"webhook_id": objectID}, opts)
+	if err != nil {
+		return nil, translateError(err, "Webhook delivery")
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var delivery models.WebhookDelivery
+		if err := cursor.Decode(&delivery); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, &delivery)
+	}
+
+	return deliveries, translateError(cursor.Err(), "Webhook delivery")
+}
+
+func (repo *WebhookRepo) find(ctx context.Context, filter bson.M) ([]*models.Webhook, error) {
+	var hooks []*models.Webhook
+
+	ctx, cancel := repo.timeouts.forRead(ctx)
+	defer cancel()
+
+	cursor, err := repo.collection.Find(ctx, filter)
+	if err != nil {
+		return nil, translateError(err, "Webhook")
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var hook models.Webhook
+		if err := cursor.Decode(&hook); err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, &hook)
+	}
+
+	return hooks, translateError(cursor.Err(), "Webhook")
+}
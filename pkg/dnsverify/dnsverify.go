@@ -0,0 +1,91 @@
+// Package dnsverify proves control of an email domain through a DNS TXT record.
+package dnsverify
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net"
+	"strings"
+)
+
+// RecordPrefix is the label under which the TXT record is looked up, e.g. _orgapi-challenge.acme.com.
+const RecordPrefix = "_orgapi-challenge."
+
+// valuePrefix starts the value of the TXT record, followed by the claim's token.
+const valuePrefix = "orgapi-verification="
+
+// Resolver looks up TXT records. *net.Resolver satisfies it; tests use a Static resolver.
+type Resolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// Static is a Resolver answering from a fixed map of names to records.
+type Static map[string][]string
+
+// LookupTXT returns the records stored for name, or a not-found DNS error.
+func (s Static) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	records, ok := s[name]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	return records, nil
+}
+
+// Normalize lower-cases domain and drops a trailing dot, so equal domains compare equal.
+func Normalize(domain string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+}
+
+// EmailDomain returns the normalized domain of email, or "" when it has none.
+func EmailDomain(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return ""
+	}
+	return Normalize(email[at+1:])
+}
+
+// NewToken returns a random token for a new claim.
+func NewToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// RecordName is the name the TXT record for domain must be published under.
+func RecordName(domain string) string {
+	return RecordPrefix + domain
+}
+
+// RecordValue is the TXT record that proves the claim holding token.
+func RecordValue(token string) string {
+	return valuePrefix + token
+}
+
+// Verify reports whether the TXT record for token is published for domain. A missing record is not
+// an error; a failed lookup is.
+func Verify(ctx context.Context, resolver Resolver, domain, token string) (bool, error) {
+	records, err := resolver.LookupTXT(ctx, RecordName(domain))
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	want := RecordValue(token)
+	for _, record := range records {
+		if strings.TrimSpace(record) == want {
+			return true, nil
+		}
+	}
+	return false, nil
+}
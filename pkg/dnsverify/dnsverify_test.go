@@ -0,0 +1,58 @@
+package dnsverify
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	ctx := context.Background()
+	resolver := Static{
+		"_orgapi-challenge.acme.com": {"v=spf1 -all", RecordValue("secret")},
+	}
+
+	cases := []struct {
+		domain, token string
+		want          bool
+	}{
+		{"acme.com", "secret", true},
+		{"acme.com", "other", false},
+		{"globex.com", "secret", false},
+	}
+	for _, tc := range cases {
+		got, err := Verify(ctx, resolver, tc.domain, tc.token)
+		if err != nil || got != tc.want {
+			t.Errorf("Verify(%s, %s) = %v, %v; want %v", tc.domain, tc.token, got, err, tc.want)
+		}
+	}
+}
+
+// failing is a resolver whose lookups fail.
+type failing struct{}
+
+func (failing) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	return nil, &net.DNSError{Err: "server misbehaving", Name: name, IsTemporary: true}
+}
+
+func TestVerifyReportsLookupFailures(t *testing.T) {
+	_, err := Verify(context.Background(), failing{}, "acme.com", "secret")
+	var dnsErr *net.DNSError
+	if !errors.As(err, &dnsErr) || !dnsErr.IsTemporary {
+		t.Fatalf("expected the lookup error, got %v", err)
+	}
+}
+
+func TestEmailDomain(t *testing.T) {
+	cases := map[string]string{
+		"ada@Acme.COM":  "acme.com",
+		"ada@acme.com.": "acme.com",
+		"no-at-sign":    "",
+	}
+	for email, want := range cases {
+		if got := EmailDomain(email); got != want {
+			t.Errorf("EmailDomain(%q) = %q, want %q", email, got, want)
+		}
+	}
+}
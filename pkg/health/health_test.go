@@ -0,0 +1,54 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestReadyReportsEachCheck(t *testing.T) {
+	r := NewRegistry()
+	r.Register("mongodb", func(ctx context.Context) error { return nil })
+
+	report := r.Ready(context.Background())
+	if report.Status != StatusUp || report.Checks["mongodb"].Status != StatusUp {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+
+	r.Register("redis", func(ctx context.Context) error { return errors.New("connection refused") })
+	report = r.Ready(context.Background())
+	if report.Status != StatusDown {
+		t.Fatalf("a failing check must fail readiness: %+v", report)
+	}
+	if got := report.Checks["redis"]; got.Status != StatusDown || got.Error != "connection refused" {
+		t.Fatalf("unexpected redis result: %+v", got)
+	}
+	if report.Checks["mongodb"].Status != StatusUp {
+		t.Fatalf("passing checks are still reported: %+v", report)
+	}
+}
+
+func TestReadyBoundsSlowChecks(t *testing.T) {
+	r := NewRegistry()
+	r.Timeout = 20 * time.Millisecond
+	r.Register("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	start := time.Now()
+	report := r.Ready(context.Background())
+	if report.Status != StatusDown || time.Since(start) > time.Second {
+		t.Fatalf("slow check was not bounded: %+v", report)
+	}
+}
+
+func TestReadyFailsWhileShuttingDown(t *testing.T) {
+	r := NewRegistry()
+	r.SetShuttingDown()
+
+	if report := r.Ready(context.Background()); report.Status != StatusDown {
+		t.Fatalf("expected down during shutdown: %+v", report)
+	}
+}
@@ -0,0 +1,46 @@
+package logging
+
+import (
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+const redacted = "REDACTED"
+
+// sensitiveKeys are matched against lower-cased attribute keys; any key containing one is masked.
+var sensitiveKeys = []string{"password", "secret", "token", "authorization", "cookie", "api_key"}
+
+var (
+	// uriCredentials matches the user:password@ part of a connection string.
+	uriCredentials = regexp.MustCompile(`(\w+://[^\s:/@]*):[^\s/@]+@`)
+	// bearerToken matches an Authorization header value.
+	bearerToken = regexp.MustCompile(`(?i)\bbearer\s+\S+`)
+	// jwt matches a compact JSON Web Token.
+	jwt = regexp.MustCompile(`\beyJ[\w-]*\.[\w-]+\.[\w-]*`)
+)
+
+// redact masks attributes whose key names a secret and scrubs credentials embedded in string and error values.
+func redact(groups []string, attr slog.Attr) slog.Attr {
+	key := strings.ToLower(attr.Key)
+	for _, sensitive := range sensitiveKeys {
+		if strings.Contains(key, sensitive) {
+			return slog.String(attr.Key, redacted)
+		}
+	}
+
+	switch value := attr.Value.Any().(type) {
+	case string:
+		return slog.String(attr.Key, Scrub(value))
+	case error:
+		return slog.String(attr.Key, Scrub(value.Error()))
+	}
+	return attr
+}
+
+// Scrub masks connection string passwords, bearer tokens and JWTs in s.
+func Scrub(s string) string {
+	s = uriCredentials.ReplaceAllString(s, "${1}:"+redacted+"@")
+	s = bearerToken.ReplaceAllString(s, "Bearer "+redacted)
+	return jwt.ReplaceAllString(s, redacted)
+}
@@ -0,0 +1,101 @@
+// Package mail sends transactional email, such as address confirmations, through an SMTP relay.
+package mail
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// ErrNotConfigured is returned by Disabled, when no relay is configured.
+var ErrNotConfigured = errors.New("mail: no SMTP relay configured")
+
+// Message is a plain-text email to a single recipient.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender delivers messages. SMTP sends them through a relay; Disabled refuses to.
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Disabled is the Sender used when no relay is configured.
+type Disabled struct{}
+
+// Send always fails with ErrNotConfigured.
+func (Disabled) Send(ctx context.Context, msg Message) error {
+	return ErrNotConfigured
+}
+
+// SMTP sends messages through the relay at Addr, authenticating when Username is set.
+type SMTP struct {
+	Addr     string
+	From     string
+	Username string
+	Password string
+}
+
+// Send delivers msg. The relay is expected to answer within the deadline of ctx, or 30 seconds.
+func (s SMTP) Send(ctx context.Context, msg Message) error {
+	raw, err := format(s.From, msg, time.Now())
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if s.Username != "" {
+		host, _, err := net.SplitHostPort(s.Addr)
+		if err != nil {
+			return fmt.Errorf("mail: invalid relay address %q: %w", s.Addr, err)
+		}
+		auth = smtp.PlainAuth("", s.Username, s.Password, host)
+	}
+
+	// smtp.SendMail takes no context, so run it aside and stop waiting when ctx ends.
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(s.Addr, auth, s.From, []string{msg.To}, raw)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("mail: sending to %s: %w", msg.To, ctx.Err())
+	}
+}
+
+// format renders msg with its headers. Addresses must parse and no header may contain a line
+// break, so a caller-supplied value cannot add headers or recipients.
+func format(from string, msg Message, now time.Time) ([]byte, error) {
+	for _, address := range []string{from, msg.To} {
+		if _, err := mail.ParseAddress(address); err != nil || strings.ContainsAny(address, "\r\n") {
+			return nil, fmt.Errorf("mail: invalid address %q", address)
+		}
+	}
+	if strings.ContainsAny(msg.Subject, "\r\n") {
+		return nil, errors.New("mail: subject contains a line break")
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("\r\n")
+	buf.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return buf.Bytes(), nil
+}
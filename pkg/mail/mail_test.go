@@ -0,0 +1,143 @@
+package mail
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeRelay accepts one SMTP session on a local port and sends the recipient and data it received.
+func fakeRelay(t *testing.T) (addr string, received <-chan string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	out := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+		var session strings.Builder
+		reply("220 relay.test ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			command := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+				reply("250 relay.test")
+			case strings.HasPrefix(command, "RCPT TO:"):
+				session.WriteString(strings.TrimSpace(line) + "\n")
+				reply("250 OK")
+			case command == "DATA":
+				reply("354 Go ahead")
+				for {
+					data, err := r.ReadString('\n')
+					if err != nil || data == ".\r\n" {
+						break
+					}
+					session.WriteString(data)
+				}
+				reply("250 Queued")
+			case command == "QUIT":
+				reply("221 Bye")
+				out <- session.String()
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+	}()
+	return listener.Addr().String(), out
+}
+
+func TestFormat(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	raw, err := format("no-reply@example.com", Message{To: "ada@example.com", Subject: "Confirm", Body: "line one\nline two"}, now)
+	if err != nil {
+		t.Fatalf("format: %v", err)
+	}
+
+	got := string(raw)
+	for _, want := range []string{
+		"From: no-reply@example.com\r\n",
+		"To: ada@example.com\r\n",
+		"Subject: Confirm\r\n",
+		"Date: Wed, 01 May 2024 12:00:00 +0000\r\n",
+		"\r\n\r\nline one\r\nline two",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected %q in:\n%s", want, got)
+		}
+	}
+}
+
+func TestFormatRefusesHeaderInjection(t *testing.T) {
+	for _, msg := range []Message{
+		{To: "ada@example.com\r\nBcc: eve@example.com", Subject: "Confirm"},
+		{To: "not an address", Subject: "Confirm"},
+		{To: "ada@example.com", Subject: "Confirm\r\nBcc: eve@example.com"},
+	} {
+		if _, err := format("no-reply@example.com", msg, time.Now()); err == nil {
+			t.Errorf("expected %+v to be refused", msg)
+		}
+	}
+}
+
+func TestDisabledRefusesToSend(t *testing.T) {
+	if err := (Disabled{}).Send(context.Background(), Message{To: "ada@example.com"}); !errors.Is(err, ErrNotConfigured) {
+		t.Fatalf("expected ErrNotConfigured, got %v", err)
+	}
+}
+
+func TestSMTPSend(t *testing.T) {
+	addr, received := fakeRelay(t)
+	sender := SMTP{Addr: addr, From: "no-reply@example.com"}
+
+	if err := sender.Send(context.Background(), Message{To: "ada@example.com", Subject: "Confirm", Body: "token"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	session := <-received
+	for _, want := range []string{"RCPT TO:<ada@example.com>", "From: no-reply@example.com\r\n", "\r\n\r\ntoken"} {
+		if !strings.Contains(session, want) {
+			t.Errorf("expected %q in the session:\n%s", want, session)
+		}
+	}
+}
+
+func TestSMTPSendStopsAtDeadline(t *testing.T) {
+	// A relay that accepts the connection but never greets.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			defer conn.Close()
+			time.Sleep(time.Second)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = SMTP{Addr: listener.Addr().String(), From: "no-reply@example.com"}.Send(ctx, Message{To: "ada@example.com", Subject: "Confirm"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the deadline to stop the send, got %v", err)
+	}
+}
@@ -0,0 +1,106 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// namespace prefixes every application metric.
+const namespace = "organization_api"
+
+// Registry holds every metric exposed on /metrics, including Go runtime and process stats.
+var Registry = prometheus.NewRegistry()
+
+var (
+	// HTTPRequests counts finished requests by method, route template and status code.
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "HTTP requests handled, by method, route template and status code.",
+	}, []string{"method", "route", "status"})
+
+	// HTTPRequestDuration observes request latency by method, route template and status code.
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latency, by method, route template and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	// HTTPInFlight tracks requests currently being served.
+	HTTPInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_in_flight",
+		Help:      "HTTP requests currently being served.",
+	})
+
+	// MongoOperationDuration observes repository method latency against MongoDB.
+	MongoOperationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "mongodb",
+		Name:      "operation_duration_seconds",
+		Help:      "MongoDB repository operation latency, by repository and method.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+	}, []string{"repository", "operation"})
+
+	// SignIns counts sign-in attempts by result: success or failure.
+	SignIns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "auth",
+		Name:      "signins_total",
+		Help:      "Sign-in attempts, by result.",
+	}, []string{"result"})
+
+	// TokenRefreshes counts refresh token exchanges by result: success or failure.
+	TokenRefreshes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "auth",
+		Name:      "token_refreshes_total",
+		Help:      "Refresh token exchanges, by result.",
+	}, []string{"result"})
+)
+
+// Results recorded by the auth counters.
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests,
+		HTTPRequestDuration,
+		HTTPInFlight,
+		MongoOperationDuration,
+		SignIns,
+		TokenRefreshes,
+	)
+
+	// Expose both results from the start so rates are defined before the first attempt.
+	for _, result := range []string{ResultSuccess, ResultFailure} {
+		SignIns.WithLabelValues(result)
+		TokenRefreshes.WithLabelValues(result)
+	}
+}
+
+// Handler serves the registry in the Prometheus text exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// ObserveMongo starts timing a repository operation; call the returned function when it finishes.
+func ObserveMongo(repository, operation string) func() {
+	start := time.Now()
+	return func() {
+		MongoOperationDuration.WithLabelValues(repository, operation).Observe(time.Since(start).Seconds())
+	}
+}
@@ -0,0 +1,108 @@
+// Package slug derives unique, URL-safe organization identifiers from names.
+package slug
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/text/unicode/norm"
+)
+
+// MaxLength bounds the length of a slug, including any collision suffix.
+const MaxLength = 64
+
+// MaxAttempts bounds how many suffixed candidates are tried before giving up.
+const MaxAttempts = 50
+
+// fallback is the base used for names without a single letter or digit.
+const fallback = "org"
+
+// reserved are words that would clash with routes or read as something they are not.
+var reserved = map[string]bool{
+	"admin": true, "ancestors": true, "api": true, "auth": true, "children": true,
+	"domains": true, "export": true, "healthz": true, "import": true, "invite": true,
+	"join": true, "leave": true, "me": true, "members": true, "metrics": true, "new": true,
+	"organization": true, "readyz": true, "search": true, "settings": true, "teams": true,
+	"transfer": true, "webhooks": true,
+}
+
+// Make returns the base slug for name: lower-case ASCII letters and digits separated by single
+// hyphens, with accents removed. It never returns an empty string.
+func Make(name string) string {
+	var b strings.Builder
+	hyphen := false
+	for _, r := range norm.NFD.String(strings.ToLower(name)) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			// Drop the accents split off by NFD.
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			if hyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			hyphen = false
+		default:
+			hyphen = true
+		}
+	}
+
+	base := b.String()
+	if len(base) > MaxLength-4 {
+		// Leave room for a collision suffix.
+		base = strings.TrimRight(base[:MaxLength-4], "-")
+	}
+	if base == "" {
+		return fallback
+	}
+	return base
+}
+
+// Candidate returns the n-th slug to try for base, counting from 1: the base itself, then base-2,
+// base-3 and so on. Reserved words and slugs that look like IDs always carry a suffix.
+func Candidate(base string, n int) string {
+	if n <= 1 {
+		if Allowed(base) {
+			return base
+		}
+		n = 1
+	}
+	return fmt.Sprintf("%s-%d", base, n)
+}
+
+// DerivedFrom reports whether s is one of the candidates for base, so a rename that keeps the
+// same base can keep its slug.
+func DerivedFrom(s, base string) bool {
+	if s == base {
+		return true
+	}
+	suffix, ok := strings.CutPrefix(s, base+"-")
+	if !ok || suffix == "" {
+		return false
+	}
+	for _, r := range suffix {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Allowed reports whether s may be used as a slug as it is.
+func Allowed(s string) bool {
+	return !reserved[s] && !primitive.IsValidObjectID(s)
+}
+
+// Valid reports whether s is shaped like a slug, so lookups can skip anything that cannot be one.
+func Valid(s string) bool {
+	if s == "" || len(s) > MaxLength || s[0] == '-' || s[len(s)-1] == '-' {
+		return false
+	}
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
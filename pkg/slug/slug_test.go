@@ -0,0 +1,70 @@
+package slug
+
+import "testing"
+
+func TestMake(t *testing.T) {
+	cases := map[string]string{
+		"Acme":                 "acme",
+		"  Acme   Corp, Inc. ": "acme-corp-inc",
+		"Café Crème":           "cafe-creme",
+		"R&D / EU-West":        "r-d-eu-west",
+		"!!!":                  "org",
+		"日本":                   "org",
+		"Acme 2024":            "acme-2024",
+	}
+	for name, want := range cases {
+		if got := Make(name); got != want {
+			t.Errorf("Make(%q) = %q, want %q", name, got, want)
+		}
+	}
+
+	long := Make("a very long organization name that keeps going well past the limit for slugs")
+	if len(Candidate(long, MaxAttempts)) > MaxLength || !Valid(long) {
+		t.Errorf("expected long names to leave room for a suffix, got %q", long)
+	}
+}
+
+func TestCandidate(t *testing.T) {
+	cases := []struct {
+		base string
+		n    int
+		want string
+	}{
+		{"acme", 1, "acme"},
+		{"acme", 2, "acme-2"},
+		{"admin", 1, "admin-1"},
+		{"admin", 2, "admin-2"},
+		{"65f1c0ffee0000000000abcd", 1, "65f1c0ffee0000000000abcd-1"},
+	}
+	for _, tc := range cases {
+		if got := Candidate(tc.base, tc.n); got != tc.want {
+			t.Errorf("Candidate(%q, %d) = %q, want %q", tc.base, tc.n, got, tc.want)
+		}
+	}
+}
+
+func TestValid(t *testing.T) {
+	for _, s := range []string{"acme", "acme-2", "r-d-eu-west"} {
+		if !Valid(s) {
+			t.Errorf("expected %q to be valid", s)
+		}
+	}
+	for _, s := range []string{"", "Acme", "-acme", "acme-", "acme corp", "acme/2"} {
+		if Valid(s) {
+			t.Errorf("expected %q to be invalid", s)
+		}
+	}
+}
+
+func TestDerivedFrom(t *testing.T) {
+	for _, s := range []string{"acme", "acme-2", "acme-17"} {
+		if !DerivedFrom(s, "acme") {
+			t.Errorf("expected %q to derive from acme", s)
+		}
+	}
+	for _, s := range []string{"acme-corp", "acme-", "acmes", "acme-2-3"} {
+		if DerivedFrom(s, "acme") {
+			t.Errorf("expected %q not to derive from acme", s)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/organization_api/config"
+)
+
+func TestSetupExporters(t *testing.T) {
+	for _, exporter := range []string{"none", "stdout", "otlp"} {
+		shutdown, err := Setup(context.Background(), config.TracingConfig{Exporter: exporter, Endpoint: "localhost:4318", SampleRatio: 1}, "test")
+		if err != nil {
+			t.Fatalf("Setup(%s): %v", exporter, err)
+		}
+		if err := shutdown(context.Background()); err != nil {
+			t.Errorf("shutdown(%s): %v", exporter, err)
+		}
+	}
+
+	if _, err := Setup(context.Background(), config.TracingConfig{Exporter: "zipkin"}, "test"); err == nil {
+		t.Error("expected an error for an unknown exporter")
+	}
+}
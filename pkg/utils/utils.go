@@ -3,37 +3,95 @@ package utils
 import (
 	"errors"
 	"fmt"
+	"sync/atomic"
 	"time"
 
+	"github.com/organization_api/config"
+
 	"github.com/golang-jwt/jwt"
 	"golang.org/x/crypto/bcrypt"
 )
 
-// Constants for JWT expiration times and secret key.
+// Default JWT expiration times and secret key, used until ConfigureTokens is called.
 const (
 	AccessTokenExpiry  = time.Hour * 1
 	RefreshTokenExpiry = time.Hour * 72
 	SecretKey          = "secret_key"
 )
 
+// EmailVerificationExpiry is how long the token emailed to confirm an address stays valid.
+const EmailVerificationExpiry = time.Hour * 24
+
+// tokenSettings holds the signing secret and token lifetimes in effect.
+// It is swapped atomically so lifetimes can be reloaded while requests are served.
+var tokenSettings atomic.Pointer[config.JWTConfig]
+
+func init() {
+	tokenSettings.Store(&config.JWTConfig{
+		Secret:             SecretKey,
+		AccessTokenExpiry:  AccessTokenExpiry,
+		RefreshTokenExpiry: RefreshTokenExpiry,
+	})
+}
+
+// ConfigureTokens sets the signing secret and token lifetimes.
+func ConfigureTokens(cfg config.JWTConfig) {
+	tokenSettings.Store(&cfg)
+}
+
+// RefreshTokenLifetime returns how long refresh tokens issued now stay valid.
+func RefreshTokenLifetime() time.Duration {
+	return tokenSettings.Load().RefreshTokenExpiry
+}
+
+// Token types carried in the typ claim, so a token cannot be used in place of the other kind.
+const (
+	TokenTypeAccess            = "access"
+	TokenTypeRefresh           = "refresh"
+	TokenTypeEmailVerification = "email_verification"
+)
+
 // Claims holds the standard JWT claims plus additional custom fields.
 type Claims struct {
 	Username string `json:"username"`
+	Email    string `json:"email"`
+	Type     string `json:"typ"`
+	// IssuedAtMilli is the issue time in Unix milliseconds; iat only has second precision.
+	IssuedAtMilli int64 `json:"iat_ms"`
 	jwt.StandardClaims
 }
 
+// IssuedNoLaterThan reports whether the token was issued at or before t, at millisecond precision.
+// Tokens issued before iat_ms was added fall back to the one-second precision of iat,
+// and tokens without either claim count as issued at the epoch.
+func (claims *Claims) IssuedNoLaterThan(t time.Time) bool {
+	if claims.IssuedAtMilli != 0 {
+		return claims.IssuedAtMilli <= t.UnixMilli()
+	}
+	return claims.IssuedAt <= t.Unix()
+}
+
 // GenerateTokens creates JWT access and refresh tokens for a user.
 func GenerateTokens(username, email string) (accessToken string, refreshToken string, err error) {
+	tokenConfig := tokenSettings.Load()
+	now := time.Now()
+
 	// Define the claims of the access and refresh tokens.
 	accessClaims := jwt.MapClaims{
 		"username": username,
 		"email":    email,
-		"exp":      time.Now().Add(AccessTokenExpiry).Unix(),
+		"typ":      TokenTypeAccess,
+		"iat":      now.Unix(),
+		"iat_ms":   now.UnixMilli(),
+		"exp":      now.Add(tokenConfig.AccessTokenExpiry).Unix(),
 	}
 	refreshClaims := jwt.MapClaims{
 		"username": username,
 		"email":    email,
-		"exp":      time.Now().Add(RefreshTokenExpiry).Unix(),
+		"typ":      TokenTypeRefresh,
+		"iat":      now.Unix(),
+		"iat_ms":   now.UnixMilli(),
+		"exp":      now.Add(tokenConfig.RefreshTokenExpiry).Unix(),
 	}
 
 	// Create the access and refresh token objects.
@@ -41,11 +99,11 @@ func GenerateTokens(username, email string) (accessToken string, refreshToken st
 	refreshTokenObj := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims)
 
 	// Sign the tokens with the secret key.
-	accessToken, err = accessTokenObj.SignedString([]byte(SecretKey))
+	accessToken, err = accessTokenObj.SignedString([]byte(tokenConfig.Secret))
 	if err != nil {
 		return "", "", err
 	}
-	refreshToken, err = refreshTokenObj.SignedString([]byte(SecretKey))
+	refreshToken, err = refreshTokenObj.SignedString([]byte(tokenConfig.Secret))
 	if err != nil {
 		return "", "", err
 	}
@@ -53,6 +111,22 @@ func GenerateTokens(username, email string) (accessToken string, refreshToken st
 	return accessToken, refreshToken, nil
 }
 
+// GenerateEmailVerificationToken creates the token emailed to email to confirm the address.
+// It only proves receipt of that email; it grants no access.
+func GenerateEmailVerificationToken(email string) (string, error) {
+	tokenConfig := tokenSettings.Load()
+	now := time.Now()
+
+	claims := jwt.MapClaims{
+		"email":  email,
+		"typ":    TokenTypeEmailVerification,
+		"iat":    now.Unix(),
+		"iat_ms": now.UnixMilli(),
+		"exp":    now.Add(EmailVerificationExpiry).Unix(),
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(tokenConfig.Secret))
+}
+
 // HashPassword secures a plaintext password using bcrypt.
 func HashPassword(password string) (string, error) {
 	// Generate a bcrypt hash from the plaintext password.
@@ -67,11 +141,12 @@ func HashPassword(password string) (string, error) {
 // VerifyRefreshToken checks the validity of a refresh token and returns the username and email.
 func VerifyRefreshToken(refreshToken string) (string, string, error) {
 	// Parse and validate the refresh token.
+	tokenConfig := tokenSettings.Load()
 	token, err := jwt.Parse(refreshToken, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
-		return []byte(SecretKey), nil
+		return []byte(tokenConfig.Secret), nil
 	})
 
 	// Handle parsing errors.
@@ -81,6 +156,9 @@ func VerifyRefreshToken(refreshToken string) (string, string, error) {
 
 	// Extract and return the username and email from the token claims.
 	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
+		if claims["typ"] != TokenTypeRefresh {
+			return "", "", errors.New("not a refresh token")
+		}
 		username, ok1 := claims["username"].(string)
 		email, ok2 := claims["email"].(string)
 		if !ok1 || !ok2 {
@@ -95,8 +173,9 @@ func VerifyRefreshToken(refreshToken string) (string, string, error) {
 // ValidateToken parses and validates a JWT token string.
 func ValidateToken(tokenString string) (*Claims, error) {
 	// Parse the token with the custom claims structure.
+	tokenConfig := tokenSettings.Load()
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(SecretKey), nil
+		return []byte(tokenConfig.Secret), nil
 	})
 	if err != nil {
 		return nil, err
@@ -129,8 +208,9 @@ func CheckPasswordHash(password, hash string) (bool, error) {
 // GetEmailFromToken extracts the email claim from a JWT token string.
 func GetEmailFromToken(tokenString string) (string, error) {
 	// Parse the token to retrieve the claims.
+	tokenConfig := tokenSettings.Load()
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		return []byte(SecretKey), nil
+		return []byte(tokenConfig.Secret), nil
 	})
 	if err != nil {
 		return "", err
@@ -2,21 +2,157 @@ package utils
 
 import (
 	"errors" // Importing errors package for creating custom errors
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
 
+	"github.com/organization_api/pkg/apperror"
 	"github.com/organization_api/pkg/database/mongodb/models" // Importing models package for user struct
+
+	"github.com/go-playground/validator/v10"
 )
 
-// ValidateUser validates a user's name and password.
-func ValidateUser(user models.User) error {
-	if err := ValidateUsername(user.Name); err != nil {
-		return err
+// orgNamePattern allows letters, digits, spaces and common punctuation, starting with a letter or digit.
+var orgNamePattern = regexp.MustCompile(`^[\p{L}\p{N}][\p{L}\p{N} .,&'()_-]*$`)
+
+// metadataKeyPattern keeps metadata keys usable as query parameters and MongoDB field names.
+var metadataKeyPattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_-]{0,63}$`)
+
+// tagPattern allows short lowercase labels such as "enterprise" or "tier:gold".
+var tagPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9:_-]{0,49}$`)
+
+var (
+	validate     *validator.Validate
+	validateOnce sync.Once
+)
+
+// Validator returns the shared validator that enforces the `validate` struct tags.
+func Validator() *validator.Validate {
+	validateOnce.Do(func() {
+		validate = validator.New()
+		validate.SetTagName("validate")
+
+		// Report fields by their JSON names so errors match the request body.
+		validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+			if name == "-" {
+				return ""
+			}
+			if name == "" {
+				return field.Name
+			}
+			return name
+		})
+
+		validate.RegisterValidation("orgname", func(fl validator.FieldLevel) bool {
+			return orgNamePattern.MatchString(fl.Field().String())
+		})
+		validate.RegisterValidation("metakey", func(fl validator.FieldLevel) bool {
+			return metadataKeyPattern.MatchString(fl.Field().String())
+		})
+		validate.RegisterValidation("tag", func(fl validator.FieldLevel) bool {
+			return tagPattern.MatchString(fl.Field().String())
+		})
+	})
+	return validate
+}
+
+// StructValidator adapts Validator to gin's binding.StructValidator so request binding runs the `validate` tags.
+type StructValidator struct{}
+
+// ValidateStruct validates structs and pointers to structs; other values are accepted as is.
+func (StructValidator) ValidateStruct(obj interface{}) error {
+	value := reflect.ValueOf(obj)
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return nil
 	}
 
-	if err := ValidatePassword(user.Password); err != nil {
+	return Validator().Struct(obj)
+}
+
+// Engine returns the underlying validator.
+func (StructValidator) Engine() interface{} {
+	return Validator()
+}
+
+// ValidationError converts validator failures into a typed validation error with field-level details.
+// Errors that did not come from the validator are returned unchanged.
+func ValidationError(err error) error {
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
 		return err
 	}
 
-	return nil
+	fields := make([]apperror.FieldError, 0, len(validationErrors))
+	for _, fieldErr := range validationErrors {
+		fields = append(fields, apperror.FieldError{
+			Field:   fieldPath(fieldErr),
+			Rule:    fieldErr.Tag(),
+			Message: fieldMessage(fieldErr),
+		})
+	}
+
+	return apperror.Validation("The request contains invalid fields").WithFields(fields)
+}
+
+// fieldPath strips the struct name from the namespace, e.g. "Organization.name" becomes "name".
+func fieldPath(fieldErr validator.FieldError) string {
+	namespace := fieldErr.Namespace()
+	if i := strings.Index(namespace, "."); i >= 0 {
+		return namespace[i+1:]
+	}
+	return namespace
+}
+
+// fieldMessage renders a human-readable message for a failed rule.
+func fieldMessage(fieldErr validator.FieldError) string {
+	switch fieldErr.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "url":
+		return "must be a valid URL"
+	case "min":
+		if isCollection(fieldErr) {
+			return fmt.Sprintf("must have at least %s entries", fieldErr.Param())
+		}
+		return fmt.Sprintf("must be at least %s characters long", fieldErr.Param())
+	case "max":
+		if isCollection(fieldErr) {
+			return fmt.Sprintf("must have at most %s entries", fieldErr.Param())
+		}
+		return fmt.Sprintf("must be at most %s characters long", fieldErr.Param())
+	case "unique":
+		return "must not contain duplicates"
+	case "orgname":
+		return "must start with a letter or digit and may only contain letters, digits, spaces and . , & ' ( ) _ -"
+	case "metakey":
+		return "must start with a letter and may only contain letters, digits, _ and -, up to 64 characters"
+	case "tag":
+		return "must start with a lowercase letter or digit and may only contain lowercase letters, digits, :, _ and -, up to 50 characters"
+	default:
+		return fmt.Sprintf("failed the %q rule", fieldErr.Tag())
+	}
+}
+
+// isCollection reports whether the failed field is a list or map, whose length counts entries.
+func isCollection(fieldErr validator.FieldError) bool {
+	kind := fieldErr.Kind()
+	return kind == reflect.Slice || kind == reflect.Array || kind == reflect.Map
+}
+
+// ValidateUser validates a user against its `validate` tags.
+func ValidateUser(user models.User) error {
+	return ValidationError(Validator().Struct(user))
 }
 
 // ValidateUsername checks if a username is empty.
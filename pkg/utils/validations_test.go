@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/organization_api/pkg/apperror"
+	"github.com/organization_api/pkg/database/mongodb/models"
+)
+
+func TestOrganizationNameRules(t *testing.T) {
+	cases := map[string]bool{
+		"Acme":                  true,
+		"Acme & Sons (EU) Ltd.": true,
+		"Société Générale":      true,
+		"":                      false,
+		"A":                     false,
+		"-Acme":                 false,
+		"Acme <script>":         false,
+	}
+	for name, valid := range cases {
+		err := StructValidator{}.ValidateStruct(&models.Organization{Name: name, Description: "desc"})
+		if (err == nil) != valid {
+			t.Errorf("name %q: valid=%v, got err=%v", name, valid, err)
+		}
+	}
+}
+
+func TestMetadataAndTagRules(t *testing.T) {
+	err := ValidationError(Validator().Struct(models.Organization{
+		Name:        "Acme",
+		Description: "desc",
+		Metadata:    map[string]string{"region": "eu", "billing.id": "B-1"},
+		Tags:        []string{"tier:gold", "Beta"},
+	}))
+
+	var appErr *apperror.Error
+	if !errors.As(err, &appErr) {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+	rules := map[string]string{}
+	for _, field := range appErr.Fields {
+		rules[field.Field] = field.Rule
+	}
+	if rules["metadata[billing.id]"] != "metakey" || rules["tags[1]"] != "tag" || len(rules) != 2 {
+		t.Fatalf("expected the metadata key and second tag to fail, got %v", appErr.Fields)
+	}
+
+	err = ValidationError(Validator().Struct(models.TagsRequest{Tags: make([]string, 21)}))
+	if !errors.As(err, &appErr) || appErr.Fields[0].Message != "must have at most 20 entries" {
+		t.Fatalf("expected the tag count to be limited, got %v", err)
+	}
+}
+
+func TestValidationErrorReportsFields(t *testing.T) {
+	err := ValidateUser(models.User{Name: "Ada", Email: "not-an-email", Password: "short"})
+
+	var appErr *apperror.Error
+	if !errors.As(err, &appErr) || appErr.Code != apperror.CodeValidation {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+
+	rules := map[string]string{}
+	for _, field := range appErr.Fields {
+		rules[field.Field] = field.Rule
+	}
+	if rules["email"] != "email" || rules["password"] != "min" || len(rules) != 2 {
+		t.Fatalf("unexpected field errors: %+v", appErr.Fields)
+	}
+}
+
+func TestValidationErrorPassesThroughOtherErrors(t *testing.T) {
+	if err := ValidationError(nil); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	other := errors.New("boom")
+	if err := ValidationError(other); err != other {
+		t.Fatalf("expected error to pass through unchanged, got %v", err)
+	}
+}
@@ -0,0 +1,270 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/organization_api/pkg/database/mongodb/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Events published by the API.
+const (
+	EventOrganizationUpdated  = "organization.updated"
+	EventOrganizationDeleted  = "organization.deleted"
+	EventMemberInvited        = "member.invited"
+	EventMemberRemoved        = "member.removed"
+	EventOwnershipTransferred = "organization.ownership_transferred"
+	EventTest                 = "webhook.test"
+)
+
+// Store persists webhook subscriptions lookups and the delivery log.
+type Store interface {
+	FindWebhooksForEvent(ctx context.Context, organizationID, event string) ([]*models.Webhook, error)
+	SaveDelivery(ctx context.Context, delivery *models.WebhookDelivery) error
+}
+
+// Payload is the JSON envelope posted to webhook receivers.
+type Payload struct {
+	Id             string      `json:"id"`
+	Event          string      `json:"event"`
+	OrganizationId string      `json:"organization_id"`
+	CreatedAt      time.Time   `json:"created_at"`
+	Data           interface{} `json:"data,omitempty"`
+}
+
+// Dispatcher signs and delivers events to webhook receivers, retrying with exponential backoff.
+type Dispatcher struct {
+	Store       Store
+	Client      *http.Client
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+
+	mu       sync.Mutex
+	closed   bool
+	stop     context.Context
+	stopNow  context.CancelFunc
+	inFlight sync.WaitGroup
+}
+
+// NewDispatcher creates a Dispatcher with default retry settings.
+func NewDispatcher(store Store) *Dispatcher {
+	return &Dispatcher{
+		Store:       store,
+		Client:      newClient(),
+		MaxAttempts: 5,
+		BaseDelay:   time.Second,
+		MaxDelay:    time.Minute,
+	}
+}
+
+var defaultDispatcher *Dispatcher
+
+// SetDefault installs the dispatcher used by Publish.
+func SetDefault(d *Dispatcher) {
+	defaultDispatcher = d
+}
+
+// Publish delivers an event through the default dispatcher, if one is installed.
+func Publish(ctx context.Context, organizationID, event string, data interface{}) {
+	if defaultDispatcher == nil {
+		return
+	}
+	defaultDispatcher.Publish(ctx, organizationID, event, data)
+}
+
+// Publish delivers an event asynchronously to every webhook of the organization subscribed to it.
+// ctx only bounds the subscription lookup; deliveries outlive the request that triggered them.
+func (d *Dispatcher) Publish(ctx context.Context, organizationID, event string, data interface{}) {
+	hooks, err := d.Store.FindWebhooksForEvent(ctx, organizationID, event)
+	if err != nil {
+		slog.ErrorContext(ctx, "webhook: failed to look up subscriptions", "organization_id", organizationID, "event", event, "error", err)
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		slog.WarnContext(ctx, "webhook: dispatcher is shut down, dropping event", "organization_id", organizationID, "event", event)
+		return
+	}
+	ctx = d.background()
+	for _, hook := range hooks {
+		d.inFlight.Add(1)
+		go func(hook *models.Webhook) {
+			defer d.inFlight.Done()
+			d.Deliver(ctx, hook, event, data, d.MaxAttempts)
+		}(hook)
+	}
+}
+
+// Wait blocks until every in-flight delivery has finished.
+func (d *Dispatcher) Wait() {
+	d.inFlight.Wait()
+}
+
+// Shutdown stops accepting events and waits for in-flight deliveries to finish.
+// When ctx expires first, pending retries are abandoned and ctx's error is returned
+// once the remaining deliveries have recorded their last attempt.
+func (d *Dispatcher) Shutdown(ctx context.Context) error {
+	d.mu.Lock()
+	d.closed = true
+	d.background()
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.inFlight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		d.stopNow()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// background returns the context deliveries run under; Shutdown cancels it. d.mu must be held.
+func (d *Dispatcher) background() context.Context {
+	if d.stop == nil {
+		d.stop, d.stopNow = context.WithCancel(context.Background())
+	}
+	return d.stop
+}
+
+// Deliver posts an event to a single webhook, making up to maxAttempts attempts.
+// Every attempt is recorded in the delivery log; a delivery that exhausts its
+// attempts is marked dead. Cancelling ctx abandons any remaining retries.
+func (d *Dispatcher) Deliver(ctx context.Context, hook *models.Webhook, event string, data interface{}, maxAttempts int) *models.WebhookDelivery {
+	now := time.Now().UTC()
+	delivery := &models.WebhookDelivery{
+		Id:             primitive.NewObjectID(),
+		WebhookId:      hook.Id,
+		OrganizationId: hook.OrganizationId,
+		Event:          event,
+		Status:         models.DeliveryPending,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+
+	body, err := json.Marshal(Payload{
+		Id:             delivery.Id.Hex(),
+		Event:          event,
+		OrganizationId: hook.OrganizationId,
+		CreatedAt:      now,
+		Data:           data,
+	})
+	if err != nil {
+		delivery.Status = models.DeliveryDead
+		delivery.LastError = err.Error()
+		d.save(ctx, delivery)
+		return delivery
+	}
+	delivery.Payload = string(body)
+
+	for {
+		delivery.Attempts++
+		code, err := d.send(ctx, hook, delivery, body)
+		delivery.ResponseCode = code
+		delivery.UpdatedAt = time.Now().UTC()
+
+		if err == nil {
+			delivery.Status = models.DeliverySucceeded
+			delivery.LastError = ""
+			delivery.NextAttemptAt = time.Time{}
+			d.save(ctx, delivery)
+			return delivery
+		}
+
+		delivery.LastError = err.Error()
+		if delivery.Attempts >= maxAttempts {
+			delivery.Status = models.DeliveryDead
+			delivery.NextAttemptAt = time.Time{}
+			d.save(ctx, delivery)
+			return delivery
+		}
+
+		backoff := d.backoff(delivery.Attempts)
+		delivery.Status = models.DeliveryFailed
+		delivery.NextAttemptAt = delivery.UpdatedAt.Add(backoff)
+		d.save(ctx, delivery)
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return delivery
+		}
+	}
+}
+
+// send makes a single signed delivery attempt. Any non-2xx response is an error.
+func (d *Dispatcher) send(ctx context.Context, hook *models.Webhook, delivery *models.WebhookDelivery, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, delivery.Event)
+	req.Header.Set(DeliveryHeader, delivery.Id.Hex())
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(hook.Secret, timestamp, body))
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver responded with status %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// backoff returns the delay before the next attempt: BaseDelay doubled per attempt, capped at MaxDelay.
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	delay := d.BaseDelay
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= d.MaxDelay {
+			return d.MaxDelay
+		}
+	}
+	return delay
+}
+
+// save records a delivery even when ctx has been cancelled, so the log reflects the last attempt.
+func (d *Dispatcher) save(ctx context.Context, delivery *models.WebhookDelivery) {
+	if err := d.Store.SaveDelivery(context.WithoutCancel(ctx), delivery); err != nil {
+		slog.ErrorContext(ctx, "webhook: failed to record delivery", "delivery_id", delivery.Id.Hex(), "error", err)
+	}
+}
+
+// GenerateSecret returns a random hex-encoded signing secret.
+func GenerateSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
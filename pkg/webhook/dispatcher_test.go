@@ -0,0 +1,227 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/organization_api/pkg/database/mongodb/models"
+	"github.com/organization_api/pkg/database/mongodb/repository"
+)
+
+func newTestDispatcher(store Store) *Dispatcher {
+	d := NewDispatcher(store)
+	// Test receivers listen on loopback, which the default client refuses to dial.
+	d.Client = &http.Client{Timeout: time.Second}
+	d.BaseDelay = time.Millisecond
+	d.MaxDelay = 4 * time.Millisecond
+	return d
+}
+
+func TestDeliverSignsPayload(t *testing.T) {
+	ctx := context.Background()
+	var verified atomic.Bool
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp, _ := strconv.ParseInt(r.Header.Get(TimestampHeader), 10, 64)
+		verified.Store(Verify("s3cret", timestamp, body, r.Header.Get(SignatureHeader)) &&
+			r.Header.Get(EventHeader) == EventMemberInvited)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	store := repository.NewMemoryWebhookRepo()
+	hook := &models.Webhook{OrganizationId: "org", URL: receiver.URL, Secret: "s3cret", Active: true}
+	store.CreateWebhook(ctx, hook)
+
+	delivery := newTestDispatcher(store).Deliver(ctx, hook, EventMemberInvited, map[string]string{"user_email": "a@example.com"}, 3)
+	if delivery.Status != models.DeliverySucceeded || delivery.Attempts != 1 {
+		t.Fatalf("unexpected delivery: %+v", delivery)
+	}
+	if !verified.Load() {
+		t.Fatal("receiver could not verify the signature")
+	}
+}
+
+func TestDeliverRetriesThenSucceeds(t *testing.T) {
+	ctx := context.Background()
+	var calls atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer receiver.Close()
+
+	store := repository.NewMemoryWebhookRepo()
+	hook := &models.Webhook{OrganizationId: "org", URL: receiver.URL, Secret: "s", Active: true}
+	store.CreateWebhook(ctx, hook)
+
+	delivery := newTestDispatcher(store).Deliver(ctx, hook, EventOrganizationUpdated, nil, 5)
+	if delivery.Status != models.DeliverySucceeded || delivery.Attempts != 3 {
+		t.Fatalf("unexpected delivery: %+v", delivery)
+	}
+}
+
+func TestDeliverDeadLettersAfterMaxAttempts(t *testing.T) {
+	ctx := context.Background()
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer receiver.Close()
+
+	store := repository.NewMemoryWebhookRepo()
+	hook := &models.Webhook{OrganizationId: "org", URL: receiver.URL, Secret: "s", Active: true}
+	store.CreateWebhook(ctx, hook)
+
+	delivery := newTestDispatcher(store).Deliver(ctx, hook, EventOrganizationUpdated, nil, 3)
+	if delivery.Status != models.DeliveryDead || delivery.Attempts != 3 || delivery.ResponseCode != http.StatusInternalServerError {
+		t.Fatalf("unexpected delivery: %+v", delivery)
+	}
+
+	logged, _ := store.GetDeliveriesByWebhook(ctx, hook.Id.Hex())
+	if len(logged) != 1 || logged[0].Status != models.DeliveryDead {
+		t.Fatalf("expected a single dead delivery in the log, got %+v", logged)
+	}
+}
+
+func TestPublishHonoursEventFilter(t *testing.T) {
+	ctx := context.Background()
+	var calls atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	}))
+	defer receiver.Close()
+
+	store := repository.NewMemoryWebhookRepo()
+	store.CreateWebhook(ctx, &models.Webhook{OrganizationId: "org", URL: receiver.URL, Active: true})
+	store.CreateWebhook(ctx, &models.Webhook{OrganizationId: "org", URL: receiver.URL, Events: []string{EventMemberInvited}, Active: true})
+	store.CreateWebhook(ctx, &models.Webhook{OrganizationId: "other", URL: receiver.URL, Active: true})
+
+	d := newTestDispatcher(store)
+	d.Publish(ctx, "org", EventOrganizationDeleted, nil)
+	d.Wait()
+
+	if calls.Load() != 1 {
+		t.Fatalf("expected 1 delivery, got %d", calls.Load())
+	}
+}
+
+func TestBackoffIsCapped(t *testing.T) {
+	d := &Dispatcher{BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, want := range expected {
+		if got := d.backoff(i + 1); got != want {
+			t.Fatalf("backoff(%d) = %v, want %v", i+1, got, want)
+		}
+	}
+}
+
+func TestShutdownDrainsThenRejects(t *testing.T) {
+	ctx := context.Background()
+	var calls atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer receiver.Close()
+
+	store := repository.NewMemoryWebhookRepo()
+	store.CreateWebhook(ctx, &models.Webhook{OrganizationId: "org", URL: receiver.URL, Secret: "s", Active: true})
+
+	d := newTestDispatcher(store)
+	d.Publish(ctx, "org", EventOrganizationUpdated, nil)
+	if err := d.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("in-flight delivery was not drained: %d calls", calls.Load())
+	}
+
+	d.Publish(ctx, "org", EventOrganizationUpdated, nil)
+	d.Wait()
+	if calls.Load() != 1 {
+		t.Fatal("events published after shutdown must be dropped")
+	}
+}
+
+func TestShutdownAbandonsRetriesAtDeadline(t *testing.T) {
+	ctx := context.Background()
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer receiver.Close()
+
+	store := repository.NewMemoryWebhookRepo()
+	hook := &models.Webhook{OrganizationId: "org", URL: receiver.URL, Secret: "s", Active: true}
+	store.CreateWebhook(ctx, hook)
+
+	d := newTestDispatcher(store)
+	d.BaseDelay, d.MaxDelay = time.Hour, time.Hour
+	d.Publish(ctx, "org", EventOrganizationUpdated, nil)
+
+	shutdownCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := d.Shutdown(shutdownCtx); err != context.DeadlineExceeded {
+		t.Fatalf("Shutdown error = %v, want deadline exceeded", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Fatal("Shutdown waited for the retry backoff")
+	}
+
+	deliveries, _ := store.GetDeliveriesByWebhook(ctx, hook.Id.Hex())
+	if len(deliveries) != 1 || deliveries[0].Status != models.DeliveryFailed {
+		t.Fatalf("last attempt not recorded: %+v", deliveries)
+	}
+}
+
+func TestCheckTarget(t *testing.T) {
+	ctx := context.Background()
+	for _, target := range []string{
+		"http://127.0.0.1:8080/hook",
+		"http://localhost/hook",
+		"http://10.1.2.3/hook",
+		"http://192.168.0.10/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://100.64.0.1/hook",
+		"http://[::1]/hook",
+		"http://[fd00:ec2::254]/hook",
+		"http://0.0.0.0/hook",
+		"ftp://93.184.216.34/hook",
+	} {
+		if err := CheckTarget(ctx, target); err == nil {
+			t.Errorf("%s: expected the target to be refused", target)
+		}
+	}
+	if err := CheckTarget(ctx, "https://93.184.216.34/hook"); err != nil {
+		t.Errorf("public address: %v", err)
+	}
+}
+
+func TestDefaultClientRefusesInternalAddresses(t *testing.T) {
+	ctx := context.Background()
+	var calls atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	}))
+	defer receiver.Close()
+
+	store := repository.NewMemoryWebhookRepo()
+	hook := &models.Webhook{OrganizationId: "org", URL: receiver.URL, Secret: "s", Active: true}
+	store.CreateWebhook(ctx, hook)
+
+	d := NewDispatcher(store)
+	delivery := d.Deliver(ctx, hook, EventTest, nil, 1)
+	if delivery.Status != models.DeliveryDead || calls.Load() != 0 || !strings.Contains(delivery.LastError, ErrForbiddenTarget.Error()) {
+		t.Fatalf("expected the loopback receiver to be refused, got %+v after %d calls", delivery, calls.Load())
+	}
+}
@@ -0,0 +1,79 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// ErrForbiddenTarget reports a webhook URL that points into the network the API runs in.
+var ErrForbiddenTarget = errors.New("webhook target is not a public address")
+
+// sharedAddressSpace is the carrier-grade NAT range, private in practice though not by RFC 1918.
+var sharedAddressSpace = &net.IPNet{IP: net.IP{100, 64, 0, 0}, Mask: net.CIDRMask(10, 32)}
+
+// forbiddenIP reports whether ip is loopback, private, link-local (which includes the cloud
+// metadata address 169.254.169.254), unspecified or multicast.
+func forbiddenIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() || sharedAddressSpace.Contains(ip)
+}
+
+// CheckTarget rejects webhook URLs that are not http(s) or whose host resolves to an address the API
+// must not call. The check is repeated when dialing, since DNS answers can change after creation.
+func CheckTarget(ctx context.Context, rawURL string) error {
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if target.Scheme != "http" && target.Scheme != "https" {
+		return fmt.Errorf("webhook target must use http or https, not %q", target.Scheme)
+	}
+	host := target.Hostname()
+	if host == "" {
+		return errors.New("webhook target has no host")
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return fmt.Errorf("webhook target %s does not resolve: %w", host, err)
+	}
+	for _, addr := range addrs {
+		if forbiddenIP(addr.IP) {
+			return fmt.Errorf("%w: %s resolves to %s", ErrForbiddenTarget, host, addr.IP)
+		}
+	}
+	return nil
+}
+
+// guardedDialer refuses connections to forbidden addresses once the name has been resolved, so a
+// receiver cannot be re-pointed at an internal host through DNS or a redirect.
+func guardedDialer() *net.Dialer {
+	return &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: func(network, address string, conn syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || forbiddenIP(ip) {
+				return fmt.Errorf("%w: %s", ErrForbiddenTarget, host)
+			}
+			return nil
+		},
+	}
+}
+
+// newClient returns the HTTP client deliveries use: it only dials public addresses and ignores
+// proxy settings, which would otherwise decide where requests really go.
+func newClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = guardedDialer().DialContext
+	return &http.Client{Timeout: 10 * time.Second, Transport: transport}
+}